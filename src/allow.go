@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/setup"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The $PATH scripts get, worked out once per run by scriptPath
+var restrictedPath struct {
+	sync.Once
+	dir string
+	err error
+}
+
+/* Returns the $PATH the scripts run with, an empty one leaves them tuckr's own
+With the config's script_commands set it's a directory holding only those commands
+which gets removed when tuckr exits */
+func scriptPath() (string, error) {
+	restrictedPath.Do(func() {
+		conf, err := config.LoadConfig()
+		if err != nil || len(conf.General.ScriptCommands) == 0 {
+			restrictedPath.err = err
+			return
+		}
+		dir, missing, err := setup.RestrictedPath(conf.General.ScriptCommands)
+		if err != nil {
+			restrictedPath.err = err
+			return
+		}
+		registerCleanup(func() { os.RemoveAll(dir) })
+		if len(missing) > 0 {
+			fmt.Println(aurora.Yellow("Warning:"), "script_commands lists commands that aren't installed:", strings.Join(missing, ", "))
+		}
+		restrictedPath.dir = dir
+	})
+	return restrictedPath.dir, restrictedPath.err
+}
+
+/* Prints a warning for each of the scripts in dir that isn't in the config's allowed_scripts
+They're matched relative to the store, nothing is flagged when allowed_scripts is empty */
+func flagUnlistedScripts(root string, dir string, scripts []string) error {
+	conf, err := config.LoadConfig()
+	if err != nil || len(conf.General.AllowedScripts) == 0 {
+		return err
+	}
+	for _, script := range scripts {
+		path := filepath.Join(dir, script)
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if !scriptAllowed(filepath.ToSlash(rel), conf.General.AllowedScripts) {
+			fmt.Println(aurora.Yellow("Warning:"), path, "isn't in allowed_scripts")
+		}
+	}
+	return nil
+}
+
+// Returns true if the script, relative to the store, matches any of the globs
+func scriptAllowed(rel string, allowed []string) bool {
+	for _, glob := range allowed {
+		if ok, _ := filepath.Match(glob, rel); ok {
+			return true
+		}
+	}
+	return false
+}
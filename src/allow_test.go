@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScriptsOutsideAllowedScriptsAreFlaggedBeforeTheyRun(t *testing.T) {
+	e := newTestEnv(t)
+	e.config(t, "[GENERAL]\nallowed_scripts = Hooks/vim/set_plugins.sh, Hooks/*/linux/*\n")
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Hooks/vim/set_plugins.sh", "echo ran plugins\n")
+	fetch := e.write(t, "Hooks/vim/set_fetch.sh", "echo ran fetch\n")
+	e.write(t, "Hooks/vim/linux/set_fonts.sh", "echo ran fonts\n")
+
+	out := uncolored(captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	warning := strings.Index(out, "Warning: "+fetch+" isn't in allowed_scripts")
+	if ran := strings.Index(out, "ran fetch"); warning < 0 || ran < warning {
+		t.Errorf("set_fetch.sh wasn't flagged before it ran:\n%s", out)
+	}
+	for _, script := range []string{"set_plugins.sh", "set_fonts.sh"} {
+		if strings.Contains(out, script+" isn't in allowed_scripts") {
+			t.Errorf("the allowed %s was flagged:\n%s", script, out)
+		}
+	}
+
+	out = uncolored(captureOutput(t, func() {
+		if err := runScripts([]string{"run", "vim", "set_fetch.sh"}); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	if warning, ran := strings.Index(out, "isn't in allowed_scripts"), strings.Index(out, "ran fetch"); warning < 0 || ran < warning {
+		t.Errorf("running set_fetch.sh alone didn't flag it first:\n%s", out)
+	}
+}
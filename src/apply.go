@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/setup"
+	"github.com/raphgl/tuckr/store"
+	"os"
+)
+
+/* Handles the apply command which provisions a [PROFILE <name>] of the config
+Its groups are set, then its packages are installed and its scripts are run */
+func runApply(args []string) error {
+	if len(args) != 1 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := config.HomeDir()
+	if err != nil {
+		return err
+	}
+	err = applyProfile(root, home, conf, args[0], setup.ExecRunner{})
+	return maintain(err, setup.ExecRunner{})
+}
+
+/* Sets the groups of the profile, installs its packages and runs its scripts
+Every script is looked up before anything is done so a typo doesn't leave the
+profile half applied */
+func applyProfile(root string, home string, conf config.Config, name string, runner setup.CommandRunner) error {
+	profile, ok := conf.Profiles[name]
+	if !ok {
+		return errors.New("Error: No profile " + name + " in config")
+	}
+	var scripts []string
+	for _, script := range profile.Scripts {
+		path, ok := conf.Scripts[script]
+		if !ok {
+			return errors.New("Error: Profile " + name + " runs script " + script + " which isn't under [SCRIPTS]")
+		}
+		scripts = append(scripts, path)
+	}
+	var install []string
+	if len(profile.Packages) > 0 {
+		cmd, err := setup.SplitCommand(conf.Packages.PkgInstallCmd)
+		if err != nil {
+			return err
+		}
+		if len(cmd) == 0 {
+			return errors.New("Error: Profile " + name + " has packages but no pkg_install_cmd is set in config")
+		}
+		install = append(cmd, profile.Packages...)
+	}
+
+	if len(profile.Groups) > 0 {
+		filter, err := deployFilter(root)
+		if err != nil {
+			return err
+		}
+		groups, err := selectGroups(root, profile.Groups, filter)
+		if err != nil {
+			return err
+		}
+		if err := setGroups(root, home, groups, setOptions{}); err != nil {
+			return err
+		}
+	}
+	if len(install) > 0 {
+		fmt.Println(aurora.Green("Installing:"), len(profile.Packages), "packages")
+		if err := runner.Run(install[0], install[1:]...); err != nil {
+			return errors.New("Error: Could not install the packages of " + name + ": " + err.Error())
+		}
+	}
+	for i, path := range scripts {
+		fmt.Println(aurora.Green("Running:"), profile.Scripts[i])
+		if err := runner.Run("sh", path); err != nil {
+			return errors.New("Error: Script " + profile.Scripts[i] + " failed: " + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplySetsInstallsAndRunsTheProfile(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	gitconfig := e.write(t, "Configs/git/.gitconfig", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	fonts := filepath.Join(e.dir, "fonts.sh")
+	e.config(t, `[PACKAGES]
+pkg_install_cmd = pacman -S --needed
+[SCRIPTS]
+fonts = `+fonts+`
+dock = `+filepath.Join(e.dir, "dock.sh")+`
+[PROFILE laptop]
+groups = vim, git
+packages = neovim, git
+scripts = fonts
+`)
+	conf, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var early bool
+	runner := &stubRunner{run: func(name string, args ...string) error {
+		// the groups are set before anything is installed or run
+		if _, err := os.Lstat(e.inHome(".gitconfig")); err != nil {
+			early = true
+		}
+		return nil
+	}}
+	captureOutput(t, func() {
+		if err := applyProfile(e.store, e.home, conf, "laptop", runner); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertLinked(t, e.inHome(".gitconfig"), gitconfig)
+	assertMissing(t, e.inHome(".zshrc"))
+	if want := []string{"pacman -S --needed neovim git", "sh " + fonts}; !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("applying the profile ran %v, want %v", runner.calls, want)
+	}
+	if early {
+		t.Error("the packages were installed before the groups were set")
+	}
+
+	conf.Profiles["broken"] = config.Profile{Groups: []string{"zsh"}, Scripts: []string{"missing"}}
+	for name, want := range map[string]string{"desktop": "No profile desktop", "broken": "isn't under [SCRIPTS]"} {
+		runner.calls = nil
+		if err := applyProfile(e.store, e.home, conf, name, runner); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("applying %s failed with %v, want %q", name, err, want)
+		}
+		if len(runner.calls) != 0 {
+			t.Errorf("the failed %s profile still ran %v", name, runner.calls)
+		}
+	}
+	// Scripts are looked up before anything is set
+	assertMissing(t, e.inHome(".zshrc"))
+}
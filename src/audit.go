@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// A linked file of a sensitive group whose mode is more permissive than allowed
+type loosePerms struct {
+	path    string
+	mode    os.FileMode
+	allowed os.FileMode
+}
+
+/* Handles the audit-perms command
+The store files linked by the sensitive groups, or by the groups given instead, are
+checked to not be more permissive than sensitive_perms allows, with --fix the extra
+permission bits get removed */
+func runAuditPerms(args []string) error {
+	flags := flag.NewFlagSet("audit-perms", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "remove the permissions the files shouldn't have")
+	userName := flags.String("user", "", "audit the links in this user's home instead")
+	flags.Parse(args)
+	args = flags.Args()
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := targetHome(*userName)
+	if err != nil {
+		return err
+	}
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	groups, err := sensitiveGroups(root, conf.General.SensitiveGroups)
+	if len(args) > 0 {
+		groups, err = selectGroups(root, args, store.GroupFilter{})
+	}
+	if err != nil {
+		return err
+	}
+	loose, err := auditPerms(root, home, groups, os.FileMode(conf.General.SensitivePerms))
+	if err != nil {
+		return err
+	}
+	for _, l := range loose {
+		if *fix {
+			if err := os.Chmod(l.path, l.mode&l.allowed); err != nil {
+				return err
+			}
+			fmt.Println(aurora.Green("Tightened:"), l.path, fmt.Sprintf("%03o -> %03o", l.mode, l.mode&l.allowed))
+			continue
+		}
+		fmt.Println(aurora.Red("Too permissive:"), l.path, fmt.Sprintf("(%03o, at most %03o)", l.mode, l.allowed))
+	}
+	if len(loose) > 0 && !*fix {
+		return errors.New("Error: " + strconv.Itoa(len(loose)) + " sensitive file(s) are too permissive, run audit-perms --fix to tighten them")
+	}
+	if len(loose) == 0 {
+		fmt.Println(aurora.Green("Audited:"), "no sensitive file is too permissive")
+	}
+	return nil
+}
+
+// Returns the groups of the store that match any of the patterns
+func sensitiveGroups(root string, patterns []string) ([]string, error) {
+	all, err := store.ListGroups(root, store.GroupFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var groups []string
+	for _, group := range all {
+		for _, pattern := range patterns {
+			if ok, err := filepath.Match(pattern, group); err != nil {
+				return nil, errors.New("Error: Invalid group pattern " + pattern + " in sensitive_groups")
+			} else if ok {
+				groups = append(groups, group)
+				break
+			}
+		}
+	}
+	return groups, nil
+}
+
+/* Returns the files and directories behind the links of the groups that have permissions perms doesn't
+Only what's linked is looked at and directories are allowed to be searched by whoever perms lets read */
+func auditPerms(root string, home string, groups []string, perms os.FileMode) ([]loosePerms, error) {
+	dirPerms := perms | (perms&0444)>>2
+	var loose []loosePerms
+	audit := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		allowed := perms
+		if info.IsDir() {
+			allowed = dirPerms
+		}
+		if mode := info.Mode().Perm(); mode&^allowed != 0 {
+			loose = append(loose, loosePerms{path: path, mode: mode, allowed: allowed})
+		}
+		return nil
+	}
+	for _, group := range groups {
+		deployments, err := groupDeployments(root, group, home)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range deployments {
+			links, err := groupLinks(root, group, d)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range links {
+				if !l.IsLinked() {
+					continue
+				}
+				source, err := filepath.EvalSymlinks(l.Source)
+				if err != nil {
+					return nil, err
+				}
+				if err := filepath.Walk(source, audit); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return loose, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditPermsReportsAndTightensLooseSensitiveFiles(t *testing.T) {
+	e := newTestEnv(t)
+	config := e.write(t, "Configs/ssh/.ssh/config", "Host *\n")
+	key := e.write(t, "Configs/ssh/.ssh/id_ed25519", "")
+	keys := filepath.Join(e.store, "Configs", "gnupg", ".gnupg")
+	ring := e.write(t, "Configs/gnupg/.gnupg/pubring.kbx", "")
+	e.write(t, "Configs/gnupg/.tuckr.json", `{"linkAsDirectory": [".gnupg"]}`)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	unlinked := e.write(t, "Configs/ssh/.ssh/known_hosts", "")
+	for path, mode := range map[string]os.FileMode{config: 0644, key: 0600, keys: 0755, ring: 0644, vimrc: 0666, unlinked: 0644} {
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	captureOutput(t, func() {
+		if err := runSet([]string{"ssh", "gnupg", "vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err := os.Remove(e.inHome(".ssh/known_hosts")); err != nil {
+		t.Fatal(err)
+	}
+
+	out := uncolored(captureOutput(t, func() {
+		if err := runAuditPerms(nil); err == nil {
+			t.Error("audit-perms passed with loose files")
+		}
+	}))
+	for _, line := range []string{"Too permissive: " + config + " (644, at most 600)", "Too permissive: " + keys + " (755, at most 700)", "Too permissive: " + ring + " (644, at most 600)"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("audit-perms didn't report %q:\n%s", line, out)
+		}
+	}
+	for _, path := range []string{key, vimrc, unlinked} {
+		if strings.Contains(out, path+" ") {
+			t.Errorf("audit-perms reported %s:\n%s", path, out)
+		}
+	}
+
+	captureOutput(t, func() {
+		if err := runAuditPerms([]string{"--fix"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for path, want := range map[string]os.FileMode{config: 0600, key: 0600, keys: 0700, ring: 0600, vimrc: 0666, unlinked: 0644} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != want {
+			t.Errorf("after --fix %s has the mode %v %v, want %v", path, info.Mode().Perm(), err, want)
+		}
+	}
+	captureOutput(t, func() {
+		if err := runAuditPerms(nil); err != nil {
+			t.Errorf("audit-perms still failed after --fix: %v", err)
+		}
+	})
+
+	// The rules come from the config and groups can be given instead
+	e.config(t, "[GENERAL]\nsensitive_groups = vi*\nsensitive_perms = 640\n")
+	captureOutput(t, func() {
+		if err := runAuditPerms(nil); err == nil {
+			t.Error("vim's 666 .vimrc passed sensitive_perms 640")
+		}
+	})
+	out = uncolored(captureOutput(t, func() {
+		if err := runAuditPerms([]string{"--fix", "ssh"}); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	if strings.Contains(out, vimrc) || !strings.Contains(out, "no sensitive file is too permissive") {
+		t.Errorf("auditing only ssh printed:\n%s", out)
+	}
+}
@@ -0,0 +1,276 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/raphgl/tuckr/store"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Name of the manifest file stored at the root of every bundle
+const ManifestName = "tuckr.manifest.json"
+
+/* Describes the contents of a bundle
+Files maps every path relative to the store root to the sha256 of its contents
+and Links maps the symlinks of the store to what they point to */
+type Manifest struct {
+	Version int               `json:"version"`
+	Created time.Time         `json:"created"`
+	Groups  []string          `json:"groups"`
+	Files   map[string]string `json:"files"`
+	Links   map[string]string `json:"links,omitempty"`
+}
+
+/* Packages the store at root into a gzipped tarball at out
+Everything in the store is bundled except for what store.IsLocalState leaves out, secrets included
+since Secrets only ever holds their encrypted files. Symlinks are bundled as they are */
+func Export(root string, out string) (Manifest, error) {
+	manifest := Manifest{Version: 1, Created: time.Now().UTC(), Files: map[string]string{}, Links: map[string]string{}}
+	groups, err := store.Groups(root)
+	if err != nil {
+		return manifest, err
+	}
+	manifest.Groups = groups
+
+	outPath, err := filepath.Abs(out)
+	if err != nil {
+		return manifest, err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return manifest, err
+	}
+	var paths []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if abs, err := filepath.Abs(path); err == nil && abs == outPath {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if store.IsLocalState(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			dest, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			manifest.Links[rel] = filepath.ToSlash(dest)
+			paths = append(paths, rel)
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		sum, err := store.HashFile(path)
+		if err != nil {
+			return err
+		}
+		manifest.Files[rel] = sum
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return manifest, err
+	}
+	sort.Strings(paths)
+
+	f, err := os.Create(out)
+	if err != nil {
+		return manifest, err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	hdr := &tar.Header{Name: ManifestName, Mode: 0644, Size: int64(len(data)), ModTime: manifest.Created}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return manifest, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return manifest, err
+	}
+
+	for _, rel := range paths {
+		if err := addFile(tw, root, rel); err != nil {
+			return manifest, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	if err := gz.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, f.Close()
+}
+
+/* Reconstructs a store at root from the bundle at in
+Every extracted file and symlink is checked against the bundle's manifest, the
+symlinks are only created once every file is in place so no file of the bundle
+can be written through one of them */
+func Import(in string, root string) (Manifest, error) {
+	var manifest Manifest
+	f, err := os.Open(in)
+	if err != nil {
+		return manifest, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return manifest, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != ManifestName {
+		return manifest, errors.New("Error: Bundle has no manifest")
+	}
+	data, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, errors.New("Error: Bundle manifest is malformed")
+	}
+
+	extracted := map[string]bool{}
+	links := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, err
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			if want, ok := manifest.Links[hdr.Name]; !ok || want != hdr.Linkname {
+				return manifest, errors.New("Error: " + hdr.Name + " does not match the bundle manifest")
+			}
+			links[hdr.Name] = hdr.Linkname
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		want, ok := manifest.Files[hdr.Name]
+		if !ok {
+			return manifest, errors.New("Error: " + hdr.Name + " is not listed in the bundle manifest")
+		}
+		dest, err := safeJoin(root, hdr.Name)
+		if err != nil {
+			return manifest, err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return manifest, err
+		}
+		out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+		if err != nil {
+			return manifest, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(io.MultiWriter(out, h), tr)
+		out.Close()
+		if err != nil {
+			return manifest, err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != want {
+			return manifest, errors.New("Error: " + hdr.Name + " does not match the bundle manifest")
+		}
+		extracted[hdr.Name] = true
+	}
+
+	for path := range manifest.Files {
+		if !extracted[path] {
+			return manifest, errors.New("Error: " + path + " is missing from the bundle")
+		}
+	}
+	var names []string
+	for name := range manifest.Links {
+		if _, ok := links[name]; !ok {
+			return manifest, errors.New("Error: " + name + " is missing from the bundle")
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dest, err := safeJoin(root, name)
+		if err != nil {
+			return manifest, err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return manifest, err
+		}
+		if err := os.Symlink(filepath.FromSlash(links[name]), dest); err != nil {
+			return manifest, err
+		}
+	}
+	return manifest, nil
+}
+
+// Writes the file or symlink at root/rel into the tarball under the name rel
+func addFile(tw *tar.Writer, root string, rel string) error {
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(info, filepath.ToSlash(link))
+	if err != nil {
+		return err
+	}
+	hdr.Name = rel
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if link != "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Joins name onto root making sure the result does not escape root
+func safeJoin(root string, name string) (string, error) {
+	dest := filepath.Join(root, filepath.FromSlash(name))
+	rel, err := filepath.Rel(root, dest)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("Error: " + name + " points outside of the store")
+	}
+	return dest, nil
+}
@@ -0,0 +1,170 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Writes contents to root/rel creating its parent directories
+func writeFile(t *testing.T, root string, rel string, contents string, mode os.FileMode) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(contents), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Returns every file and symlink under root mapped to its contents or what it points to
+func tree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if info.Mode()&os.ModeSymlink != 0 {
+			dest, err := os.Readlink(path)
+			files[filepath.ToSlash(rel)] = "-> " + dest
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		files[filepath.ToSlash(rel)] = string(data) + " " + info.Mode().Perm().String()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tuckr-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "store")
+	writeFile(t, src, "Configs/vim/.vimrc", "set nu", 0644)
+	writeFile(t, src, "Configs/vim/.config/nvim/init.vim", "source ~/.vimrc", 0644)
+	writeFile(t, src, "Hooks/vim/set_plugins.sh", "echo hi", 0755)
+	writeFile(t, src, "Secrets/vim/token.age", "encrypted", 0600)
+	writeFile(t, src, ".tuckrignore", "scratch", 0644)
+	writeFile(t, src, ".git/config", "[core]", 0644)
+	writeFile(t, src, "tuckr.history.jsonl", "{}", 0644)
+	writeFile(t, src, "tuckr.history.jsonl.1", "{}", 0644)
+	if err := os.Symlink(".vimrc", filepath.Join(src, "Configs", "vim", ".exrc")); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "store.tar.gz")
+	exported, err := Export(src, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exported.Groups, []string{"vim"}) {
+		t.Errorf("exported groups %v, want [vim]", exported.Groups)
+	}
+	dest := filepath.Join(dir, "imported")
+	if _, err := Import(out, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	want := tree(t, src)
+	delete(want, ".git/config")
+	delete(want, "tuckr.history.jsonl")
+	delete(want, "tuckr.history.jsonl.1")
+	for rel := range exported.Files {
+		if rel == "tuckr.history.jsonl" || rel == "tuckr.history.jsonl.1" {
+			t.Errorf("the store's history %s got bundled", rel)
+		}
+	}
+	if got := tree(t, dest); !reflect.DeepEqual(got, want) {
+		t.Errorf("imported store differs\ngot  %v\nwant %v", got, want)
+	}
+}
+
+func TestImportRejectsTamperedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tuckr-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "store")
+	writeFile(t, src, "Configs/vim/.vimrc", "set nu", 0644)
+	out := filepath.Join(src, "store.tar.gz")
+	manifest, err := Export(src, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := manifest.Files["store.tar.gz"]; ok {
+		t.Error("the bundle being written got bundled into itself")
+	}
+	manifest.Files["Configs/vim/.vimrc"] = "0000"
+	if err := rewriteManifest(out, manifest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(out, filepath.Join(dir, "imported")); err == nil {
+		t.Error("importing a file that doesn't match the manifest succeeded")
+	}
+}
+
+// Replaces the manifest of the bundle at path keeping its other entries as they are
+func rewriteManifest(path string, manifest Manifest) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if hdr.Name == ManifestName {
+			if data, err = json.Marshal(manifest); err != nil {
+				return err
+			}
+			hdr.Size = int64(len(data))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	f.Close()
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
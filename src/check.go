@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Names a group can have without causing trouble in paths or on the command line
+var validGroupName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.+-]*$`)
+
+/* Handles the check command which validates the store without deploying anything
+Every problem found is printed and the exit code is non-zero if there's any
+With --templates every template is also rendered without writing it anywhere and
+with --scripts every script is checked to be there and executable */
+func runCheck(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	templates := flags.Bool("templates", false, "also render every "+store.TemplateExt+" file against the current environment, set links them without rendering")
+	scripts := flags.Bool("scripts", false, "also check the groups' set_ and unset_ scripts and the config's [SCRIPTS] are there and executable")
+	flags.Parse(args)
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := config.HomeDir()
+	if err != nil {
+		return err
+	}
+	problems, err := checkStore(root, home)
+	if err != nil {
+		return err
+	}
+	if *templates {
+		broken, err := checkTemplates(root, store.CurrentTemplateData())
+		if err != nil {
+			return err
+		}
+		problems = append(problems, broken...)
+	}
+	if *scripts {
+		broken, err := checkScripts(root)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, broken...)
+	}
+	for _, p := range problems {
+		fmt.Println(aurora.Red("Problem:"), p)
+	}
+	if len(problems) > 0 {
+		return errors.New("Error: Found " + strconv.Itoa(len(problems)) + " problem(s) in " + root)
+	}
+	fmt.Println(aurora.Green("No problems found in"), root)
+	return nil
+}
+
+/* Validates every group of the store, returning a description of each problem
+It checks group names, .tuckr.json files, paths that would escape the group,
+dependencies that don't exist or form a cycle, links that would collide on the
+same target, including targets that only differ in case on case-insensitive
+platforms, scripts that don't exist and profiles referring to either */
+func checkStore(root string, home string) ([]string, error) {
+	// Every group is validated, including the ones set leaves out on this machine
+	groups, err := store.ListGroups(root, store.GroupFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	owners := map[string]string{}
+	var allLinks []manage.Link
+	// Only groups whose .tuckr.json parses get their dependencies sorted so it's reported once
+	var parsed []string
+	for _, group := range groups {
+		if !validGroupName.MatchString(group) {
+			problems = append(problems, "group "+strconv.Quote(group)+" has an invalid name")
+		}
+		conf, err := store.LoadGroupConfig(root, group)
+		if err != nil {
+			problems = append(problems, strings.TrimPrefix(err.Error(), "Error: "))
+			continue
+		}
+		parsed = append(parsed, group)
+		src := store.GroupPath(root, group)
+		for _, dir := range conf.LinkAsDirectory {
+			problems = append(problems, checkGroupPath(src, group, "linkAsDirectory", dir)...)
+		}
+		var files []string
+		for file := range conf.Actions {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			problems = append(problems, checkGroupPath(src, group, "actions", file)...)
+		}
+		for _, file := range conf.Executable {
+			problems = append(problems, checkGroupPath(src, group, "executable", file)...)
+		}
+		files = nil
+		for file := range conf.Links {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			problems = append(problems, checkGroupPath(src, group, "links", file)...)
+		}
+		if conf.Hooks != "" {
+			problems = append(problems, checkGroupPath(src, group, "hooks", conf.Hooks)...)
+		}
+		for _, dep := range conf.DependsOn {
+			if !store.HasGroup(root, dep) {
+				problems = append(problems, group+" depends on "+dep+" which doesn't exist")
+			}
+		}
+
+		deployments, err := groupDeployments(root, group, home)
+		if err != nil {
+			problems = append(problems, strings.TrimPrefix(err.Error(), "Error: "))
+			continue
+		}
+		for _, d := range deployments {
+			links, err := storeLinks(root, group, d)
+			if err != nil {
+				problems = append(problems, strings.TrimPrefix(err.Error(), "Error: "))
+				continue
+			}
+			for _, l := range links {
+				if dest, err := filepath.EvalSymlinks(l.Source); err == nil && !manage.IsWithin(dest, root) {
+					problems = append(problems, l.Source+" in group "+group+" points outside of the store to "+dest)
+				}
+				if owner, ok := owners[l.Target]; ok {
+					problems = append(problems, l.Target+" is linked by both "+owner+" and "+l.Source)
+					continue
+				}
+				owners[l.Target] = l.Source
+				allLinks = append(allLinks, l)
+			}
+		}
+	}
+	if _, err := store.SortByDependencies(root, parsed); err != nil {
+		problems = append(problems, strings.TrimPrefix(err.Error(), "Error: "))
+	}
+	if manage.CaseInsensitive(runtime.GOOS) {
+		for _, pair := range manage.CaseCollisions(allLinks) {
+			problems = append(problems, pair[0].Source+" and "+pair[1].Source+" collide on a case-insensitive filesystem at "+pair[1].Target)
+		}
+	}
+
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	var scripts []string
+	for name := range conf.Scripts {
+		scripts = append(scripts, name)
+	}
+	sort.Strings(scripts)
+	for _, name := range scripts {
+		if _, err := os.Stat(conf.Scripts[name]); err != nil {
+			problems = append(problems, "script "+name+" points to "+conf.Scripts[name]+" which doesn't exist")
+		}
+	}
+	var profiles []string
+	for name := range conf.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		for _, script := range conf.Profiles[name].Scripts {
+			if _, ok := conf.Scripts[script]; !ok {
+				problems = append(problems, "profile "+name+" runs script "+script+" which isn't under [SCRIPTS]")
+			}
+		}
+		for _, group := range conf.Profiles[name].Groups {
+			if !strings.ContainsAny(group, "*?[") && !store.HasGroup(root, group) {
+				problems = append(problems, "profile "+name+" sets "+group+" which doesn't exist")
+			}
+		}
+	}
+	return problems, nil
+}
+
+/* Renders every template of the store against data and returns the errors of the ones that fail
+The errors are path:line: msg, see store.TemplateError */
+func checkTemplates(root string, data store.TemplateData) ([]string, error) {
+	templates, err := store.Templates(root)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, path := range templates {
+		if err := store.RenderTemplate(path, data, ioutil.Discard); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems, nil
+}
+
+/* Returns the scripts of the groups' hooks and the config's [SCRIPTS] that are missing
+or not executable, a script left unexecutable may be a file that was never meant to run
+Symlinks to scripts that are gone count as missing, scripts marked with the skip
+marker are left out and nothing has to be executable on Windows */
+func checkScripts(root string) ([]string, error) {
+	// Like checkStore every group's scripts are checked
+	groups, err := store.ListGroups(root, store.GroupFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, group := range groups {
+		handles, err := hookHandles(root, group)
+		if err != nil {
+			problems = append(problems, strings.TrimPrefix(err.Error(), "Error: "))
+			continue
+		}
+		for _, handle := range handles {
+			for _, prefix := range []string{"set_", "unset_"} {
+				for _, script := range handle.Scripts(prefix) {
+					problems = append(problems, checkScript(filepath.Join(handle.Dir, script), group+"'s script")...)
+				}
+			}
+		}
+	}
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range conf.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// checkStore already reports the ones that don't exist
+		if _, err := os.Stat(conf.Scripts[name]); err == nil {
+			problems = append(problems, checkScript(conf.Scripts[name], "script "+name+" at")...)
+		}
+	}
+	return problems, nil
+}
+
+// Checks the script at path exists and is executable, what describes it in the problems
+func checkScript(path string, what string) []string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return []string{what + " " + path + " doesn't exist"}
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		return []string{what + " " + path + " isn't executable"}
+	}
+	return nil
+}
+
+// Checks a path from a group's .tuckr.json stays inside the group and exists
+func checkGroupPath(src string, group string, field string, rel string) []string {
+	clean := filepath.Clean(rel)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return []string{group + "'s " + field + " entry " + rel + " points outside of the group"}
+	}
+	if _, err := os.Stat(filepath.Join(src, clean)); err != nil {
+		return []string{group + "'s " + field + " entry " + rel + " doesn't exist"}
+	}
+	return nil
+}
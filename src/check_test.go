@@ -0,0 +1,129 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/setup"
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckReportsEveryDefect(t *testing.T) {
+	e := newTestEnv(t)
+	e.config(t, "[SCRIPTS]\nbootstrap = "+filepath.Join(e.dir, "bootstrap.sh")+"\n")
+	e.write(t, "Configs/bad name/.inputrc", "")
+	e.write(t, "Configs/broken/.tuckr.json", `{"targets": [`)
+	e.write(t, "Configs/escape/.tuckr.json", `{"actions": {"../../etc/passwd": ["cat {}"]}, "executable": ["bin/missing"]}`)
+	e.write(t, "Configs/escape/.profile", "")
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/neovim/.vimrc", "")
+	e.write(t, "Configs/neovim/.tuckr.json", `{"dependsOn": ["lsp"]}`)
+	problems, err := checkStore(e.store, e.home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`group "bad name" has an invalid name`,
+		"broken",
+		"escape's actions entry ../../etc/passwd points outside of the group",
+		"escape's executable entry bin/missing doesn't exist",
+		e.inHome(".vimrc") + " is linked by both",
+		"neovim depends on lsp which doesn't exist",
+		"script bootstrap points to " + filepath.Join(e.dir, "bootstrap.sh") + " which doesn't exist",
+	}
+	for _, problem := range want {
+		found := false
+		for _, p := range problems {
+			found = found || strings.Contains(p, problem)
+		}
+		if !found {
+			t.Errorf("%q wasn't reported in %q", problem, problems)
+		}
+	}
+	if len(problems) != len(want) {
+		t.Errorf("check reported %d problems, want %d: %q", len(problems), len(want), problems)
+	}
+
+	e.write(t, "Configs/broken/.tuckr.json", "{}")
+	for _, group := range []string{"bad name", "escape", "neovim"} {
+		if err := os.RemoveAll(filepath.Join(e.store, "Configs", group)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.config(t, "")
+	if problems, err := checkStore(e.store, e.home); err != nil || len(problems) != 0 {
+		t.Errorf("a clean store has problems %q %v", problems, err)
+	}
+}
+
+func TestCheckTemplatesReportsTheBrokenOnesWithTheirLine(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/zsh/.zshrc.tmpl", "export EDITOR=vim\n{{ if .OS }}\n")
+	e.write(t, "Configs/vim/.vimrc.tmpl", "\" {{ .Hostname }}\nset nu\n")
+	broken := filepath.Join(e.store, "Configs", "zsh", ".zshrc.tmpl")
+	problems, err := checkTemplates(e.store, store.CurrentTemplateData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], broken+":3: ") {
+		t.Errorf("check --templates found %q, want %s:3 reported", problems, broken)
+	}
+
+	// Set leaves rendering to whatever reads the template
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc.tmpl"), filepath.Join(e.store, "Configs", "vim", ".vimrc.tmpl"))
+}
+
+func TestCheckScriptsReportsMissingAndUnexecutableOnes(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	ok := e.write(t, "Hooks/vim/set_plugins.sh", "true\n")
+	notExecutable := e.write(t, "Hooks/vim/unset_plugins.sh", "true\n")
+	skipped := e.write(t, "Hooks/vim/set_later.sh", setup.SkipMarker+"\n")
+	gone := filepath.Join(e.store, "Hooks", "vim", "set_gone.sh")
+	tool := writeTestFile(t, filepath.Join(e.dir, "tools", "bootstrap.sh"), "true\n")
+	e.config(t, "[SCRIPTS]\nbootstrap = "+tool+"\n")
+	for _, path := range []string{ok, tool} {
+		if err := os.Chmod(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(e.dir, "nowhere.sh"), gone); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := checkScripts(e.store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"vim's script " + gone + " doesn't exist",
+		"vim's script " + notExecutable + " isn't executable",
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("check --scripts found %q, want %q", problems, want)
+	}
+	if strings.Contains(strings.Join(problems, "\n"), skipped) {
+		t.Errorf("the skipped script was checked: %q", problems)
+	}
+
+	if err := os.Chmod(tool, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := uncolored(captureOutput(t, func() {
+		if err := runCheck([]string{"--scripts"}); err == nil {
+			t.Error("check --scripts passed with broken scripts")
+		}
+	}))
+	for _, p := range append(want, "script bootstrap at "+tool+" isn't executable") {
+		if !strings.Contains(out, "Problem: "+p+"\n") {
+			t.Errorf("check --scripts didn't report %q:\n%s", p, out)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+/* Functions that have to run however the command ends, like shredding decrypted
+secrets that never got linked. main runs them before exiting, on errors and
+interrupts too, in the reverse order they were registered */
+var cleanups struct {
+	sync.Mutex
+	funcs []*func()
+}
+
+// Registers f to run when the command ends and returns a function that unregisters it
+func registerCleanup(f func()) func() {
+	cleanups.Lock()
+	defer cleanups.Unlock()
+	cleanups.funcs = append(cleanups.funcs, &f)
+	return func() {
+		cleanups.Lock()
+		defer cleanups.Unlock()
+		f = nil
+	}
+}
+
+// Runs the registered cleanups once each, the ones registered after it runs wait for the next run
+func runCleanups() {
+	cleanups.Lock()
+	funcs := cleanups.funcs
+	cleanups.funcs = nil
+	cleanups.Unlock()
+	for i := len(funcs) - 1; i >= 0; i-- {
+		cleanups.Lock()
+		f := *funcs[i]
+		cleanups.Unlock()
+		if f != nil {
+			f()
+		}
+	}
+}
+
+// Runs the cleanups when the command gets interrupted or terminated before exiting
+func cleanupOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		runCleanups()
+		os.Exit(130)
+	}()
+}
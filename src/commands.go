@@ -0,0 +1,706 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/bundle"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/setup"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* Handles the reset command
+All groups are unset, with --delete-store the store is deleted and cloned again
+and then all groups are set. It asks for confirmation unless --yes is passed */
+func runReset(args []string) error {
+	flags := flag.NewFlagSet("reset", flag.ExitOnError)
+	deleteStore := flags.Bool("delete-store", false, "delete the store and clone it again")
+	yes := flags.Bool("yes", false, "don't ask for confirmation")
+	showTimings := flags.Bool("timings", false, "print how long cloning, linking and running scripts took")
+	flags.Parse(args)
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	// The store gets deleted so it can't be whatever directory tuckr happens to run in
+	root, err := store.Root()
+	if *deleteStore {
+		root, err = store.ConfiguredRoot()
+	}
+	if err != nil {
+		return err
+	}
+	home, err := config.HomeDir()
+	if err != nil {
+		return err
+	}
+	question := "This will unset all groups and set them again"
+	if *deleteStore {
+		question = "This will unset all groups, delete " + root + ", clone it again and set all groups"
+	}
+	if !*yes && !confirm(os.Stdin, question+". Continue?") {
+		return errors.New("Error: Reset aborted")
+	}
+	var t *timings
+	if *showTimings {
+		t = newTimings(time.Now)
+	}
+	err = resetStore(root, home, conf.General, *deleteStore, setup.ExecRunner{}, t)
+	t.print()
+	return maintain(err, setup.ExecRunner{})
+}
+
+/* Unsets every group, optionally deletes and clones the store again and then sets every group
+How long each phase takes is collected into t unless it's nil. Before anything is unset
+the store is checked to be one that can be cloned again and that doesn't hold home */
+func resetStore(root string, home string, general config.General, deleteStore bool, runner setup.CommandRunner, t *timings) error {
+	if deleteStore {
+		general.DotfilesDest = root
+		if _, err := setup.CloneCommand(general); err != nil {
+			return err
+		}
+		if manage.IsWithin(home, root) {
+			return errors.New("Error: Refusing to delete " + root + " since " + home + " is in it")
+		}
+	}
+	// Every group gets unset, ones that are ignored or disabled now may still be linked from before
+	groups, err := store.ListGroups(root, store.GroupFilter{})
+	if err != nil {
+		return err
+	}
+	if err := unsetGroups(root, home, groups); err != nil {
+		return err
+	}
+	if deleteStore {
+		if err := os.RemoveAll(root); err != nil {
+			return err
+		}
+		stopCloning := t.start("cloning")
+		err := setup.CloneFiles(general, runner)
+		stopCloning()
+		if err != nil {
+			return err
+		}
+	}
+	filter, err := deployFilter(root)
+	if err != nil {
+		return err
+	}
+	groups, err = store.ListGroups(root, filter)
+	if err != nil {
+		return err
+	}
+	return setGroups(root, home, groups, setOptions{timings: t})
+}
+
+/* Asks a yes or no question reading the answer from in, anything but yes means no
+Passing the same bufio.Reader for every question keeps piped answers from getting lost */
+func confirm(in io.Reader, question string) bool {
+	fmt.Print(question, " [y/N]: ")
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+/* Runs the config's maintenance command with sh when err is nil and returns err
+It's opt-in and a failing maintenance command only gets reported, the command
+that ran before it already did its job */
+func maintain(err error, runner setup.CommandRunner) error {
+	if err != nil {
+		return err
+	}
+	conf, err := config.LoadConfig()
+	if err != nil || conf.General.Maintenance == "" {
+		return err
+	}
+	fmt.Println(aurora.Green("Running maintenance:"), conf.General.Maintenance)
+	if err := runner.Run("sh", "-c", conf.General.Maintenance); err != nil {
+		fmt.Println(aurora.Yellow("Warning:"), "maintenance command failed:", err)
+	}
+	return nil
+}
+
+// What groups --json reports about each group
+type groupInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Files       int      `json:"files"`
+	Targets     []string `json:"targets"`
+	Platforms   []string `json:"platforms"`
+	Tags        []string `json:"tags,omitempty"`
+	Deployed    bool     `json:"deployed"`
+}
+
+/* Handles the groups command by listing every group in the store
+With --json each group's file count, targets, platforms and whether it's fully
+deployed are printed as json instead */
+func runGroups(args []string) error {
+	flags := flag.NewFlagSet("groups", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the groups and their metadata as json")
+	print0 := flags.Bool("print0", false, "only print the names of the groups separated with NUL")
+	flags.Parse(args)
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	ignore, err := store.LoadStoreIgnore(root)
+	if err != nil {
+		return err
+	}
+	// The groups of every platform are listed, --json tells which ones they're for
+	groups, err := store.ListGroups(root, store.GroupFilter{Ignore: ignore})
+	if err != nil {
+		return err
+	}
+	if *print0 {
+		printPaths(groups, true)
+		return nil
+	}
+	if !*asJSON {
+		for _, group := range groups {
+			fmt.Println(describe(root, group))
+		}
+		return nil
+	}
+
+	home, err := config.HomeDir()
+	if err != nil {
+		return err
+	}
+	infos := []groupInfo{}
+	for _, group := range groups {
+		conf, err := store.LoadGroupConfig(root, group)
+		if err != nil {
+			return err
+		}
+		deployments, err := groupDeployments(root, group, home)
+		if err != nil {
+			return err
+		}
+		info := groupInfo{
+			Name:        group,
+			Description: store.Description(root, group),
+			Targets:     []string{},
+			Platforms:   conf.Platforms,
+			Tags:        conf.Tags,
+			Deployed:    true,
+		}
+		if info.Platforms == nil {
+			info.Platforms = []string{}
+		}
+		files := map[string]bool{}
+		for _, d := range deployments {
+			info.Targets = append(info.Targets, d.target)
+			links, err := groupLinks(root, group, d)
+			if err != nil {
+				return err
+			}
+			for _, l := range links {
+				files[l.Source] = true
+				if !l.IsLinked() {
+					info.Deployed = false
+				}
+			}
+		}
+		info.Files = len(files)
+		infos = append(infos, info)
+	}
+	data, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// Handles the update command by pulling the store and setting the groups that changed
+func runUpdate(args []string) error {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	showTimings := flags.Bool("timings", false, "print how long pulling, linking and running scripts took")
+	dryRun := flags.Bool("dry-run", false, "fetch and print the groups that would be set again without merging anything")
+	anyRemote := flags.Bool("any-remote", false, "update even if the store's origin isn't the config's dotfiles_repo")
+	flags.Parse(args)
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := config.HomeDir()
+	if err != nil {
+		return err
+	}
+	if !*anyRemote {
+		if err := checkOrigin(root, setup.ExecRunner{}); err != nil {
+			return err
+		}
+	}
+	if *dryRun {
+		return previewUpdate(root, setup.ExecRunner{})
+	}
+	var t *timings
+	if *showTimings {
+		t = newTimings(time.Now)
+	}
+	err = updateStore(root, home, setup.ExecRunner{}, t)
+	t.print()
+	return maintain(err, setup.ExecRunner{})
+}
+
+/* Checks the store's origin is the config's dotfiles_repo so update doesn't pull from the wrong repo
+Nothing is checked when dotfiles_repo isn't set */
+func checkOrigin(root string, runner setup.CommandRunner) error {
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	repo := conf.General.DotfilesRepo
+	if repo == "" {
+		return nil
+	}
+	origin, err := setup.OriginURL(root, runner)
+	if err != nil {
+		return err
+	}
+	if !setup.SameRemote(origin, repo) {
+		return errors.New("Error: The store's origin " + origin + " isn't the config's dotfiles_repo " + repo + ", pass --any-remote to update from it anyway")
+	}
+	return nil
+}
+
+/* Pulls the store and sets again only the groups whose files changed with the pull
+How long each phase takes is collected into t unless it's nil */
+func updateStore(root string, home string, runner setup.CommandRunner, t *timings) error {
+	before, err := setup.Head(root, runner)
+	if err != nil {
+		return err
+	}
+	stopPulling := t.start("pulling")
+	err = setup.Pull(root, runner)
+	stopPulling()
+	if err != nil {
+		return err
+	}
+	after, err := setup.Head(root, runner)
+	if err != nil {
+		return err
+	}
+	if before == after {
+		fmt.Println(aurora.Green("Already up to date"))
+		return nil
+	}
+	groups, err := changedGroups(root, before, after, runner)
+	if err != nil {
+		return err
+	}
+	return setGroups(root, home, groups, setOptions{timings: t})
+}
+
+/* Fetches the store and prints the groups an update would set again
+Nothing gets merged so the store and its links are left as they are, which also
+means groups the update removes are listed as well */
+func previewUpdate(root string, runner setup.CommandRunner) error {
+	if err := setup.Fetch(root, runner); err != nil {
+		return err
+	}
+	head, err := setup.Head(root, runner)
+	if err != nil {
+		return err
+	}
+	upstream, err := setup.Upstream(root, runner)
+	if err != nil {
+		return err
+	}
+	if head == upstream {
+		fmt.Println(aurora.Green("Already up to date"))
+		return nil
+	}
+	files, err := setup.ChangedFiles(root, head, upstream, runner)
+	if err != nil {
+		return err
+	}
+	groups := store.GroupsOf(files)
+	if len(groups) == 0 {
+		fmt.Println(aurora.Green("No groups would be set again"))
+		return nil
+	}
+	fmt.Println(aurora.Cyan("Would set again:"), strings.Join(groups, ", "))
+	return nil
+}
+
+// Returns the groups of the store whose files differ between two commits
+func changedGroups(root string, from string, to string, runner setup.CommandRunner) ([]string, error) {
+	files, err := setup.ChangedFiles(root, from, to, runner)
+	if err != nil {
+		return nil, err
+	}
+	var groups []string
+	for _, group := range store.GroupsOf(files) {
+		if !store.HasGroup(root, group) {
+			continue
+		}
+		fmt.Println(aurora.Cyan("Changed:"), group)
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+/* Handles the diff-store command by listing the files of each group that differ
+between two refs of the store, files outside of any group are listed last */
+func runDiffStore(args []string) error {
+	if len(args) != 2 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	return diffStore(root, args[0], args[1], setup.ExecRunner{})
+}
+
+// Prints the files that differ between two refs of the store grouped by their group
+func diffStore(root string, from string, to string, runner setup.CommandRunner) error {
+	files, err := setup.ChangedFiles(root, from, to, runner)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Println(aurora.Green("No differences between"), from, "and", to)
+		return nil
+	}
+	byGroup := map[string][]string{}
+	for _, file := range files {
+		group := store.GroupOf(file)
+		byGroup[group] = append(byGroup[group], file)
+	}
+	for _, group := range store.GroupsOf(files) {
+		fmt.Println(aurora.Cyan("Changed:"), group)
+		for _, file := range byGroup[group] {
+			fmt.Println("  " + file)
+		}
+	}
+	if other := byGroup[""]; len(other) > 0 {
+		fmt.Println(aurora.Cyan("Changed outside of groups:"))
+		for _, file := range other {
+			fmt.Println("  " + file)
+		}
+	}
+	return nil
+}
+
+// Handles the config diff-defaults and init subcommands
+func runConfig(args []string) error {
+	if len(args) > 0 && args[0] == "init" {
+		return runConfigInit(args[1:])
+	}
+	if len(args) != 1 || args[0] != "diff-defaults" {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	deviations := config.DiffDefaults(conf)
+	if len(deviations) == 0 {
+		fmt.Println(aurora.Green("The config only has default values"))
+		return nil
+	}
+	for _, d := range deviations {
+		fmt.Printf("[%s] %s = %s %s\n", d.Section, d.Key, d.Value, aurora.Cyan("(default: "+strconv.Quote(d.Default)+")"))
+	}
+	return nil
+}
+
+/* Handles the config init subcommand which writes a tuckr.conf out of the answers
+to a few prompts, an existing config is only replaced with --force */
+func runConfigInit(args []string) error {
+	flags := flag.NewFlagSet("config init", flag.ExitOnError)
+	force := flags.Bool("force", false, "replace the config if there's one already")
+	flags.Parse(args)
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil && !*force {
+		return errors.New("Error: " + path + " already exists, pass --force to replace it")
+	}
+	home, err := config.HomeDir()
+	if err != nil {
+		return err
+	}
+	conf, err := promptConfig(bufio.NewReader(os.Stdin), home)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := config.Write(f, conf); err != nil {
+		return err
+	}
+	fmt.Println(aurora.Green("Wrote config:"), path)
+	return f.Close()
+}
+
+/* Builds a config out of the answers read from in
+An empty answer takes the default shown in brackets and invalid answers are asked again */
+func promptConfig(in *bufio.Reader, home string) (config.Config, error) {
+	conf := config.Default()
+	var err error
+	if conf.General.DotfilesRepo, err = ask(in, "Dotfiles repo url", ""); err != nil {
+		return conf, err
+	}
+	dest, err := ask(in, "Where the store lives", filepath.Join(home, ".dotfiles"))
+	if err != nil {
+		return conf, err
+	}
+	if conf.General.DotfilesDest, err = config.ExpandPath(dest); err != nil {
+		return conf, err
+	}
+	for {
+		policy, err := ask(in, "What to do with targets taken by other files, skip, overwrite, backup or abort", "skip")
+		if err != nil {
+			return conf, err
+		}
+		if _, err := manage.PolicyResolver(policy); err != nil {
+			fmt.Println(aurora.Red("Invalid option:"), policy)
+			continue
+		}
+		if policy != "skip" {
+			conf.General.ConflictPolicy = policy
+		}
+		break
+	}
+	for {
+		format, err := ask(in, "How the store is laid out, tuckr, flat or stow", store.FormatTuckr)
+		if err != nil {
+			return conf, err
+		}
+		if conf.General.StoreFormat, err = store.ParseFormat(format); err != nil {
+			fmt.Println(aurora.Red("Invalid option:"), format)
+			continue
+		}
+		break
+	}
+	return conf, nil
+}
+
+/* Asks a question reading the answer from in, an empty answer or running out of
+input gives def back */
+func ask(in *bufio.Reader, question string, def string) (string, error) {
+	if def != "" {
+		question += " [" + def + "]"
+	}
+	fmt.Print(question, ": ")
+	answer, err := in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if err == io.EOF {
+		fmt.Println()
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+/* Handles the resolve command by printing where a file of a group gets linked to
+The file is relative to the group's directory, one path is printed per target */
+func runResolve(args []string) error {
+	flags := flag.NewFlagSet("resolve", flag.ExitOnError)
+	print0 := flags.Bool("print0", false, "separate the paths with NUL instead of newlines")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) != 2 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := config.HomeDir()
+	if err != nil {
+		return err
+	}
+	targets, err := resolveTarget(root, home, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	printPaths(targets, *print0)
+	return nil
+}
+
+// Returns the absolute paths a file of a group gets linked to without linking it
+func resolveTarget(root string, home string, group string, file string) ([]string, error) {
+	if !store.HasGroup(root, group) {
+		return nil, errors.New("Error: Group " + group + " does not exist")
+	}
+	deployments, err := groupDeployments(root, group, home)
+	if err != nil {
+		return nil, err
+	}
+	file = filepath.Clean(file)
+	var targets []string
+	for _, d := range deployments {
+		links, err := groupLinks(root, group, d)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range links {
+			rel, err := filepath.Rel(d.src, l.Source)
+			if err != nil {
+				return nil, err
+			}
+			if rel == file {
+				targets = append(targets, l.Target)
+			} else if strings.HasPrefix(file, rel+string(filepath.Separator)) {
+				// the file is inside of a directory that's linked whole
+				targets = append(targets, filepath.Join(l.Target, file[len(rel)+1:]))
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("Error: " + file + " is not a deployed file of " + group)
+	}
+	return targets, nil
+}
+
+/* Handles the disable and enable commands
+Disabling a group makes set skip it without touching the links it already has,
+enabling it makes set deploy it again */
+func runDisable(args []string, disable bool) error {
+	if len(args) == 0 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	groups, err := selectGroups(root, args, store.GroupFilter{})
+	if err != nil {
+		return err
+	}
+	disabled, err := state.LoadDisabled()
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if disable {
+			disabled[group] = time.Now()
+			fmt.Println(aurora.Yellow("Disabled:"), group)
+		} else {
+			delete(disabled, group)
+			fmt.Println(aurora.Green("Enabled:"), group)
+		}
+	}
+	return disabled.Save()
+}
+
+// Handles the scripts run subcommand which runs a single script of a group
+func runScripts(args []string) error {
+	if len(args) != 3 || args[0] != "run" {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	group, script := args[1], args[2]
+	handles, err := hookHandles(root, group)
+	if err != nil {
+		return err
+	}
+	if len(handles) == 0 {
+		return errors.New("Error: Group " + group + " has no scripts")
+	}
+	return runHookScript(root, handles, script)
+}
+
+/* Runs a single script out of a group's hooks
+The script is relative to the group's hooks so a platform's script is named like
+linux/set_up.sh, a bare name is looked for in the shared and then the platform's scripts */
+func runHookScript(root string, handles []setup.SetupHandle, script string) error {
+	dir, name := filepath.Split(filepath.FromSlash(script))
+	for i, handle := range handles {
+		isPlatform := i > 0 && filepath.Clean(dir) == filepath.Base(handle.Dir)
+		if (dir == "" || isPlatform) && handle.HasScript(name) {
+			if err := flagUnlistedScripts(root, handle.Dir, []string{name}); err != nil {
+				return err
+			}
+			return handle.RunScript(name)
+		}
+	}
+	return errors.New("Error: Script " + script + " not found")
+}
+
+// Handles the bundle export and bundle import subcommands
+func runBundle(args []string) error {
+	if len(args) < 2 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	path, err := config.ExpandPath(args[1])
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "export":
+		manifest, err := bundle.Export(root, path)
+		if err != nil {
+			return err
+		}
+		fmt.Println(aurora.Green("Exported:"), len(manifest.Groups), "groups and", len(manifest.Files), "files to", args[1])
+	case "import":
+		if len(args) > 2 {
+			if root, err = config.ExpandPath(args[2]); err != nil {
+				return err
+			}
+		}
+		manifest, err := bundle.Import(path, root)
+		if err != nil {
+			return err
+		}
+		fmt.Println(aurora.Green("Imported:"), len(manifest.Groups), "groups and", len(manifest.Files), "files into", root)
+	default:
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	return nil
+}
+
+/* Prints each path on its own line, or terminated by NUL with print0 so they
+can be piped to xargs -0 even when they hold spaces or newlines */
+func printPaths(paths []string, print0 bool) {
+	for _, path := range paths {
+		if print0 {
+			fmt.Print(path, "\x00")
+		} else {
+			fmt.Println(path)
+		}
+	}
+}
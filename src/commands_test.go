@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/raphgl/tuckr/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResetUnsetsClonesAndSetsInOrder(t *testing.T) {
+	e := newTestEnv(t)
+	old := e.write(t, "Configs/vim/.vimrc", "old")
+	if err := setGroups(e.store, e.home, []string{"vim"}, setOptions{quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	assertLinked(t, e.inHome(".vimrc"), old)
+
+	var steps []string
+	runner := &stubRunner{run: func(name string, args ...string) error {
+		// By the time the store is cloned its links are gone and so is the store
+		if _, err := os.Lstat(e.inHome(".vimrc")); os.IsNotExist(err) {
+			steps = append(steps, "unset")
+		}
+		if _, err := os.Stat(e.store); os.IsNotExist(err) {
+			steps = append(steps, "delete")
+		}
+		steps = append(steps, "clone")
+		e.write(t, "Configs/zsh/.zshrc", "new")
+		return nil
+	}}
+	general := config.General{DotfilesRepo: "https://example.com/dotfiles.git"}
+	captureOutput(t, func() {
+		if err := resetStore(e.store, e.home, general, true, runner, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := []string{"unset", "delete", "clone"}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("reset went through %v before setting, want %v", steps, want)
+	}
+	if want := []string{"git clone https://example.com/dotfiles.git " + e.store}; !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("ran %v, want %v", runner.calls, want)
+	}
+	assertMissing(t, e.inHome(".vimrc"))
+	assertLinked(t, e.inHome(".zshrc"), filepath.Join(e.store, "Configs", "zsh", ".zshrc"))
+}
+
+func TestResetKeepsTheStoreWhenItCantBeClonedAgain(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	if err := setGroups(e.store, e.home, []string{"vim"}, setOptions{quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	runner := &stubRunner{}
+	check := func(name string, root string, home string, general config.General) {
+		t.Helper()
+		captureOutput(t, func() {
+			if err := resetStore(root, home, general, true, runner, nil); err == nil {
+				t.Errorf("resetting %s succeeded", name)
+			}
+		})
+		if _, err := os.Stat(root); err != nil {
+			t.Errorf("resetting %s deleted the store: %v", name, err)
+		}
+		assertLinked(t, e.inHome(".vimrc"), vimrc)
+		if len(runner.calls) != 0 {
+			t.Errorf("resetting %s ran %v", name, runner.calls)
+		}
+	}
+	check("without a dotfiles_repo", e.store, e.home, config.General{})
+	check("with a clone command that can't be split", e.store, e.home, config.General{DotfilesRepo: "https://example.com/dotfiles.git", CloneDotfilesCmd: "git 'clone"})
+	repo := config.General{DotfilesRepo: "https://example.com/dotfiles.git"}
+	check("a store that is home", e.home, e.home, repo)
+	check("a store that holds home", e.dir, e.home, repo)
+
+	// Without a store set the directory reset runs in isn't taken for it
+	os.Unsetenv("TUCKR_STORE")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.Chdir(e.store); err != nil {
+		t.Fatal(err)
+	}
+	captureOutput(t, func() {
+		if err := runReset([]string{"--delete-store", "--yes"}); err == nil {
+			t.Error("resetting with no store set succeeded")
+		}
+	})
+	if _, err := os.Stat(e.store); err != nil {
+		t.Errorf("resetting with no store set deleted the current directory: %v", err)
+	}
+}
+
+func TestGroupsJSONReportsMetadata(t *testing.T) {
+	e := newTestEnv(t)
+	srv := filepath.Join(e.dir, "srv")
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/vim/.config/nvim/init.vim", "")
+	e.write(t, "Configs/vim/README", "Vim settings")
+	e.write(t, "Configs/vim/.tuckr.json", `{"platforms": ["linux"], "tags": ["editor"]}`)
+	e.write(t, "Configs/web/site.conf", "")
+	e.write(t, "Configs/web/.tuckr.json", `{"targets": ["`+srv+`"]}`)
+	if err := setGroups(e.store, e.home, []string{"web"}, setOptions{quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := captureOutput(t, func() {
+		if err := runGroups([]string{"--json"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	var fields []map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &fields); err != nil {
+		t.Fatalf("groups --json printed invalid json: %v\n%s", err, out)
+	}
+	for _, group := range fields {
+		for _, key := range []string{"name", "files", "targets", "platforms", "deployed"} {
+			if _, ok := group[key]; !ok {
+				t.Errorf("%v is missing %q", group["name"], key)
+			}
+		}
+	}
+	var infos []groupInfo
+	json.Unmarshal([]byte(out), &infos)
+	want := []groupInfo{
+		{Name: "vim", Description: "Vim settings", Files: 2, Targets: []string{e.home}, Platforms: []string{"linux"}, Tags: []string{"editor"}},
+		{Name: "web", Files: 1, Targets: []string{srv}, Platforms: []string{}, Deployed: true},
+	}
+	if !reflect.DeepEqual(infos, want) {
+		t.Errorf("groups --json reported %+v, want %+v", infos, want)
+	}
+}
+
+func TestScriptsRunOnlyRunsTheNamedScript(t *testing.T) {
+	e := newTestEnv(t)
+	for _, name := range []string{"set_a.sh", "set_b.sh", "unset_a.sh"} {
+		e.write(t, "Hooks/vim/"+name, "touch "+filepath.Join(e.dir, name)+"\n")
+	}
+	captureOutput(t, func() {
+		if err := runScripts([]string{"run", "vim", "set_a.sh"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if _, err := os.Stat(filepath.Join(e.dir, "set_a.sh")); err != nil {
+		t.Errorf("the named script didn't run: %v", err)
+	}
+	assertMissing(t, filepath.Join(e.dir, "set_b.sh"))
+	assertMissing(t, filepath.Join(e.dir, "unset_a.sh"))
+
+	if err := runScripts([]string{"run", "vim", "set_c.sh"}); err == nil {
+		t.Error("running a script the group doesn't have succeeded")
+	}
+}
+
+func TestUpdateOnlySetsTheGroupsThatChanged(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	head := "before"
+	runner := &stubRunner{
+		run: func(name string, args ...string) error {
+			head = "after"
+			return nil
+		},
+		output: func(args ...string) ([]byte, error) {
+			switch strings.Join(args[3:], " ") {
+			case "rev-parse HEAD":
+				return []byte(head + "\n"), nil
+			case "diff --name-only -z before..after":
+				return []byte("Configs/vim/.vimrc\x00"), nil
+			}
+			return nil, errors.New("unexpected command " + strings.Join(args, " "))
+		},
+	}
+	captureOutput(t, func() {
+		if err := updateStore(e.store, e.home, runner, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertMissing(t, e.inHome(".zshrc"))
+	if runner.calls[1] != "git -C "+e.store+" pull" {
+		t.Errorf("ran %v, want the store pulled after reading its commit", runner.calls)
+	}
+}
+
+func TestResolvePrintsTheTargets(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/vim/.config/nvim/init.vim", "")
+	e.write(t, "Configs/vim/.vim/plugins/surround.vim", "")
+	e.write(t, "Configs/vim/links.map", "vimrc.local .vimrc.local\n")
+	e.write(t, "Configs/vim/vimrc.local", "")
+	e.write(t, "Configs/vim/.tuckr.json", `{"linkAsDirectory": [".vim/plugins"]}`)
+	e.write(t, "Bin/vim/vimdiff-all", "")
+	tests := map[string]string{
+		".vimrc":                    e.inHome(".vimrc"),
+		".config/nvim/init.vim":     e.inHome(".config/nvim/init.vim"),
+		".vim/plugins/surround.vim": e.inHome(".vim/plugins/surround.vim"),
+		"vimrc.local":               e.inHome(".vimrc.local"),
+		"vimdiff-all":               e.inHome(".local/bin/vimdiff-all"),
+	}
+	for file, want := range tests {
+		out := captureOutput(t, func() {
+			if err := runResolve([]string{"vim", file}); err != nil {
+				t.Error(err)
+			}
+		})
+		if out != want+"\n" {
+			t.Errorf("resolve vim %s printed %q, want %q", file, out, want)
+		}
+	}
+	for _, file := range []string{"README", "links.map", "missing"} {
+		if _, err := resolveTarget(e.store, e.home, "vim", file); err == nil {
+			t.Errorf("%s resolved to a target", file)
+		}
+	}
+}
+
+func TestDisabledGroupsAreSkippedButKeepTheirLinks(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	zshrc := e.write(t, "Configs/zsh/.zshrc", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := runDisable([]string{"vim"}, true); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// A new file of the disabled group shows whether set touched it
+	e.write(t, "Configs/vim/.exrc", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"*"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertMissing(t, e.inHome(".exrc"))
+	assertLinked(t, e.inHome(".zshrc"), zshrc)
+
+	captureOutput(t, func() {
+		if err := runDisable([]string{"vim"}, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := runSet([]string{"*"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".exrc"), filepath.Join(e.store, "Configs", "vim", ".exrc"))
+}
+
+func TestDiffStoreListsTheChangedFilesByGroup(t *testing.T) {
+	e := newTestEnv(t)
+	runner := &stubRunner{output: func(args ...string) ([]byte, error) {
+		if got := strings.Join(args, " "); got != "git -C "+e.store+" diff --name-only -z main..next" {
+			return nil, errors.New("unexpected command " + got)
+		}
+		return []byte("Configs/zsh/.zshrc\x00README.md\x00Configs/vim/.vimrc\x00Hooks/vim/set_plugins.sh\x00"), nil
+	}}
+	out := captureOutput(t, func() {
+		if err := diffStore(e.store, "main", "next", runner); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, want := range []string{"vim", "  Configs/vim/.vimrc", "  Hooks/vim/set_plugins.sh", "zsh", "  Configs/zsh/.zshrc", "outside of groups", "  README.md"} {
+		i := strings.Index(out, want)
+		if i < 0 {
+			t.Errorf("diff-store didn't list %q in\n%s", want, out)
+			continue
+		}
+		// Everything is listed in the order it's wanted
+		out = out[i+len(want):]
+	}
+
+	runner.output = func(args ...string) ([]byte, error) { return nil, nil }
+	out = captureOutput(t, func() {
+		if err := diffStore(e.store, "main", "main", runner); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, "No differences between") {
+		t.Errorf("diff-store of a ref against itself printed %q", out)
+	}
+}
+
+func TestMaintenanceOnlyRunsAfterSuccess(t *testing.T) {
+	e := newTestEnv(t)
+	runner := &stubRunner{}
+	failed := errors.New("Error: set failed")
+	captureOutput(t, func() {
+		if err := maintain(failed, runner); err != failed {
+			t.Errorf("maintain returned %v, want the command's own error", err)
+		}
+		if err := maintain(nil, runner); err != nil {
+			t.Error(err)
+		}
+	})
+	if len(runner.calls) != 0 {
+		t.Errorf("ran %v without a maintenance command configured", runner.calls)
+	}
+
+	e.config(t, "[GENERAL]\nmaintenance = git -C ~/.dotfiles add -A && git commit -m sync\n")
+	captureOutput(t, func() {
+		maintain(failed, runner)
+	})
+	if len(runner.calls) != 0 {
+		t.Errorf("ran %v after a failure", runner.calls)
+	}
+	runner.run = func(name string, args ...string) error { return errors.New("exit status 1") }
+	out := captureOutput(t, func() {
+		if err := maintain(nil, runner); err != nil {
+			t.Errorf("a failing maintenance command failed the command with %v", err)
+		}
+	})
+	if want := []string{"sh -c git -C ~/.dotfiles add -A && git commit -m sync"}; !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("ran %v after success, want %v", runner.calls, want)
+	}
+	if !strings.Contains(out, "maintenance command failed") {
+		t.Errorf("the failure wasn't reported: %q", out)
+	}
+}
+
+func TestDryRunsOnlyRunGitCommandsThatLeaveTheStoreAlone(t *testing.T) {
+	e := newTestEnv(t)
+	runner := &stubRunner{output: func(args ...string) ([]byte, error) {
+		switch strings.Join(args[3:], " ") {
+		case "rev-parse HEAD":
+			return []byte("before\n"), nil
+		case "rev-parse @{upstream}":
+			return []byte("after\n"), nil
+		case "diff --name-only -z before..after":
+			return []byte("Configs/vim/.vimrc\x00"), nil
+		}
+		return nil, errors.New("unexpected command " + strings.Join(args, " "))
+	}}
+	out := captureOutput(t, func() {
+		if err := previewUpdate(e.store, runner); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, "Would set again:") || !strings.HasSuffix(out, " vim\n") {
+		t.Errorf("update --dry-run printed %q, want vim listed", out)
+	}
+	for _, call := range runner.calls {
+		for _, mutating := range []string{" pull", " merge", " reset", " checkout", " clone"} {
+			if strings.Contains(call, mutating) {
+				t.Errorf("update --dry-run ran %q", call)
+			}
+		}
+	}
+	if runner.calls[0] != "git -C "+e.store+" fetch" {
+		t.Errorf("update --dry-run ran %v, want it to fetch first", runner.calls)
+	}
+
+	out = captureOutput(t, func() {
+		if err := runSet([]string{"--from", "https://example.com/dotfiles.git", "--dry-run", "vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, "Would clone:") || !strings.Contains(out, " git clone https://example.com/dotfiles.git ") {
+		t.Errorf("set --from --dry-run printed %q, want the clone command", out)
+	}
+	if entries, err := ioutil.ReadDir(e.home); err != nil || len(entries) != 0 {
+		t.Errorf("set --from --dry-run left %v in the home (%v)", entries, err)
+	}
+}
+
+func TestConfigInitWritesAConfigThatLoadsBack(t *testing.T) {
+	e := newTestEnv(t)
+	answers := "https://example.com/dotfiles.git\n" + e.dir + "/dots\nsometimes\nbackup\n\n"
+	var conf config.Config
+	captureOutput(t, func() {
+		var err error
+		if conf, err = promptConfig(bufio.NewReader(strings.NewReader(answers)), e.home); err != nil {
+			t.Fatal(err)
+		}
+	})
+	f, err := os.Create(os.Getenv("TUCKR_CONFIG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Write(f, conf); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	loaded, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config.Default().General
+	want.DotfilesRepo = "https://example.com/dotfiles.git"
+	want.DotfilesDest = filepath.Join(e.dir, "dots")
+	want.ConflictPolicy = "backup"
+	if !reflect.DeepEqual(loaded.General, want) {
+		t.Errorf("the written config loaded as %+v, want %+v", loaded.General, want)
+	}
+
+	// Running out of answers takes the defaults
+	captureOutput(t, func() {
+		if conf, err = promptConfig(bufio.NewReader(strings.NewReader("")), e.home); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if conf.General.DotfilesDest != filepath.Join(e.home, ".dotfiles") || conf.General.ConflictPolicy != "" || conf.General.StoreFormat != "tuckr" {
+		t.Errorf("the defaults were %+v", conf.General)
+	}
+}
+
+func TestUpdateRefusesAnOriginThatIsntTheConfigsRepo(t *testing.T) {
+	e := newTestEnv(t)
+	origin := "git@github.com:someone/dotfiles.git"
+	runner := &stubRunner{output: func(args ...string) ([]byte, error) {
+		if got := strings.Join(args, " "); got != "git -C "+e.store+" remote get-url origin" {
+			return nil, errors.New("unexpected command " + got)
+		}
+		return []byte(origin + "\n"), nil
+	}}
+	// Nothing to compare against without a dotfiles_repo
+	if err := checkOrigin(e.store, runner); err != nil || len(runner.calls) != 0 {
+		t.Errorf("without dotfiles_repo the check failed with %v after running %v", err, runner.calls)
+	}
+
+	e.config(t, "[GENERAL]\ndotfiles_repo = https://github.com/me/dotfiles\n")
+	if err := checkOrigin(e.store, runner); err == nil || !strings.Contains(err.Error(), "--any-remote") {
+		t.Errorf("a mismatched origin failed the check with %v", err)
+	}
+	origin = "git@github.com:me/dotfiles.git"
+	if err := checkOrigin(e.store, runner); err != nil {
+		t.Errorf("the same repo over ssh failed the check with %v", err)
+	}
+}
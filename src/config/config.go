@@ -0,0 +1,416 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+/* Settings under the [GENERAL] section
+With ReadOnlyStore set files are copied into a per user cache and linked from
+there so that the store is never written to, the history goes into the state dir
+With LinkRegistry set every link set creates is recorded so links into where the
+store was before it moved are still known to be tuckr's
+ConflictPolicy is how set resolves targets taken by other files when it can't
+prompt for it: skip, overwrite, backup or abort
+Maintenance is a shell command run after every command that changed something
+succeeds, like one that commits the store
+StoreFormat is the directory convention the store follows: tuckr, flat or stow
+EscalationCmd is what set --escalate runs the commands for privileged targets through
+DecryptCmd prints the plaintext of the .age file appended to it, it defaults to age
+with the identity in $XDG_CONFIG_HOME/age/keys.txt
+FSConcurrency is how many links get created at once, lower it for slow filesystems
+SuggestPaths are the globs, relative to home, where suggest looks for configs no group manages
+Umask is the octal umask the directories created for links and copies get
+AllowedScripts are globs, relative to the store, of the scripts known to be safe,
+the others get flagged before they run. ScriptCommands are the only commands
+scripts find through $PATH when there are any
+SensitiveGroups are globs of the groups audit-perms checks, SensitivePerms is the most
+permissive octal mode their files can have, their directories can also be searched */
+type General struct {
+	CloneDotfilesCmd string
+	DotfilesRepo     string
+	DotfilesDest     string
+	ReadOnlyStore    bool
+	LinkRegistry     bool
+	ConflictPolicy   string
+	Maintenance      string
+	StoreFormat      string
+	EscalationCmd    string
+	DecryptCmd       string
+	FSConcurrency    int
+	Umask            int
+	SuggestPaths     []string
+	AllowedScripts   []string
+	ScriptCommands   []string
+	SensitiveGroups  []string
+	SensitivePerms   int
+}
+
+// Settings under the [PACKAGES] section
+type Packages struct {
+	PkgInstallCmd string
+	PkgList       string
+	PipList       string
+	NpmList       string
+	YarnList      string
+}
+
+/* Settings under a [PROFILE <name>] section
+A profile names a whole role, the groups to set, the packages to install with
+pkg_install_cmd and the [SCRIPTS] to run, so apply can provision it at once */
+type Profile struct {
+	Groups   []string
+	Packages []string
+	Scripts  []string
+}
+
+/* Contents of a tuckr.conf
+Scripts maps the name of each script under [SCRIPTS] to its path and Targets
+maps the lowercase name of a store folder, like configs or bin, under [TARGETS]
+to the directory its groups get deployed into. Profiles are keyed by their name */
+type Config struct {
+	General  General
+	Packages Packages
+	Scripts  map[string]string
+	Targets  map[string]string
+	Profiles map[string]Profile
+	// Whether the config was written in the legacy flat format without sections
+	legacy bool
+	// The [TARGETS] as they're written, for TargetsIn
+	rawTargets map[string]string
+}
+
+/* Sections that the keys of a legacy flat config belong to
+Configs from before sections were introduced had every key at the top level */
+var legacySections = map[string]string{
+	"clone_dotfiles_cmd": "GENERAL",
+	"dotfiles_repo":      "GENERAL",
+	"dotfiles_dest":      "GENERAL",
+	"conflict_policy":    "GENERAL",
+	"maintenance":        "GENERAL",
+	"store_format":       "GENERAL",
+	"escalation_cmd":     "GENERAL",
+	"decrypt_cmd":        "GENERAL",
+	"fs_concurrency":     "GENERAL",
+	"umask":              "GENERAL",
+	"suggest_paths":      "GENERAL",
+	"allowed_scripts":    "GENERAL",
+	"script_commands":    "GENERAL",
+	"sensitive_groups":   "GENERAL",
+	"sensitive_perms":    "GENERAL",
+	"read_only_store":    "GENERAL",
+	"link_registry":      "GENERAL",
+	"pkg_install_cmd":    "PACKAGES",
+	"pkg_list":           "PACKAGES",
+	"pip_list":           "PACKAGES",
+	"npm_list":           "PACKAGES",
+	"yarn_list":          "PACKAGES",
+}
+
+// Makes sure the legacy format warning is only printed once per run
+var legacyWarning sync.Once
+
+// Returns the config with the values tuckr uses when they're not set
+func Default() Config {
+	return Config{
+		General: General{
+			CloneDotfilesCmd: "git clone",
+			StoreFormat:      "tuckr",
+			EscalationCmd:    "sudo",
+			FSConcurrency:    4,
+			Umask:            0022,
+			SuggestPaths:     []string{".bashrc", ".bash_profile", ".zshrc", ".profile", ".vimrc", ".gitconfig", ".tmux.conf", ".inputrc", ".config/*"},
+			SensitiveGroups:  []string{"ssh*", "gpg*", "gnupg*"},
+			SensitivePerms:   0600,
+		},
+		Scripts:  map[string]string{},
+		Targets:  map[string]string{},
+		Profiles: map[string]Profile{},
+	}
+}
+
+/* Returns the directory files are copied into when the store is read-only
+It's tuckr/store inside the user's cache dir */
+func CacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tuckr", "store"), nil
+}
+
+/* Returns the path to the config file
+$TUCKR_CONFIG takes precedence, otherwise it's tuckr/tuckr.conf in the user's config dir */
+func Path() (string, error) {
+	if path := os.Getenv("TUCKR_CONFIG"); path != "" {
+		return ExpandPath(path)
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tuckr", "tuckr.conf"), nil
+}
+
+/* Returns the value of a key in the user's git config, an empty string when it's not set
+It's a variable so it can be swapped out without depending on git being installed */
+var gitConfig = func(key string) string {
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+/* Loads the config file from Path
+A missing config file is not an error, the defaults are returned instead
+A legacy flat config is still loaded but a warning to migrate it is printed
+When no dotfiles_dest is set, dotfiles.dest from the git config is used instead */
+func LoadConfig() (Config, error) {
+	config, err := loadConfigFile()
+	if err == nil && config.General.DotfilesDest == "" {
+		if dest := gitConfig("dotfiles.dest"); dest != "" {
+			config.General.DotfilesDest, err = ExpandPath(dest)
+		}
+	}
+	return config, err
+}
+
+// Loads the config file from Path without any fallbacks
+func loadConfigFile() (Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Default(), err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Default(), nil
+		}
+		return Default(), err
+	}
+	defer f.Close()
+	config, err := Parse(f)
+	if err == nil && config.legacy {
+		legacyWarning.Do(func() {
+			fmt.Println(aurora.Yellow("Warning:"), path, "uses the deprecated flat format, move its keys under [GENERAL] and [PACKAGES]")
+		})
+	}
+	return config, err
+}
+
+/* Parses a config in the ini format used by tuckr.conf
+Values have their environment variables expanded and paths also get ~ expanded
+and are made absolute
+Keys outside of any section are taken as a legacy flat config and get assigned to
+the section they belong to */
+func Parse(r io.Reader) (Config, error) {
+	config := Default()
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			// profile names keep their case, only the word PROFILE doesn't matter
+			if words := strings.Fields(section); len(words) == 2 && strings.ToUpper(words[0]) == "PROFILE" {
+				section = "PROFILE " + words[1]
+			} else {
+				section = strings.ToUpper(section)
+			}
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return config, errors.New("Error: Invalid line " + strconv.Itoa(lineNo) + " in config: " + line)
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		keySection := section
+		if keySection == "" {
+			if legacySection, ok := legacySections[key]; ok {
+				keySection = legacySection
+				config.legacy = true
+			}
+		}
+		if err := config.set(keySection, key, value); err != nil {
+			return config, errors.New("Error: " + err.Error() + " on line " + strconv.Itoa(lineNo) + " of config")
+		}
+	}
+	return config, scanner.Err()
+}
+
+// Assigns value to the field that key refers to in section
+func (c *Config) set(section string, key string, value string) error {
+	if strings.HasPrefix(section, "PROFILE ") {
+		return c.setProfile(strings.TrimPrefix(section, "PROFILE "), key, value)
+	}
+	var field *string
+	isPath := true
+	switch section {
+	case "GENERAL":
+		switch key {
+		case "clone_dotfiles_cmd":
+			field = &c.General.CloneDotfilesCmd
+			isPath = false
+		case "dotfiles_repo":
+			field = &c.General.DotfilesRepo
+			isPath = false
+		case "dotfiles_dest":
+			field = &c.General.DotfilesDest
+		case "conflict_policy":
+			field = &c.General.ConflictPolicy
+			isPath = false
+		case "maintenance":
+			field = &c.General.Maintenance
+			isPath = false
+		case "store_format":
+			field = &c.General.StoreFormat
+			isPath = false
+		case "escalation_cmd":
+			field = &c.General.EscalationCmd
+			isPath = false
+		case "decrypt_cmd":
+			field = &c.General.DecryptCmd
+			isPath = false
+		case "read_only_store":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.New("Invalid boolean " + value + " for " + key)
+			}
+			c.General.ReadOnlyStore = b
+			return nil
+		case "link_registry":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.New("Invalid boolean " + value + " for " + key)
+			}
+			c.General.LinkRegistry = b
+			return nil
+		case "fs_concurrency":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return errors.New("Invalid number " + value + " for " + key + ", expected at least 1")
+			}
+			c.General.FSConcurrency = n
+			return nil
+		case "umask":
+			n, err := strconv.ParseUint(value, 8, 32)
+			if err != nil || n > 0777 {
+				return errors.New("Invalid umask " + value + ", expected an octal number like 022")
+			}
+			c.General.Umask = int(n)
+			return nil
+		case "suggest_paths":
+			c.General.SuggestPaths = splitList(value)
+			return nil
+		case "sensitive_groups":
+			c.General.SensitiveGroups = splitList(value)
+			return nil
+		case "sensitive_perms":
+			n, err := strconv.ParseUint(value, 8, 32)
+			if err != nil || n > 0777 {
+				return errors.New("Invalid mode " + value + " for " + key + ", expected an octal number like 600")
+			}
+			c.General.SensitivePerms = int(n)
+			return nil
+		case "allowed_scripts":
+			c.General.AllowedScripts = splitList(value)
+			return nil
+		case "script_commands":
+			c.General.ScriptCommands = splitList(value)
+			return nil
+		}
+	case "PACKAGES":
+		switch key {
+		case "pkg_install_cmd":
+			field = &c.Packages.PkgInstallCmd
+			isPath = false
+		case "pkg_list":
+			field = &c.Packages.PkgList
+		case "pip_list":
+			field = &c.Packages.PipList
+		case "npm_list":
+			field = &c.Packages.NpmList
+		case "yarn_list":
+			field = &c.Packages.YarnList
+		}
+	case "SCRIPTS":
+		path, err := ExpandPath(value)
+		c.Scripts[key] = path
+		return err
+	case "TARGETS":
+		if c.rawTargets == nil {
+			c.rawTargets = map[string]string{}
+		}
+		c.rawTargets[key] = value
+		path, err := ExpandPath(value)
+		c.Targets[key] = path
+		return err
+	}
+	if field == nil {
+		return errors.New("Unknown key " + key + " in section [" + section + "]")
+	}
+	if !isPath {
+		*field = os.ExpandEnv(value)
+		return nil
+	}
+	path, err := ExpandPath(value)
+	*field = path
+	return err
+}
+
+/* Returns the [TARGETS] with a leading ~ expanded to home instead, see ExpandPathIn
+Deploying into another user's home takes these over Targets */
+func (c Config) TargetsIn(home string) (map[string]string, error) {
+	targets := map[string]string{}
+	for key, value := range c.Targets {
+		targets[key] = value
+	}
+	for key, value := range c.rawTargets {
+		path, err := ExpandPathIn(value, home)
+		if err != nil {
+			return nil, err
+		}
+		targets[key] = path
+	}
+	return targets, nil
+}
+
+// Assigns value, a list separated by commas or spaces, to the field of the profile that key refers to
+func (c *Config) setProfile(name string, key string, value string) error {
+	profile := c.Profiles[name]
+	list := splitList(value)
+	switch key {
+	case "groups":
+		profile.Groups = list
+	case "packages":
+		profile.Packages = list
+	case "scripts":
+		profile.Scripts = list
+	default:
+		return errors.New("Unknown key " + key + " in section [PROFILE " + name + "]")
+	}
+	c.Profiles[name] = profile
+	return nil
+}
+
+// Splits a list value separated by commas or spaces after expanding its $VARS
+func splitList(value string) []string {
+	return strings.FieldsFunc(os.ExpandEnv(value), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
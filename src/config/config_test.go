@@ -0,0 +1,113 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Runs f and returns what it printed to stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	os.Stdout = stdout
+	return <-done
+}
+
+// Makes gitConfig read from values for the rest of the test
+func stubGitConfig(t *testing.T, values map[string]string) {
+	t.Helper()
+	readGitConfig := gitConfig
+	gitConfig = func(key string) string { return values[key] }
+	t.Cleanup(func() { gitConfig = readGitConfig })
+}
+
+func TestLoadConfigMapsALegacyFlatConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tuckr-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tuckr.conf")
+	flat := "dotfiles_repo = https://example.com/dotfiles.git\ndotfiles_dest = " + dir + "/dotfiles\nread_only_store = true\npkg_list = " + dir + "/pkgs\n"
+	if err := ioutil.WriteFile(path, []byte(flat), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setEnv(t, "TUCKR_CONFIG", path)
+	stubGitConfig(t, nil)
+	legacyWarning = sync.Once{}
+
+	var conf Config
+	out := captureStdout(t, func() {
+		for i := 0; i < 2; i++ {
+			if conf, err = LoadConfig(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	if conf.General.DotfilesRepo != "https://example.com/dotfiles.git" || conf.General.DotfilesDest != filepath.Join(dir, "dotfiles") || !conf.General.ReadOnlyStore {
+		t.Errorf("the flat config's general keys loaded as %+v", conf.General)
+	}
+	if conf.Packages.PkgList != filepath.Join(dir, "pkgs") {
+		t.Errorf("the flat config's package keys loaded as %+v", conf.Packages)
+	}
+	if strings.Count(out, "deprecated flat format") != 1 || !strings.Contains(out, path) {
+		t.Errorf("the deprecation warning wasn't printed exactly once:\n%s", out)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("[GENERAL]\ndotfiles_repo = https://example.com/dotfiles.git\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	legacyWarning = sync.Once{}
+	out = captureStdout(t, func() {
+		if _, err := LoadConfig(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if out != "" {
+		t.Errorf("a sectioned config printed %q", out)
+	}
+}
+
+func TestLoadConfigFallsBackToGitsDotfilesDest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tuckr-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tuckr.conf")
+	setEnv(t, "TUCKR_CONFIG", path)
+	setEnv(t, "HOME", dir)
+	stubGitConfig(t, map[string]string{"dotfiles.dest": "~/src/dotfiles"})
+
+	// Without a config file at all the git config is still read
+	conf, err := LoadConfig()
+	if err != nil || conf.General.DotfilesDest != filepath.Join(dir, "src", "dotfiles") {
+		t.Errorf("without a config the store is at %q %v, want git's dotfiles.dest expanded", conf.General.DotfilesDest, err)
+	}
+	if err := ioutil.WriteFile(path, []byte("[GENERAL]\ndotfiles_dest = "+dir+"/store\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if conf, err = LoadConfig(); err != nil || conf.General.DotfilesDest != filepath.Join(dir, "store") {
+		t.Errorf("with dotfiles_dest set the store is at %q %v, want the config's", conf.General.DotfilesDest, err)
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A key of the config whose value isn't the built-in default
+type Deviation struct {
+	Section string
+	Key     string
+	Value   string
+	Default string
+}
+
+// A key of the config with its value formatted like it's written in tuckr.conf
+type field struct {
+	section string
+	key     string
+	value   string
+}
+
+// Returns the keys of the config that have a single value in the order they're documented
+func (c Config) fields() []field {
+	return []field{
+		{"GENERAL", "clone_dotfiles_cmd", c.General.CloneDotfilesCmd},
+		{"GENERAL", "dotfiles_repo", c.General.DotfilesRepo},
+		{"GENERAL", "dotfiles_dest", c.General.DotfilesDest},
+		{"GENERAL", "read_only_store", strconv.FormatBool(c.General.ReadOnlyStore)},
+		{"GENERAL", "link_registry", strconv.FormatBool(c.General.LinkRegistry)},
+		{"GENERAL", "conflict_policy", c.General.ConflictPolicy},
+		{"GENERAL", "maintenance", c.General.Maintenance},
+		{"GENERAL", "store_format", c.General.StoreFormat},
+		{"GENERAL", "escalation_cmd", c.General.EscalationCmd},
+		{"GENERAL", "decrypt_cmd", c.General.DecryptCmd},
+		{"GENERAL", "fs_concurrency", strconv.Itoa(c.General.FSConcurrency)},
+		{"GENERAL", "umask", fmt.Sprintf("%03o", c.General.Umask)},
+		{"GENERAL", "suggest_paths", strings.Join(c.General.SuggestPaths, ", ")},
+		{"GENERAL", "allowed_scripts", strings.Join(c.General.AllowedScripts, ", ")},
+		{"GENERAL", "script_commands", strings.Join(c.General.ScriptCommands, ", ")},
+		{"GENERAL", "sensitive_groups", strings.Join(c.General.SensitiveGroups, ", ")},
+		{"GENERAL", "sensitive_perms", fmt.Sprintf("%03o", c.General.SensitivePerms)},
+		{"PACKAGES", "pkg_install_cmd", c.Packages.PkgInstallCmd},
+		{"PACKAGES", "pkg_list", c.Packages.PkgList},
+		{"PACKAGES", "pip_list", c.Packages.PipList},
+		{"PACKAGES", "npm_list", c.Packages.NpmList},
+		{"PACKAGES", "yarn_list", c.Packages.YarnList},
+	}
+}
+
+/* Returns the keys of the config whose values differ from Default
+Every entry under [SCRIPTS], [TARGETS] and the profiles counts since there are
+none by default */
+func DiffDefaults(c Config) []Deviation {
+	var deviations []Deviation
+	defaults := Default().fields()
+	for i, f := range c.fields() {
+		if f.value != defaults[i].value {
+			deviations = append(deviations, Deviation{Section: f.section, Key: f.key, Value: f.value, Default: defaults[i].value})
+		}
+	}
+	for _, section := range []struct {
+		name    string
+		entries map[string]string
+	}{{"SCRIPTS", c.Scripts}, {"TARGETS", c.Targets}} {
+		var keys []string
+		for key := range section.entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			deviations = append(deviations, Deviation{Section: section.name, Key: key, Value: section.entries[key]})
+		}
+	}
+	var profiles []string
+	for name := range c.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		profile := c.Profiles[name]
+		for _, list := range []struct {
+			key    string
+			values []string
+		}{{"groups", profile.Groups}, {"packages", profile.Packages}, {"scripts", profile.Scripts}} {
+			if len(list.values) > 0 {
+				deviations = append(deviations, Deviation{Section: "PROFILE " + name, Key: list.key, Value: strings.Join(list.values, ", ")})
+			}
+		}
+	}
+	return deviations
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffDefaultsOnlyListsWhatWasChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tuckr-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tuckr.conf")
+	contents := "[GENERAL]\nconflict_policy = backup\nfs_concurrency = 8\n# written out even though it's the default\nescalation_cmd = sudo\n[TARGETS]\nbin = " + dir + "/bin\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setEnv(t, "TUCKR_CONFIG", path)
+	stubGitConfig(t, nil)
+	conf, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Deviation{
+		{Section: "GENERAL", Key: "conflict_policy", Value: "backup"},
+		{Section: "GENERAL", Key: "fs_concurrency", Value: "8", Default: "4"},
+		{Section: "TARGETS", Key: "bin", Value: filepath.Join(dir, "bin")},
+	}
+	if got := DiffDefaults(conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffDefaults listed %+v, want %+v", got, want)
+	}
+	if got := DiffDefaults(Default()); len(got) != 0 {
+		t.Errorf("the defaults differ from themselves by %+v", got)
+	}
+
+	// Every single valued key has to be compared for a new one not to go unnoticed
+	keys := reflect.TypeOf(General{}).NumField() + reflect.TypeOf(Packages{}).NumField()
+	if fields := Default().fields(); len(fields) != keys {
+		t.Errorf("only %d of the %d keys are compared against their defaults", len(fields), keys)
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Operating system paths get resolved for, swapped out to resolve them the way another platform would
+var goos = runtime.GOOS
+
+/* Returns the user's home directory
+$HOME takes precedence, on Windows where it's usually unset %USERPROFILE% and
+then %HOMEDRIVE%%HOMEPATH% are used instead */
+func HomeDir() (string, error) {
+	if home := os.Getenv("HOME"); home != "" {
+		return home, nil
+	}
+	if goos != "windows" {
+		return os.UserHomeDir()
+	}
+	if home := os.Getenv("USERPROFILE"); home != "" {
+		return home, nil
+	}
+	if drive, path := os.Getenv("HOMEDRIVE"), os.Getenv("HOMEPATH"); drive != "" && path != "" {
+		return drive + path, nil
+	}
+	return "", errors.New("Error: Could not find the home directory, neither %HOME% nor %USERPROFILE% are set")
+}
+
+// Where each XDG base directory is by default relative to home and the variable holding it on Windows
+var baseDirs = map[string]struct {
+	home    string
+	windows string
+}{
+	"XDG_CONFIG_HOME": {home: ".config", windows: "APPDATA"},
+	"XDG_DATA_HOME":   {home: filepath.Join(".local", "share"), windows: "LOCALAPPDATA"},
+	"XDG_STATE_HOME":  {home: filepath.Join(".local", "state"), windows: "LOCALAPPDATA"},
+	"XDG_CACHE_HOME":  {home: ".cache", windows: "LOCALAPPDATA"},
+}
+
+/* Returns the XDG base directory held by the variable, like XDG_CONFIG_HOME
+The variable takes precedence, then on Windows the config dir is %APPDATA% and the
+others are %LOCALAPPDATA%, otherwise it's the XDG default inside of home */
+func BaseDir(variable string) (string, error) {
+	if dir := os.Getenv(variable); dir != "" {
+		return dir, nil
+	}
+	base, ok := baseDirs[variable]
+	if !ok {
+		return "", errors.New("Error: " + variable + " is not an XDG base directory")
+	}
+	if dir := os.Getenv(base.windows); goos == "windows" && dir != "" {
+		return dir, nil
+	}
+	home, err := HomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, base.home), nil
+}
+
+/* Returns the value of a variable in a path, $HOME and the XDG base directories
+resolve even when they're unset, see HomeDir and BaseDir */
+func pathVar(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	var dir string
+	if name == "HOME" {
+		dir, _ = HomeDir()
+	} else if _, ok := baseDirs[name]; ok {
+		dir, _ = BaseDir(name)
+	}
+	return dir
+}
+
+/* Expands a leading ~ or ~user and any $VARS in path and makes it absolute
+An empty path stays empty, unset variables expand to nothing except for the ones pathVar resolves */
+func ExpandPath(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(path, "~") {
+		name := path[1:]
+		rest := ""
+		if i := strings.IndexAny(name, `/\`); i >= 0 {
+			name, rest = name[:i], name[i:]
+		}
+		var home string
+		if name == "" {
+			dir, err := HomeDir()
+			if err != nil {
+				return "", err
+			}
+			home = dir
+		} else {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", errors.New("Error: Could not expand ~" + name + ": " + err.Error())
+			}
+			home = u.HomeDir
+		}
+		path = home + rest
+	}
+	return filepath.Abs(os.Expand(path, pathVar))
+}
+
+/* Same as ExpandPath but a leading ~ without a user name is expanded to home, for
+paths that belong to someone other than the user running tuckr */
+func ExpandPathIn(path string, home string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		path = home + path[1:]
+	}
+	return ExpandPath(path)
+}
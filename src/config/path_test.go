@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+)
+
+// Sets the variable for the rest of the test
+func setEnv(t *testing.T, name string, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func TestExpandPathExpandsTildeAndVars(t *testing.T) {
+	home := filepath.Join(os.TempDir(), "tuckr-home")
+	setEnv(t, "HOME", home)
+	setEnv(t, "XDG_CONFIG_HOME", "")
+	want := filepath.Join(home, "foo")
+	for _, path := range []string{"~/foo", "$HOME/foo", "${HOME}/foo", "~/bar/../foo"} {
+		if got, err := ExpandPath(path); err != nil || got != want {
+			t.Errorf("ExpandPath(%q) = %q %v, want %q", path, got, err, want)
+		}
+	}
+	if got, _ := ExpandPath("$XDG_CONFIG_HOME/tuckr"); got != filepath.Join(home, ".config", "tuckr") {
+		t.Errorf("an unset XDG_CONFIG_HOME expanded to %q", got)
+	}
+	wd, _ := os.Getwd()
+	if got, _ := ExpandPath("foo"); got != filepath.Join(wd, "foo") {
+		t.Errorf("a relative path expanded to %q", got)
+	}
+	if got, err := ExpandPath(""); got != "" || err != nil {
+		t.Errorf("an empty path expanded to %q %v", got, err)
+	}
+}
+
+func TestExpandPathExpandsOtherUsersHomes(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skip("the current user can't be looked up:", err)
+	}
+	if got, err := ExpandPath("~" + u.Username + "/foo"); err != nil || got != filepath.Join(u.HomeDir, "foo") {
+		t.Errorf("~%s/foo expanded to %q %v, want it inside of %s", u.Username, got, err, u.HomeDir)
+	}
+	if _, err := ExpandPath("~tuckr-no-such-user/foo"); err == nil {
+		t.Error("the home of a user that doesn't exist was expanded")
+	}
+}
+
+func TestWindowsPathsFallBackToItsVariables(t *testing.T) {
+	platform := goos
+	goos = "windows"
+	t.Cleanup(func() { goos = platform })
+	for _, name := range []string{"HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME", "USERPROFILE", "HOMEDRIVE", "HOMEPATH"} {
+		setEnv(t, name, "")
+	}
+	setEnv(t, "APPDATA", "/Users/me/AppData/Roaming")
+	setEnv(t, "LOCALAPPDATA", "/Users/me/AppData/Local")
+
+	// Without %USERPROFILE% the home is made out of the drive and path
+	setEnv(t, "HOMEDRIVE", "/drive")
+	setEnv(t, "HOMEPATH", "/Users/me")
+	if home, err := HomeDir(); err != nil || home != "/drive/Users/me" {
+		t.Errorf("the home without USERPROFILE is %q %v", home, err)
+	}
+	setEnv(t, "USERPROFILE", "/Users/me")
+
+	tests := map[string]string{
+		"~/.vimrc":                 "/Users/me/.vimrc",
+		"$HOME/.vimrc":             "/Users/me/.vimrc",
+		"$XDG_CONFIG_HOME/nvim":    "/Users/me/AppData/Roaming/nvim",
+		"$XDG_DATA_HOME/fonts":     "/Users/me/AppData/Local/fonts",
+		"$XDG_STATE_HOME/tuckr":    "/Users/me/AppData/Local/tuckr",
+		"${XDG_CACHE_HOME}/tuckr":  "/Users/me/AppData/Local/tuckr",
+		"$TUCKR_TEST_UNSET/.vimrc": "/.vimrc",
+	}
+	for path, want := range tests {
+		if got, err := ExpandPath(path); err != nil || got != filepath.FromSlash(want) {
+			t.Errorf("ExpandPath(%q) = %q %v, want %q", path, got, err, want)
+		}
+	}
+
+	// The variables themselves still win when they're set
+	setEnv(t, "XDG_CONFIG_HOME", "/config")
+	if dir, err := BaseDir("XDG_CONFIG_HOME"); err != nil || dir != "/config" {
+		t.Errorf("with XDG_CONFIG_HOME set the config dir is %q %v", dir, err)
+	}
+	setEnv(t, "LOCALAPPDATA", "")
+	if dir, err := BaseDir("XDG_CACHE_HOME"); err != nil || dir != filepath.Join("/Users/me", ".cache") {
+		t.Errorf("without LOCALAPPDATA the cache dir is %q %v", dir, err)
+	}
+	if _, err := BaseDir("XDG_RUNTIME_DIR"); err == nil {
+		t.Error("a variable that isn't a base directory resolved")
+	}
+	setEnv(t, "USERPROFILE", "")
+	setEnv(t, "HOMEDRIVE", "")
+	if _, err := HomeDir(); err == nil {
+		t.Error("a home was found without any of its variables")
+	}
+}
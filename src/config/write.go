@@ -0,0 +1,24 @@
+package config
+
+import (
+	"bufio"
+	"io"
+)
+
+/* Writes the config to w in the format Parse reads
+Only the values that differ from Default are written, each under its section */
+func Write(w io.Writer, c Config) error {
+	out := bufio.NewWriter(w)
+	section := ""
+	for _, d := range DiffDefaults(c) {
+		if d.Section != section {
+			if section != "" {
+				out.WriteString("\n")
+			}
+			section = d.Section
+			out.WriteString("[" + section + "]\n")
+		}
+		out.WriteString(d.Key + " = " + d.Value + "\n")
+	}
+	return out.Flush()
+}
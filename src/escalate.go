@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/setup"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Swapped out by tests since they can write anywhere as root and can't run sudo
+var (
+	writable                             = writableDir
+	escalationRunner setup.CommandRunner = setup.ExecRunner{}
+)
+
+/* Splits links into the ones the current user can create and the privileged ones
+whose target's closest existing directory the user can't write to */
+func splitPrivileged(links []manage.Link) ([]manage.Link, []manage.Link) {
+	var unprivileged, privileged []manage.Link
+	for _, l := range links {
+		if l.IsLinked() || writable(l.Target) {
+			unprivileged = append(unprivileged, l)
+		} else {
+			privileged = append(privileged, l)
+		}
+	}
+	return unprivileged, privileged
+}
+
+/* Returns true if a file can be created in the closest directory of path that exists
+It's found out by creating and removing a file there since permissions alone don't
+tell the whole story with ACLs and read-only mounts */
+func writableDir(path string) bool {
+	dir := filepath.Dir(path)
+	for {
+		if info, err := os.Stat(dir); err == nil {
+			if !info.IsDir() {
+				return false
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+	f, err := ioutil.TempFile(dir, ".tuckr-probe")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+/* Creates the privileged links by running mkdir and ln through the escalation command
+Nothing else is run with privileges, targets that are taken are skipped instead of
+being resolved since replacing system files is best left to the user */
+func createPrivilegedLinks(links []manage.Link, escalation []string, runner setup.CommandRunner, results *manage.Results) error {
+	if len(escalation) == 0 {
+		return errors.New("Error: No escalation_cmd set in config")
+	}
+	run := func(args ...string) error {
+		return runner.Run(escalation[0], append(append([]string{}, escalation[1:]...), args...)...)
+	}
+	for _, l := range links {
+		if _, err := os.Lstat(l.Target); err == nil {
+			fmt.Println(aurora.Red("Skipping:"), l.Target, "already exists and needs privileges to replace")
+			results.Add(manage.Skipped, l.Target)
+			continue
+		}
+		fmt.Println(aurora.Yellow("Privileged:"), l.Target, "->", l.Source)
+		if err := run("mkdir", "-p", filepath.Dir(l.Target)); err != nil {
+			return errors.New("Error: Could not create " + filepath.Dir(l.Target) + " with " + escalation[0] + ": " + err.Error())
+		}
+		if err := run("ln", "-s", l.Source, l.Target); err != nil {
+			return errors.New("Error: Could not link " + l.Target + " with " + escalation[0] + ": " + err.Error())
+		}
+		results.Add(manage.Created, l.Target)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/setup"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEscalationIsOnlyUsedForPrivilegedTargets(t *testing.T) {
+	e := newTestEnv(t)
+	etc := filepath.Join(e.dir, "etc")
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	hosts := e.write(t, "Configs/system/hosts", "")
+	e.write(t, "Configs/system/resolv.conf", "")
+	e.write(t, "Configs/system/.tuckr.json", `{"targets": ["`+etc+`"]}`)
+	writeTestFile(t, filepath.Join(etc, "resolv.conf"), "nameserver 1.1.1.1")
+	writable = func(path string) bool { return !strings.HasPrefix(path, etc) }
+	runner := &stubRunner{}
+	escalationRunner = runner
+	t.Cleanup(func() {
+		writable = writableDir
+		escalationRunner = setup.ExecRunner{}
+	})
+
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"vim", "system"}, setOptions{escalation: []string{"sudo", "-n"}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	// The taken resolv.conf is skipped instead of replaced with privileges
+	want := []string{
+		"sudo -n mkdir -p " + etc,
+		"sudo -n ln -s " + hosts + " " + filepath.Join(etc, "hosts"),
+	}
+	if !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("escalated %v, want %v", runner.calls, want)
+	}
+
+	// Without escalation every link is created as the current user
+	runner.calls = nil
+	e.write(t, "Configs/system/fstab", "")
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"system"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(runner.calls) != 0 {
+		t.Errorf("a set without escalation escalated %v", runner.calls)
+	}
+	assertLinked(t, filepath.Join(etc, "hosts"), hosts)
+	assertLinked(t, filepath.Join(etc, "fstab"), filepath.Join(e.store, "Configs", "system", "fstab"))
+}
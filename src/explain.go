@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// The reasons --explain gives for a file being left alone
+const (
+	// The group's .tuckrignore matches the file
+	skipIgnored = iota
+	// The target is already a link to the file
+	skipLinked
+	// The target is taken by a file that's not a link to the store
+	skipConflict
+)
+
+// A file of a group that isn't getting linked and why
+type skippedLink struct {
+	reason int
+	target string
+	why    string
+}
+
+/* Returns why set leaves the whole group alone, an empty string when it doesn't
+It's disabled, meant for other platforms or its enableWhen doesn't hold */
+func groupSkipReason(root string, group string, disabled state.Disabled) (string, error) {
+	if disabled.Has(group) {
+		return "is disabled", nil
+	}
+	conf, err := store.LoadGroupConfig(root, group)
+	if err != nil {
+		return "", err
+	}
+	if !conf.SupportsPlatform(runtime.GOOS) {
+		return "is not meant for " + runtime.GOOS, nil
+	}
+	enabled, err := store.EvalEnableWhen(conf.EnableWhen)
+	if err != nil {
+		return "", err
+	}
+	if !enabled {
+		return "is not enabled since " + conf.EnableWhen + " doesn't hold", nil
+	}
+	return "", nil
+}
+
+// Same as groupSkipReason but groups the store's .tuckrignore matches are left out as well
+func filteredReason(root string, group string, ignore store.Ignore, disabled state.Disabled) (string, error) {
+	if ignore.Match(group) {
+		return "is ignored by the store's " + store.IgnoreName, nil
+	}
+	return groupSkipReason(root, group, disabled)
+}
+
+/* Returns the files of a deployment that don't get linked along with why
+links are the deployment's links as groupLinks returns them */
+func skippedLinks(root string, group string, d deployment, links []manage.Link) ([]skippedLink, error) {
+	_, ignored, err := splitStoreLinks(root, group, d)
+	if err != nil {
+		return nil, err
+	}
+	var skipped []skippedLink
+	for _, i := range ignored {
+		why := "is ignored by the pattern " + strconv.Quote(i.pattern) + " in " + group + "'s " + store.IgnoreName
+		skipped = append(skipped, skippedLink{reason: skipIgnored, target: i.link.Target, why: why})
+	}
+	for _, l := range links {
+		if l.IsLinked() {
+			skipped = append(skipped, skippedLink{reason: skipLinked, target: l.Target, why: "is already linked"})
+		} else if _, err := os.Lstat(l.Target); err == nil {
+			skipped = append(skipped, skippedLink{reason: skipConflict, target: l.Target, why: "is taken by a file that isn't a link to the store"})
+		}
+	}
+	return skipped, nil
+}
+
+// Prints why each file skipped for any of the reasons is left alone
+func printSkipped(skipped []skippedLink, reasons ...int) {
+	for _, s := range skipped {
+		for _, reason := range reasons {
+			if s.reason == reason {
+				fmt.Println(aurora.Yellow("Skipping:"), s.target, s.why)
+			}
+		}
+	}
+}
+
+/* Prints why the group or any of its files isn't linked below its status
+Conflicts are left out since status already lists them as problems */
+func explainStatus(root string, group string, home string, disabled state.Disabled) error {
+	ignore, err := store.LoadStoreIgnore(root)
+	if err != nil {
+		return err
+	}
+	reason, err := filteredReason(root, group, ignore, disabled)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		fmt.Println("  ", aurora.Yellow("Skipped:"), group, reason)
+	}
+	deployments, err := groupDeployments(root, group, home)
+	if err != nil {
+		return err
+	}
+	for _, d := range deployments {
+		links, err := groupLinks(root, group, d)
+		if err != nil {
+			return err
+		}
+		skipped, err := skippedLinks(root, group, d, links)
+		if err != nil {
+			return err
+		}
+		for _, s := range skipped {
+			if s.reason == skipIgnored {
+				fmt.Println("  ", aurora.Yellow("Skipped:"), s.target, s.why)
+			}
+		}
+	}
+	return nil
+}
+
+/* Prints why the groups matched by the patterns were left out of selected
+Groups that are named as they are always get selected so only patterns leave any out */
+func printFilteredGroups(root string, patterns []string, selected []string) error {
+	// The groups left out are the ones to explain so none of them are filtered away
+	all, err := store.ListGroups(root, store.GroupFilter{})
+	if err != nil {
+		return err
+	}
+	ignore, err := store.LoadStoreIgnore(root)
+	if err != nil {
+		return err
+	}
+	disabled, err := state.LoadDisabled()
+	if err != nil {
+		return err
+	}
+	chosen := map[string]bool{}
+	for _, group := range selected {
+		chosen[group] = true
+	}
+	for _, group := range all {
+		matched := false
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(pattern, group); ok && strings.ContainsAny(pattern, "*?[") {
+				matched = true
+			}
+		}
+		if chosen[group] || !matched {
+			continue
+		}
+		reason, err := filteredReason(root, group, ignore, disabled)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			fmt.Println(aurora.Yellow("Skipping:"), group, reason)
+		}
+	}
+	return nil
+}
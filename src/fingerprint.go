@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"sort"
+	"strconv"
+)
+
+// Handles the fingerprint command by saving the hashes of every file in the store
+func runFingerprint(args []string) error {
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	files, err := store.HashTree(root)
+	if err != nil {
+		return err
+	}
+	fingerprint := state.Fingerprint{Store: root, Hash: store.TreeHash(files), Files: files}
+	if err := fingerprint.Save(); err != nil {
+		return err
+	}
+	fmt.Println(aurora.Green("Fingerprint:"), fingerprint.Hash)
+	return nil
+}
+
+/* Handles the verify-fingerprint command
+The store is hashed again and every file that changed, appeared or disappeared
+since the saved fingerprint is printed */
+func runVerifyFingerprint(args []string) error {
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	saved, err := state.LoadFingerprint()
+	if err != nil {
+		return err
+	}
+	if saved.Hash == "" {
+		return errors.New("Error: The store has no fingerprint yet, run tuckr fingerprint first")
+	}
+	if saved.Store != root {
+		return errors.New("Error: The fingerprint is of " + saved.Store + " and not of " + root)
+	}
+	files, err := store.HashTree(root)
+	if err != nil {
+		return err
+	}
+	if store.TreeHash(files) == saved.Hash {
+		fmt.Println(aurora.Green("Verified:"), "the store matches its fingerprint")
+		return nil
+	}
+	differences := compareFingerprint(saved.Files, files)
+	for _, d := range differences {
+		fmt.Println(aurora.Red(d.kind+":"), d.path)
+	}
+	return errors.New("Error: " + strconv.Itoa(len(differences)) + " file(s) differ from the store's fingerprint")
+}
+
+// A file whose hash isn't what the fingerprint says
+type fingerprintDifference struct {
+	kind string
+	path string
+}
+
+// Returns the files that changed, were added or were removed from saved to current sorted by path
+func compareFingerprint(saved map[string]string, current map[string]string) []fingerprintDifference {
+	var differences []fingerprintDifference
+	for path, hash := range current {
+		if old, ok := saved[path]; !ok {
+			differences = append(differences, fingerprintDifference{"Added", path})
+		} else if old != hash {
+			differences = append(differences, fingerprintDifference{"Changed", path})
+		}
+	}
+	for path := range saved {
+		if _, ok := current[path]; !ok {
+			differences = append(differences, fingerprintDifference{"Removed", path})
+		}
+	}
+	sort.Slice(differences, func(i, j int) bool { return differences[i].path < differences[j].path })
+	return differences
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVerifyFingerprintPointsAtTheChangedFiles(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "set nu")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	e.write(t, ".git/HEAD", "ref: refs/heads/main")
+	gone := e.write(t, "Hooks/zsh/set_plugins.sh", "")
+	captureOutput(t, func() {
+		if err := runFingerprint(nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// Neither git's files nor the history are part of the fingerprint
+	e.write(t, ".git/HEAD", "ref: refs/heads/next")
+	captureOutput(t, func() {
+		appendHistory(e.store, store.HistoryEntry{Operation: "set"})
+		if err := runVerifyFingerprint(nil); err != nil {
+			t.Errorf("an untouched store failed verification: %v", err)
+		}
+	})
+
+	writeTestFile(t, vimrc, "set nonu")
+	e.write(t, "Configs/vim/.exrc", "")
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	out := captureOutput(t, func() {
+		err = runVerifyFingerprint(nil)
+	})
+	if err == nil || !strings.Contains(err.Error(), "3 file(s)") {
+		t.Errorf("verifying a changed store failed with %v, want 3 files reported", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	want := []string{"Added:", "Configs/vim/.exrc", "Changed:", "Configs/vim/.vimrc", "Removed:", "Hooks/zsh/set_plugins.sh"}
+	if len(lines) != 3 {
+		t.Fatalf("verify-fingerprint printed %q, want one line per file", out)
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, want[2*i]) || !strings.HasSuffix(line, " "+want[2*i+1]) {
+			t.Errorf("line %d is %q, want %s %s", i+1, line, want[2*i], want[2*i+1])
+		}
+	}
+}
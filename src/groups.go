@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+/* A directory of a group in the store and the directory its files get linked into
+Files linked from executable deployments have their execute bit set, symlinks
+in dereferenced ones get linked to what they point to and files deeper than
+maxDepth, when it's set, aren't linked */
+type deployment struct {
+	src         string
+	target      string
+	executable  bool
+	dereference bool
+	maxDepth    int
+	// Only the dot entries of the group's own directory get linked, Bin's tools and the like aren't dotfiles by nature
+	dotfilesOnly bool
+}
+
+/* Returns every place a group gets deployed into
+Its directory goes into its folder type's root, or into the targets listed in its
+.tuckr.json, and its directories in the other folder types go into their roots
+See GroupPath for which of its directories is the group's own */
+func groupDeployments(root string, group string, home string) ([]deployment, error) {
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	groupConf, err := store.LoadGroupConfig(root, group)
+	if err != nil {
+		return nil, err
+	}
+	// The targets may be written relative to ~, which is home's rather than the current user's
+	overrides, err := conf.TargetsIn(home)
+	if err != nil {
+		return nil, err
+	}
+	groupTargets, err := groupConf.TargetsIn(home)
+	if err != nil {
+		return nil, err
+	}
+	var deployments []deployment
+	for _, folder := range store.FolderTypes(root, home, overrides) {
+		src := filepath.Join(root, folder.Dir, group)
+		if _, err := os.Stat(src); err != nil || store.IgnoresGroup(src) {
+			continue
+		}
+		targets := []string{folder.Root}
+		if src == store.GroupPath(root, group) && len(groupTargets) > 0 {
+			targets = groupTargets
+		}
+		for _, target := range targets {
+			deployments = append(deployments, deployment{src: src, target: target, executable: folder.Executable})
+		}
+	}
+	return deployments, nil
+}
+
+/* Returns the links needed for a deployment of a group
+The group's README, .tuckr.json, .tuckrignore, links.map and hooks directory only
+describe it so they're never linked, neither are the files its ignore patterns match
+When the store is read-only the links point into the cache instead, see cacheLinks, and
+encrypted files are linked from where their plaintext gets decrypted to, see decryptedLinks */
+func groupLinks(root string, group string, d deployment) ([]manage.Link, error) {
+	links, err := storeLinks(root, group, d)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if conf.General.ReadOnlyStore {
+		if links, err = linksIntoCache(root, links); err != nil {
+			return nil, err
+		}
+	}
+	return decryptedLinks(root, group, links)
+}
+
+/* Points the links into the cache instead of the store, see cacheLinks
+Links already pointing into the cache or at decrypted secrets are left alone */
+func linksIntoCache(root string, links []manage.Link) ([]manage.Link, error) {
+	cache, err := config.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range links {
+		if manage.IsWithin(l.Source, cache) || isDecrypted(l) {
+			continue
+		}
+		rel, err := filepath.Rel(root, l.Source)
+		if err != nil {
+			return nil, err
+		}
+		links[i].Source = filepath.Join(cache, rel)
+	}
+	return links, nil
+}
+
+/* Copies the store files of links that point into the cache over to it
+Links that point straight into the store are left alone */
+func cacheLinks(root string, links []manage.Link) error {
+	cache, err := config.CacheDir()
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		if !manage.IsWithin(l.Source, cache) {
+			continue
+		}
+		if err := manage.CopyTree(origin(root, cache, l), l.Source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the file in the store that the link's source comes from, the encrypted one for decrypted secrets
+func origin(root string, cache string, l manage.Link) string {
+	if isDecrypted(l) {
+		return encryptedOrigin(root, l)
+	}
+	if !manage.IsWithin(l.Source, cache) {
+		return l.Source
+	}
+	rel, err := filepath.Rel(cache, l.Source)
+	if err != nil {
+		return l.Source
+	}
+	return filepath.Join(root, rel)
+}
+
+/* Returns the links of a deployment pointing straight at the files in the store
+The targets of the group's Configs get the name transforms of its .tuckr.json and
+parent directories of the targets that are symlinks get resolved, see ResolveTarget */
+func storeLinks(root string, group string, d deployment) ([]manage.Link, error) {
+	links, err := plannedStoreLinks(root, group, d)
+	for i, l := range links {
+		links[i].Target = manage.ResolveTarget(l.Target)
+	}
+	return links, err
+}
+
+// Returns the links of a deployment before their targets' parents are resolved
+func plannedStoreLinks(root string, group string, d deployment) ([]manage.Link, error) {
+	links, _, err := splitStoreLinks(root, group, d)
+	return links, err
+}
+
+// A link left out of a deployment because the group's .tuckrignore matches its file
+type ignoredLink struct {
+	link    manage.Link
+	pattern string
+}
+
+// Same as plannedStoreLinks but also returns the links the group's .tuckrignore leaves out
+func splitStoreLinks(root string, group string, d deployment) ([]manage.Link, []ignoredLink, error) {
+	conf, err := store.LoadGroupConfig(root, group)
+	if err != nil {
+		return nil, nil, err
+	}
+	ignore, err := store.LoadIgnore(root, group)
+	if err != nil {
+		return nil, nil, err
+	}
+	planned, err := manage.PlanLinks(d.src, d.target, manage.PlanOptions{WholeDirs: conf.LinkAsDirectory, Dereference: d.dereference, MaxDepth: d.maxDepth})
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.src != store.GroupPath(root, group) {
+		return planned, nil, nil
+	}
+	var links []manage.Link
+	var ignored []ignoredLink
+	for _, l := range planned {
+		// the source may be dereferenced but the planned target mirrors the store
+		rel, err := filepath.Rel(d.target, l.Target)
+		if err != nil {
+			return nil, nil, err
+		}
+		if store.IsGroupMeta(rel) || conf.InHooks(rel) {
+			continue
+		}
+		l.Target = filepath.Join(d.target, conf.LinkName(rel))
+		if d.dotfilesOnly && !isDotfile(conf.LinkName(rel)) {
+			continue
+		}
+		if pattern, ok := ignore.Matching(rel); ok {
+			ignored = append(ignored, ignoredLink{link: l, pattern: pattern})
+			continue
+		}
+		links = append(links, l)
+	}
+	return links, ignored, nil
+}
+
+/* Returns true if the top level entry of the path, relative to a target, starts with a dot
+so .vimrc and .config/nvim/init.vim are dotfiles but README.md isn't */
+func isDotfile(rel string) bool {
+	return strings.HasPrefix(rel, ".") && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Looks up users by name, swapped out to avoid depending on the system's users
+var lookupUser = user.Lookup
+
+/* Returns the home directory groups get deployed into
+It's the current user's home unless name is set, then it's the home in name's
+passwd entry, deploying there takes privileges to write to that home. Targets relative
+to ~ are relative to this home, but the directories made in it along the way aren't
+handed over to the user, they're owned by whoever runs tuckr */
+func targetHome(name string) (string, error) {
+	if name == "" {
+		return config.HomeDir()
+	}
+	u, err := lookupUser(name)
+	if err != nil {
+		return "", errors.New("Error: Could not find user " + name + ": " + err.Error())
+	}
+	if u.HomeDir == "" {
+		return "", errors.New("Error: User " + name + " has no home directory")
+	}
+	return u.HomeDir, nil
+}
+
+/* Returns the filter for the groups that belong on this machine
+Groups for other platforms and the ones in the store's .tuckrignore are left out */
+func platformFilter(root string) (store.GroupFilter, error) {
+	ignore, err := store.LoadStoreIgnore(root)
+	if err != nil {
+		return store.GroupFilter{}, err
+	}
+	return store.GroupFilter{Platform: runtime.GOOS, Ignore: ignore}, nil
+}
+
+/* Returns the filter for the groups set deploys when it's asked for all of them
+It's the platformFilter with disabled groups left out as well */
+func deployFilter(root string) (store.GroupFilter, error) {
+	filter, err := platformFilter(root)
+	if err != nil {
+		return store.GroupFilter{}, err
+	}
+	disabled, err := state.LoadDisabled()
+	if err != nil {
+		return store.GroupFilter{}, err
+	}
+	filter.Disabled = disabled.Has
+	return filter, nil
+}
+
+/* Expands '*' and other glob patterns into the groups in the store that match and
+make it through the filter and checks that the other groups exist
+Groups are returned in the order they're asked for, each only once */
+func selectGroups(root string, args []string, filter store.GroupFilter) ([]string, error) {
+	var groups []string
+	var all []string
+	seen := map[string]bool{}
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?[") {
+			if !store.HasGroup(root, arg) {
+				return nil, errors.New("Error: Group " + arg + " does not exist")
+			}
+			if !seen[arg] {
+				seen[arg] = true
+				groups = append(groups, arg)
+			}
+			continue
+		}
+		if all == nil {
+			var err error
+			if all, err = store.ListGroups(root, filter); err != nil {
+				return nil, err
+			}
+		}
+		for _, group := range all {
+			if ok, err := filepath.Match(arg, group); err != nil {
+				return nil, errors.New("Error: Invalid group pattern " + arg)
+			} else if ok && !seen[group] {
+				seen[group] = true
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups, nil
+}
+
+/* Returns groups with the groups that make it through the filter and carry any of
+the comma separated tags appended, the ones already in groups aren't added again */
+func addTagged(root string, groups []string, filter store.GroupFilter, tags string) ([]string, error) {
+	filter.Tags = strings.FieldsFunc(tags, func(r rune) bool { return r == ',' })
+	tagged, err := store.ListGroups(root, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tagged) == 0 {
+		return nil, errors.New("Error: No group is tagged with " + tags)
+	}
+	seen := map[string]bool{}
+	for _, group := range groups {
+		seen[group] = true
+	}
+	for _, group := range tagged {
+		if !seen[group] {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+/* Reads a list of groups from a file, one group or glob pattern per line
+Blank lines and lines starting with # are skipped */
+func readGroupList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var groups []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			groups = append(groups, line)
+		}
+	}
+	return groups, scanner.Err()
+}
+
+// Returns the group's name followed by its description when it has one
+func describe(root string, group string) string {
+	if desc := store.Description(root, group); desc != "" {
+		return group + " - " + desc
+	}
+	return group
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/store"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Makes lookupUser find the users in homes, and only them, for the rest of the test
+func stubUsers(t *testing.T, homes map[string]string) {
+	t.Helper()
+	lookupUser = func(name string) (*user.User, error) {
+		home, ok := homes[name]
+		if !ok {
+			return nil, user.UnknownUserError(name)
+		}
+		return &user.User{Username: name, HomeDir: home}, nil
+	}
+	t.Cleanup(func() { lookupUser = user.Lookup })
+}
+
+func TestTargetHomeComesFromTheUserLookup(t *testing.T) {
+	e := newTestEnv(t)
+	alice := filepath.Join(e.dir, "alice")
+	stubUsers(t, map[string]string{"alice": alice, "nohome": ""})
+	if home, err := targetHome(""); err != nil || home != e.home {
+		t.Errorf("without a user the home is %q %v, want %q", home, err, e.home)
+	}
+	if home, err := targetHome("alice"); err != nil || home != alice {
+		t.Errorf("alice's home is %q %v, want %q", home, err, alice)
+	}
+	for _, name := range []string{"nohome", "bob"} {
+		if _, err := targetHome(name); err == nil {
+			t.Errorf("%s was given a home", name)
+		}
+	}
+
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	tool := e.write(t, "Bin/vim/vimdiff-all", "")
+	site := e.write(t, "Configs/web/site.conf", "")
+	e.write(t, "Configs/web/.tuckr.json", `{"targets": ["~/srv"]}`)
+	e.config(t, "[TARGETS]\nbin = ~/tools\n")
+	captureOutput(t, func() {
+		if err := runSet([]string{"--user", "alice", "vim", "web"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, filepath.Join(alice, ".vimrc"), vimrc)
+	assertLinked(t, filepath.Join(alice, "tools", "vimdiff-all"), tool)
+	assertLinked(t, filepath.Join(alice, "srv", "site.conf"), site)
+	assertMissing(t, e.inHome(".vimrc"))
+	assertMissing(t, e.inHome("tools"))
+	assertMissing(t, e.inHome("srv"))
+}
+
+func TestCommandsShareTheGroupsOfThisMachine(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	e.write(t, "Configs/paint/paint.ini", "")
+	e.write(t, "Configs/paint/.tuckr.json", `{"platforms": ["plan9"]}`)
+	e.write(t, "Configs/scratch/notes", "")
+	e.write(t, ".tuckrignore", "scratch\n")
+	captureOutput(t, func() {
+		if err := runDisable([]string{"zsh"}, true); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	deploy, err := deployFilter(e.store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := selectGroups(e.store, []string{"*"}, deploy); err != nil || !reflect.DeepEqual(got, []string{"vim"}) {
+		t.Errorf("set, impact and sandbox would take %v %v, want [vim]", got, err)
+	}
+	report, _, err := checkHealth(e.store, e.home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checked []string
+	for _, g := range report.Groups {
+		checked = append(checked, g.Name)
+	}
+	if want := []string{"vim", "zsh"}; !reflect.DeepEqual(checked, want) {
+		t.Errorf("serve checked %v, want the disabled group too %v", checked, want)
+	}
+	out := captureOutput(t, func() {
+		if err := runGroups([]string{"--print0"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := "paint\x00vim\x00zsh\x00"; out != want {
+		t.Errorf("groups listed %q, want every platform's groups %q", out, want)
+	}
+}
+
+func TestSetFromFileDeploysTheListedGroups(t *testing.T) {
+	e := newTestEnv(t)
+	for _, group := range []string{"vim", "git", "gnupg", "zsh"} {
+		e.write(t, "Configs/"+group+"/."+group+"rc", "")
+	}
+	list := writeTestFile(t, filepath.Join(e.dir, "laptop.groups"), "# editors\nvim\n\n  g*  \n# zsh\n")
+	captureOutput(t, func() {
+		if err := runSet([]string{"--from-file", list}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, group := range []string{"vim", "git", "gnupg"} {
+		assertLinked(t, e.inHome("."+group+"rc"), filepath.Join(e.store, "Configs", group, "."+group+"rc"))
+	}
+	assertMissing(t, e.inHome(".zshrc"))
+
+	empty := writeTestFile(t, filepath.Join(e.dir, "empty.groups"), "# nothing yet\n\n")
+	if err := runSet([]string{"--from-file", empty}); err == nil {
+		t.Error("setting from a list without groups succeeded")
+	}
+}
+
+func TestMarkedDirectoriesAreNotGroups(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/templates/.bashrc", "")
+	e.write(t, "Configs/templates/"+store.IgnoreGroupName, "")
+	if groups, err := store.Groups(e.store); err != nil || !reflect.DeepEqual(groups, []string{"vim"}) {
+		t.Errorf("the store has the groups %v %v, want only vim", groups, err)
+	}
+	captureOutput(t, func() {
+		if err := runSet([]string{"*"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := runSet([]string{"templates"}); err == nil {
+			t.Error("setting the marked directory by name succeeded")
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertMissing(t, e.inHome(".bashrc"))
+	assertMissing(t, e.inHome(store.IgnoreGroupName))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// A throwaway store, home and state dir that the environment points tuckr at for a test
+type testEnv struct {
+	dir   string
+	store string
+	home  string
+}
+
+// Environment variables tuckr reads its paths from, all of them are pointed into the test's directory
+var testEnvVars = []string{"TUCKR_STORE", "TUCKR_CONFIG", "TUCKR_STATE_DIR", "HOME", "XDG_CACHE_HOME", "XDG_CONFIG_HOME", "XDG_STATE_HOME", "SHELL"}
+
+// Creates a test environment that's removed along with its variables once the test ends
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "tuckr-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	e := &testEnv{dir: dir, store: filepath.Join(dir, "store"), home: filepath.Join(dir, "home")}
+	for _, d := range []string{e.store, e.home} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	values := map[string]string{
+		"TUCKR_STORE":     e.store,
+		"TUCKR_CONFIG":    filepath.Join(dir, "tuckr.conf"),
+		"TUCKR_STATE_DIR": filepath.Join(dir, "state"),
+		"HOME":            e.home,
+		"XDG_CACHE_HOME":  filepath.Join(dir, "cache"),
+		"XDG_CONFIG_HOME": filepath.Join(dir, "config"),
+		"XDG_STATE_HOME":  filepath.Join(dir, "xdg-state"),
+		"SHELL":           "sh",
+	}
+	for _, name := range testEnvVars {
+		old, ok := os.LookupEnv(name)
+		os.Setenv(name, values[name])
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(name, old)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return e
+}
+
+// Writes a file of the store, rel is relative to the store and uses slashes
+func (e *testEnv) write(t *testing.T, rel string, contents string) string {
+	t.Helper()
+	return writeTestFile(t, filepath.Join(e.store, filepath.FromSlash(rel)), contents)
+}
+
+// Writes the config file tuckr reads
+func (e *testEnv) config(t *testing.T, contents string) {
+	t.Helper()
+	writeTestFile(t, os.Getenv("TUCKR_CONFIG"), contents)
+}
+
+// Returns the path of rel inside of the home
+func (e *testEnv) inHome(rel string) string {
+	return filepath.Join(e.home, filepath.FromSlash(rel))
+}
+
+// Writes contents to path creating its parent directories and returns path
+func writeTestFile(t *testing.T, path string, contents string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Fails the test unless path is a symlink to dest
+func assertLinked(t *testing.T, path string, dest string) {
+	t.Helper()
+	if got, err := os.Readlink(path); err != nil || got != dest {
+		t.Errorf("%s links to %q (%v), want %q", path, got, err, dest)
+	}
+}
+
+// Fails the test if anything is at path
+func assertMissing(t *testing.T, path string) {
+	t.Helper()
+	if _, err := os.Lstat(path); err == nil {
+		t.Errorf("%s exists but shouldn't", path)
+	}
+}
+
+// Runs f and returns what it printed to stdout
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.Bytes()
+	}()
+	defer func() {
+		os.Stdout = stdout
+	}()
+	f()
+	w.Close()
+	os.Stdout = stdout
+	return string(<-done)
+}
+
+// Returns out without the escape codes that color it
+func uncolored(out string) string {
+	return regexp.MustCompile("\x1b\\[[0-9;]*m").ReplaceAllString(out, "")
+}
+
+/* Records the commands it's asked to run instead of running them, run is called for each when set
+and output is what's called for the commands whose output is read */
+type stubRunner struct {
+	calls  []string
+	run    func(name string, args ...string) error
+	output func(args ...string) ([]byte, error)
+}
+
+func (r *stubRunner) Run(name string, args ...string) error {
+	r.calls = append(r.calls, strings.Join(append([]string{name}, args...), " "))
+	if r.run != nil {
+		return r.run(name, args...)
+	}
+	return nil
+}
+
+func (r *stubRunner) Output(name string, args ...string) ([]byte, error) {
+	if r.output == nil {
+		return nil, r.Run(name, args...)
+	}
+	r.calls = append(r.calls, strings.Join(append([]string{name}, args...), " "))
+	return r.output(append([]string{name}, args...)...)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"os"
+)
+
+/* Returns the directory the history gets appended to, the store's root unless the
+config's read_only_store is on, then it's the state dir since the store can't be written */
+func historyDir(root string) (string, error) {
+	conf, err := config.LoadConfig()
+	if err != nil || !conf.General.ReadOnlyStore {
+		return root, err
+	}
+	dir, err := state.Dir()
+	if err != nil {
+		return "", err
+	}
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// Appends the entry to the history in historyDir, see store.AppendHistory
+func appendHistory(root string, entry store.HistoryEntry) error {
+	dir, err := historyDir(root)
+	if err != nil {
+		return err
+	}
+	return store.AppendHistory(dir, entry)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"strings"
+)
+
+// A target of a group that's taken by something set would back up or overwrite
+type impacted struct {
+	target string
+	kind   string
+}
+
+/* Handles the impact command which lists the targets of the groups that setting
+them would back up or overwrite, depending on the conflict policy, without
+touching anything. Targets already linked to the store aren't listed */
+func runImpact(args []string) error {
+	flags := flag.NewFlagSet("impact", flag.ExitOnError)
+	userName := flags.String("user", "", "look at this user's home instead")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) == 0 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := targetHome(*userName)
+	if err != nil {
+		return err
+	}
+	filter, err := deployFilter(root)
+	if err != nil {
+		return err
+	}
+	groups, err := selectGroups(root, args, filter)
+	if err != nil {
+		return err
+	}
+	affected, err := groupImpact(root, home, groups)
+	if err != nil {
+		return err
+	}
+	for _, a := range affected {
+		fmt.Println(aurora.Cyan("Would replace:"), a.target, aurora.Yellow("("+a.kind+")"))
+	}
+	if len(affected) == 0 {
+		fmt.Println(aurora.Green("Nothing would be replaced by"), strings.Join(groups, ", "))
+	}
+	return nil
+}
+
+// Returns the targets of the groups taken by files, directories or symlinks that don't point at the store
+func groupImpact(root string, home string, groups []string) ([]impacted, error) {
+	var affected []impacted
+	for _, group := range groups {
+		deployments, err := groupDeployments(root, group, home)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range deployments {
+			links, err := groupLinks(root, group, d)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range links {
+				info, err := os.Lstat(l.Target)
+				if err != nil || l.IsLinked() {
+					continue
+				}
+				kind := "file"
+				if info.Mode()&os.ModeSymlink != 0 {
+					dest, _ := os.Readlink(l.Target)
+					kind = "symlink to " + dest
+				} else if info.IsDir() {
+					kind = "directory"
+				}
+				affected = append(affected, impacted{target: l.Target, kind: kind})
+			}
+		}
+	}
+	return affected, nil
+}
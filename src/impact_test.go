@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestImpactNamesOnlyTheTakenTargets(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	exrc := e.write(t, "Configs/vim/.exrc", "")
+	e.write(t, "Configs/vim/.gvimrc", "")
+	e.write(t, "Configs/vim/.vim/colors/dark.vim", "")
+	e.write(t, "Configs/vim/.tuckr.json", `{"linkAsDirectory": [".vim"]}`)
+	e.write(t, "Configs/zsh/.zshrc", "")
+	writeTestFile(t, e.inHome(".vimrc"), "mine")
+	writeTestFile(t, e.inHome(".vim/colors/light.vim"), "")
+	writeTestFile(t, e.inHome(".zshrc"), "mine")
+	if err := os.Symlink(exrc, e.inHome(".exrc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc/gvimrc", e.inHome(".gvimrc")); err != nil {
+		t.Fatal(err)
+	}
+
+	affected, err := groupImpact(e.store, e.home, []string{"vim"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The linked .exrc and the zsh group that isn't asked about are left out
+	want := []impacted{
+		{target: e.inHome(".gvimrc"), kind: "symlink to /etc/gvimrc"},
+		{target: e.inHome(".vim"), kind: "directory"},
+		{target: e.inHome(".vimrc"), kind: "file"},
+	}
+	if !reflect.DeepEqual(affected, want) {
+		t.Errorf("impact listed %+v, want %+v", affected, want)
+	}
+}
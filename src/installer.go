@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/setup"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/* Handles the export command, export installer <file.sh> [group...] writes a shell
+script that sets up a fresh machine the way this one is. The groups default to the
+ones set here, or to every group when none are, and the store gets cloned to where
+it is here unless dotfiles_dest says otherwise */
+func runExport(args []string) error {
+	if len(args) < 2 || args[0] != "installer" {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	groups := args[2:]
+	if len(groups) == 0 {
+		deployed, err := state.LoadDeployed()
+		if err != nil {
+			return err
+		}
+		for group := range deployed {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+	}
+	if len(groups) == 0 {
+		groups = []string{"*"}
+	}
+	if conf.General.DotfilesDest == "" {
+		if conf.General.DotfilesDest, err = store.Root(); err != nil {
+			return err
+		}
+	}
+	home, err := config.HomeDir()
+	if err != nil {
+		return err
+	}
+	script, err := installerScript(conf, home, groups)
+	if err != nil {
+		return err
+	}
+	path, err := config.ExpandPath(args[1])
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		return err
+	}
+	fmt.Println(aurora.Green("Wrote installer:"), path)
+	return nil
+}
+
+/* Returns a shell script that clones the config's dotfiles_repo, installs the packages
+of its lists and sets the groups. The lists are read now so the script needs nothing
+but itself, and the store's destination is kept relative to $HOME when it's in home
+so the script works for any user */
+func installerScript(conf config.Config, home string, groups []string) (string, error) {
+	general := conf.General
+	clone, err := setup.CloneCommand(general)
+	if err != nil {
+		return "", err
+	}
+	dest := shellQuote(general.DotfilesDest)
+	if rel, err := filepath.Rel(home, general.DotfilesDest); err == nil && !strings.HasPrefix(rel, "..") {
+		dest = `"$HOME"/` + shellQuote(filepath.ToSlash(rel))
+	}
+	var quoted []string
+	for _, word := range clone[:len(clone)-1] {
+		quoted = append(quoted, shellQuote(word))
+	}
+
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n# Written by tuckr export installer, running it sets up the dotfiles on a fresh machine\nset -e\n\n")
+	script.WriteString("command -v tuckr >/dev/null || { echo 'tuckr has to be installed first' >&2; exit 1; }\n\n")
+	script.WriteString("TUCKR_STORE=" + dest + "\nexport TUCKR_STORE\n")
+	script.WriteString("if [ ! -d \"$TUCKR_STORE/.git\" ]; then\n\t" + strings.Join(quoted, " ") + " \"$TUCKR_STORE\"\nfi\n")
+
+	lists := []struct {
+		path    string
+		install []string
+	}{
+		{conf.Packages.PkgList, nil},
+		{conf.Packages.PipList, []string{"pip", "install", "--user"}},
+		{conf.Packages.NpmList, []string{"npm", "install", "-g"}},
+		{conf.Packages.YarnList, []string{"yarn", "global", "add"}},
+	}
+	for _, list := range lists {
+		if list.path == "" {
+			continue
+		}
+		install := list.install
+		if install == nil {
+			if install, err = setup.SplitCommand(conf.Packages.PkgInstallCmd); err != nil {
+				return "", err
+			}
+			if len(install) == 0 {
+				return "", errors.New("Error: pkg_list is set but no pkg_install_cmd is set in config")
+			}
+		}
+		packages, err := readPackageList(list.path)
+		if err != nil {
+			return "", err
+		}
+		if len(packages) == 0 {
+			continue
+		}
+		var words []string
+		for _, word := range append(install, packages...) {
+			words = append(words, shellQuote(word))
+		}
+		script.WriteString("\n" + strings.Join(words, " ") + "\n")
+	}
+
+	var names []string
+	for _, group := range groups {
+		names = append(names, shellQuote(group))
+	}
+	script.WriteString("\ntuckr --store-format " + shellQuote(store.Format) + " set " + strings.Join(names, " ") + "\n")
+	return script.String(), nil
+}
+
+// Reads the packages of a list, separated by whitespace with # starting a comment
+func readPackageList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("Error: Could not read the package list " + path + ": " + err.Error())
+	}
+	defer f.Close()
+	var packages []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		packages = append(packages, strings.Fields(line)...)
+	}
+	return packages, scanner.Err()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportedInstallerClonesInstallsAndSets(t *testing.T) {
+	e := newTestEnv(t)
+	pkgs := writeTestFile(t, filepath.Join(e.dir, "pkgs"), "neovim git # editors\nzsh\n")
+	e.config(t, "[GENERAL]\ndotfiles_repo = https://example.com/me/dotfiles.git\ndotfiles_dest = "+e.inHome("src/dotfiles")+
+		"\n[PACKAGES]\npkg_install_cmd = apt-get install -y\npkg_list = "+pkgs+"\n")
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	e.write(t, "Configs/git/.gitconfig", "")
+	script := filepath.Join(e.dir, "install.sh")
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim", "zsh"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := runExport([]string{"installer", script}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	data, err := ioutil.ReadFile(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"'https://example.com/me/dotfiles.git'", `TUCKR_STORE="$HOME"/'src/dotfiles'`, " set 'vim' 'zsh'\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("the installer doesn't have %s:\n%s", want, data)
+		}
+	}
+
+	// Run on a fresh machine with stand-ins for the commands it runs
+	bin := filepath.Join(e.dir, "bin")
+	ran := filepath.Join(e.dir, "ran")
+	for _, name := range []string{"tuckr", "git", "apt-get"} {
+		writeTestFile(t, filepath.Join(bin, name), "#!/bin/sh\necho \""+name+" $*\" >> "+ran+"\n")
+		if err := os.Chmod(filepath.Join(bin, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fresh := filepath.Join(e.dir, "fresh")
+	cmd := exec.Command("sh", script)
+	cmd.Env = []string{"PATH=" + bin + ":/usr/bin:/bin", "HOME=" + fresh}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("the installer failed with %v:\n%s", err, output)
+	}
+	log, err := ioutil.ReadFile(ran)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "git clone https://example.com/me/dotfiles.git " + fresh + "/src/dotfiles\n" +
+		"apt-get install -y neovim git zsh\n" +
+		"tuckr --store-format tuckr set vim zsh\n"
+	if string(log) != want {
+		t.Errorf("the installer ran\n%s\nwant\n%s", log, want)
+	}
+}
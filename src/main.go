@@ -2,12 +2,160 @@ package main
 
 import (
 	"fmt"
-	//"github.com/raphgl/tuckr/setup"
-	//"github.com/raphgl/tuckr/manage"
-	//"os"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"strings"
 )
 
+const usage = `Usage: tuckr [--store-format tuckr|flat|stow] <command> [flags] [args]
+
+Commands:
+  set <group...>                     symlinks the groups into their targets and runs their setup scripts
+  sandbox <group...>                 sets the groups into a throwaway directory and prints where it is
+  unset <group...>                   removes the groups' symlinks and runs their unset scripts
+  unset <group> <file...>            removes only the symlinks of some files of a group
+  mv-group <old> <new>               renames a group and recreates its links if it's set
+  new-group <name>                   creates a group in Configs with a README and optionally a .tuckr.json
+  disable <group...>                 makes set skip the groups while keeping their links
+  enable <group...>                  makes set deploy the groups again
+  reset                              unsets all groups, optionally clones the store again and sets them
+  update                             pulls the store and sets the groups that changed
+  diff-store <ref-a> <ref-b>         lists the groups and files that differ between two refs of the store
+  plan [group...]                    lists the links set --reconcile would add, fix or remove
+  impact <group...>                  lists the files in the targets that setting the groups would replace
+  status [group...]                  shows which groups are linked and their problems
+  check                              validates the store without deploying anything
+  verify                             checks that the deployed store files still exist
+  fingerprint                        saves the hashes of every file in the store
+  verify-fingerprint                 lists the store files that changed since the fingerprint
+  audit-perms [group...]             lists the linked files of sensitive groups that are too permissive
+  suggest                            lists configs in home that no group manages and how to move them into one
+  groups                             lists the groups in the store and their metadata
+  resolve <group> <file>             prints where a file of a group gets linked to
+  scripts run <group> <script>       runs a single script of a group
+  apply <profile>                    sets the groups, installs the packages and runs the scripts of a profile
+  restore --list                     lists the generations of backups set made of taken targets
+  restore --from <id>                puts the files of a backup generation back over their targets
+  config diff-defaults               lists the config values that differ from the defaults
+  config init                        writes a config out of the answers to a few questions
+  serve --http <addr>                serves the deployment status as json for monitoring
+  selftest                           checks that symlinks can be created on this system
+  export installer <file.sh>         writes a shell script that clones the store, installs the packages and sets the groups
+  bundle export <out.tar.gz>         packages the store into a portable bundle
+  bundle import <in.tar.gz> [dest]   reconstructs a store from a bundle
+
+--store-format picks the directory convention of the store over the config's store_format
+tuckr keeps groups in Configs, Bin and Services, flat and stow keep them at the top of
+the store and deploy them into home or the store's parent directory respectively
+
+Run tuckr <command> -h to see the flags a command takes`
+
 func main() {
-	//manage.CreateSymlink("test.txt_link", "test.txt")
-	//manage.RemoveSymlink("test.txt_link")
+	cleanupOnSignal()
+	args, err := setGlobals(os.Args[1:])
+	if err != nil {
+		fmt.Println(aurora.Red(err))
+		os.Exit(1)
+	}
+	if len(args) < 1 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "set":
+		err = runSet(args[1:])
+	case "sandbox":
+		err = runSandbox(args[1:])
+	case "unset":
+		err = runUnset(args[1:])
+	case "mv-group":
+		err = runMvGroup(args[1:])
+	case "new-group":
+		err = runNewGroup(args[1:])
+	case "disable":
+		err = runDisable(args[1:], true)
+	case "enable":
+		err = runDisable(args[1:], false)
+	case "reset":
+		err = runReset(args[1:])
+	case "update":
+		err = runUpdate(args[1:])
+	case "diff-store":
+		err = runDiffStore(args[1:])
+	case "plan":
+		err = runPlan(args[1:])
+	case "impact":
+		err = runImpact(args[1:])
+	case "status":
+		err = runStatus(args[1:])
+	case "check":
+		err = runCheck(args[1:])
+	case "verify":
+		err = runVerify(args[1:])
+	case "fingerprint":
+		err = runFingerprint(args[1:])
+	case "verify-fingerprint":
+		err = runVerifyFingerprint(args[1:])
+	case "audit-perms":
+		err = runAuditPerms(args[1:])
+	case "suggest":
+		err = runSuggest(args[1:])
+	case "groups":
+		err = runGroups(args[1:])
+	case "resolve":
+		err = runResolve(args[1:])
+	case "scripts":
+		err = runScripts(args[1:])
+	case "apply":
+		err = runApply(args[1:])
+	case "restore":
+		err = runRestore(args[1:])
+	case "config":
+		err = runConfig(args[1:])
+	case "serve":
+		err = runServe(args[1:])
+	case "selftest":
+		err = runSelftest(args[1:])
+	case "export":
+		err = runExport(args[1:])
+	case "bundle":
+		err = runBundle(args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+	default:
+		fmt.Println(aurora.Red("Unknown command:"), args[0])
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	runCleanups()
+	if err != nil {
+		fmt.Println(aurora.Red(err))
+		os.Exit(1)
+	}
+}
+
+/* Applies the settings every command shares and returns args without the global flags
+The store's format comes from a leading --store-format in args or from the config,
+the filesystem concurrency and the umask come from the config. A broken config is left for the
+command to report */
+func setGlobals(args []string) ([]string, error) {
+	conf, err := config.LoadConfig()
+	if err != nil {
+		conf = config.Default()
+	}
+	manage.SetFSConcurrency(conf.General.FSConcurrency)
+	manage.SetUmask(os.FileMode(conf.General.Umask))
+	format := conf.General.StoreFormat
+	if len(args) > 0 && strings.HasPrefix(args[0], "--store-format=") {
+		format = strings.TrimPrefix(args[0], "--store-format=")
+		args = args[1:]
+	} else if len(args) > 1 && args[0] == "--store-format" {
+		format = args[1]
+		args = args[2:]
+	}
+	store.Format, err = store.ParseFormat(format)
+	return args, err
 }
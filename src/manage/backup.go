@@ -0,0 +1,147 @@
+package manage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Name of the file in a backup generation that maps its files to their targets
+const backupManifest = "manifest.json"
+
+/* The files a single run moved out of the way of its links
+Every generation is a directory named after when it was made inside of the
+backups directory, its directory only gets created once something is backed up */
+type Backup struct {
+	// Directory holding every generation
+	Root string
+	// When the generation was made, also what its ID is made from
+	Time time.Time
+	// ID of the generation, empty until the first file is backed up
+	ID string
+	// Maps the name of each backed up file in the generation to its target
+	Targets map[string]string
+}
+
+// Returns a generation inside of root that's made at now once something gets backed up
+func NewBackup(root string, now time.Time) *Backup {
+	return &Backup{Root: root, Time: now, Targets: map[string]string{}}
+}
+
+/* Moves target into the generation and records where it came from
+IDs are the generation's time down to the second, a number gets appended when
+another generation already took it */
+func (b *Backup) Save(target string) error {
+	if b.ID == "" {
+		if err := os.MkdirAll(b.Root, 0755); err != nil {
+			return err
+		}
+		id := b.Time.Format("20060102-150405")
+		for n := 1; ; n++ {
+			err := os.Mkdir(filepath.Join(b.Root, id), 0755)
+			if err == nil {
+				break
+			}
+			if !os.IsExist(err) {
+				return err
+			}
+			id = b.Time.Format("20060102-150405") + "." + strconv.Itoa(n)
+		}
+		b.ID = id
+	}
+	name := strconv.Itoa(len(b.Targets))
+	dest := filepath.Join(b.Root, b.ID, name)
+	if err := rename(target, dest); err != nil {
+		// the generation can be on another filesystem than the target
+		if err := CopyTree(target, dest); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+	b.Targets[name] = target
+	data, err := json.MarshalIndent(b.Targets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(b.Root, b.ID, backupManifest), data, 0644)
+}
+
+// Returns the IDs of the generations inside of root from the oldest to the newest
+func ListBackups(root string) ([]string, error) {
+	dir, err := readDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ids []string
+	for _, f := range dir {
+		if _, err := os.Stat(filepath.Join(root, f.Name(), backupManifest)); err == nil {
+			ids = append(ids, f.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Reads the generation with the ID inside of root
+func LoadBackup(root string, id string) (*Backup, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, id, backupManifest))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("Error: No backup " + id + " found")
+		}
+		return nil, err
+	}
+	b := &Backup{Root: root, ID: id}
+	if err := json.Unmarshal(data, &b.Targets); err != nil {
+		return nil, errors.New("Error: Backup " + id + " is malformed: " + err.Error())
+	}
+	return b, nil
+}
+
+/* Puts every file of the generation back at its target, replacing whatever is there,
+links included. The generation itself is kept so it can be restored again */
+func (b *Backup) Restore() error {
+	var names []string
+	for name := range b.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target := b.Targets[name]
+		src := filepath.Join(b.Root, b.ID, name)
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		if err := makeDirs(filepath.Dir(target), 0777); err != nil {
+			return err
+		}
+		info, err := os.Lstat(src)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			dest, err := os.Readlink(src)
+			if err == nil {
+				err = symlink(dest, target)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err := CopyTree(src, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package manage
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRestoreBringsBackTheChosenGeneration(t *testing.T) {
+	src, home := tempTree(t)
+	root := filepath.Join(filepath.Dir(src), "backups")
+	vimrc := filepath.Join(home, ".vimrc")
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var ids []string
+	for i, contents := range []string{"first", "second", "third"} {
+		writeFile(t, vimrc, contents)
+		// The last two are made within the same second
+		backup := NewBackup(root, start.Add(time.Duration((i+1)/2)*time.Hour))
+		if err := backup.Save(vimrc); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, backup.ID)
+	}
+	if want := []string{"20240501-120000", "20240501-130000", "20240501-130000.1"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("the generations got the IDs %v, want %v", ids, want)
+	}
+	if listed, err := ListBackups(root); err != nil || !reflect.DeepEqual(listed, ids) {
+		t.Errorf("ListBackups returned %v %v, want %v", listed, err, ids)
+	}
+
+	// What's at the target gets replaced, a link to the store included
+	writeFile(t, filepath.Join(src, ".vimrc"), "store")
+	linkFile(t, filepath.Join(src, ".vimrc"), vimrc)
+	for _, i := range []int{1, 0} {
+		backup, err := LoadBackup(root, ids[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := backup.Restore(); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"first", "second"}[i]
+		if data, err := ioutil.ReadFile(vimrc); err != nil || string(data) != want {
+			t.Errorf("restoring %s left %q %v, want %q", ids[i], data, err, want)
+		}
+	}
+	if _, err := LoadBackup(root, "20240501-140000"); err == nil {
+		t.Error("loading a generation that doesn't exist succeeded")
+	}
+}
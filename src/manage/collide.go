@@ -0,0 +1,26 @@
+package manage
+
+import (
+	"strings"
+)
+
+// Returns true if the filesystems used on goos are usually case-insensitive like on macOS and Windows
+func CaseInsensitive(goos string) bool {
+	return goos == "darwin" || goos == "windows"
+}
+
+/* Returns the pairs of links whose targets only differ in case
+On a case-insensitive filesystem both land on the same path and one overwrites the other */
+func CaseCollisions(links []Link) [][2]Link {
+	var collisions [][2]Link
+	seen := map[string]Link{}
+	for _, l := range links {
+		key := strings.ToLower(l.Target)
+		if other, ok := seen[key]; ok && other.Target != l.Target {
+			collisions = append(collisions, [2]Link{other, l})
+			continue
+		}
+		seen[key] = l
+	}
+	return collisions
+}
@@ -0,0 +1,36 @@
+package manage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCaseCollisionsFlagsTargetsDifferingInCase(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, ".Config", "app.conf"), "")
+	writeFile(t, filepath.Join(src, ".config", "app.conf"), "")
+	writeFile(t, filepath.Join(src, ".config", "other.conf"), "")
+
+	links, err := PlanLinks(src, home, PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := func(rel string) Link {
+		return Link{Source: filepath.Join(src, rel), Target: filepath.Join(home, rel)}
+	}
+	want := [][2]Link{{link(".Config/app.conf"), link(".config/app.conf")}}
+	if got := CaseCollisions(links); !reflect.DeepEqual(got, want) {
+		t.Errorf("CaseCollisions flagged %v, want %v", got, want)
+	}
+	// The same link twice isn't a collision
+	if got := CaseCollisions([]Link{link(".vimrc"), link(".vimrc")}); len(got) != 0 {
+		t.Errorf("CaseCollisions flagged %v for a single target", got)
+	}
+
+	for goos, want := range map[string]bool{"darwin": true, "windows": true, "linux": false, "freebsd": false} {
+		if got := CaseInsensitive(goos); got != want {
+			t.Errorf("CaseInsensitive(%q) = %v, want %v", goos, got, want)
+		}
+	}
+}
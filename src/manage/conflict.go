@@ -0,0 +1,144 @@
+package manage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// What to do with a link whose target is taken by another file
+const (
+	ResolveSkip = iota
+	ResolveOverwrite
+	ResolveBackup
+	ResolveAbort
+)
+
+// Decides how to resolve a link whose target is taken by another file
+type ConflictResolver func(l Link) (int, error)
+
+/* Returns a resolver that resolves every conflict the same way
+policy is one of skip, overwrite, backup or abort, an empty policy means skip */
+func PolicyResolver(policy string) (ConflictResolver, error) {
+	resolutions := map[string]int{
+		"":          ResolveSkip,
+		"skip":      ResolveSkip,
+		"overwrite": ResolveOverwrite,
+		"backup":    ResolveBackup,
+		"abort":     ResolveAbort,
+	}
+	resolution, ok := resolutions[strings.ToLower(policy)]
+	if !ok {
+		return nil, errors.New("Error: Unknown conflict policy " + policy + ", expected skip, overwrite, backup or abort")
+	}
+	return func(Link) (int, error) {
+		return resolution, nil
+	}, nil
+}
+
+/* Returns a resolver that prompts for how to resolve each conflict reading the
+answers from in, asking for a diff shows how the target differs from the store file */
+func PromptResolver(in io.Reader) ConflictResolver {
+	reader := bufio.NewReader(in)
+	return func(l Link) (int, error) {
+		fmt.Println(aurora.Yellow("Conflict:"), l.Target, "already exists")
+		for {
+			fmt.Print("[s]kip, [o]verwrite, [b]ackup and link, [d]iff, [a]bort: ")
+			answer, err := reader.ReadString('\n')
+			if err != nil && answer == "" {
+				if err == io.EOF {
+					return ResolveAbort, nil
+				}
+				return ResolveAbort, err
+			}
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "s":
+				return ResolveSkip, nil
+			case "o":
+				return ResolveOverwrite, nil
+			case "b":
+				return ResolveBackup, nil
+			case "a":
+				return ResolveAbort, nil
+			case "d":
+				if err := printDiff(l.Target, l.Source); err != nil {
+					fmt.Println(aurora.Red("Could not diff:"), err)
+				}
+			default:
+				fmt.Println(aurora.Red("Invalid option:"), strings.TrimSpace(answer))
+			}
+		}
+	}
+}
+
+// Prints the lines that differ between the files at a and b
+func printDiff(a string, b string) error {
+	before, err := ioutil.ReadFile(a)
+	if err != nil {
+		return err
+	}
+	after, err := ioutil.ReadFile(b)
+	if err != nil {
+		return err
+	}
+	fmt.Println("---", a)
+	fmt.Println("+++", b)
+	for _, line := range Diff(strings.Split(string(before), "\n"), strings.Split(string(after), "\n")) {
+		switch line[0] {
+		case '-':
+			fmt.Println(aurora.Red(line))
+		case '+':
+			fmt.Println(aurora.Green(line))
+		default:
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
+
+/* Returns a line diff turning a into b, each line prefixed by -, + or a space
+It's a plain longest common subsequence diff meant for small config files */
+func Diff(a []string, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+a[i])
+			i++
+		default:
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+"+b[j])
+	}
+	return diff
+}
@@ -0,0 +1,118 @@
+package manage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Runs f and returns what it printed to stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	os.Stdout = stdout
+	return <-done
+}
+
+// Creates a conflict for each name, a store file and a file of its own at the target
+func conflicts(t *testing.T, names ...string) []Link {
+	t.Helper()
+	src, home := tempTree(t)
+	var links []Link
+	for _, name := range names {
+		l := Link{Source: filepath.Join(src, name), Target: filepath.Join(home, name)}
+		writeFile(t, l.Source, "store "+name+"\n")
+		writeFile(t, l.Target, "mine "+name+"\n")
+		links = append(links, l)
+	}
+	return links
+}
+
+func TestPromptResolverResolvesEachConflictAsAnswered(t *testing.T) {
+	links := conflicts(t, "skip", "overwrite", "backup")
+	results := &Results{}
+	resolve := PromptResolver(strings.NewReader("s\no\nx\nd\nb\n"))
+	var err error
+	out := captureStdout(t, func() {
+		err = CreateLinksResolving(links, resolve, nil, results)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	skip, overwrite, backup := links[0], links[1], links[2]
+	if data, _ := ioutil.ReadFile(skip.Target); skip.IsLinked() || string(data) != "mine skip\n" {
+		t.Errorf("the skipped %s was changed", skip.Target)
+	}
+	if _, err := os.Stat(overwrite.Target + ".bak"); !overwrite.IsLinked() || err == nil {
+		t.Errorf("%s wasn't overwritten", overwrite.Target)
+	}
+	if data, _ := ioutil.ReadFile(backup.Target + ".bak"); !backup.IsLinked() || string(data) != "mine backup\n" {
+		t.Errorf("%s wasn't backed up and linked", backup.Target)
+	}
+	for _, want := range []string{"Invalid option:", "-mine backup", "+store backup"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q wasn't printed:\n%s", want, out)
+		}
+	}
+	if got := results.Targets(Skipped); !reflect.DeepEqual(got, []string{skip.Target}) {
+		t.Errorf("skipped %v", got)
+	}
+}
+
+func TestPromptResolverAbortLinksNothing(t *testing.T) {
+	links := conflicts(t, "first", "second")
+	resolve := PromptResolver(strings.NewReader("o\na\n"))
+	var err error
+	captureStdout(t, func() {
+		err = CreateLinksResolving(links, resolve, nil, &Results{})
+	})
+	if err == nil || !strings.Contains(err.Error(), "Aborted at "+links[1].Target) {
+		t.Errorf("aborting failed with %v", err)
+	}
+	for _, l := range links {
+		if l.IsLinked() {
+			t.Errorf("%s was linked after aborting", l.Target)
+		}
+	}
+}
+
+func TestPolicyResolver(t *testing.T) {
+	for policy, want := range map[string]int{"": ResolveSkip, "Backup": ResolveBackup, "overwrite": ResolveOverwrite, "abort": ResolveAbort} {
+		resolve, err := PolicyResolver(policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := resolve(Link{}); got != want {
+			t.Errorf("policy %q resolved to %d, want %d", policy, got, want)
+		}
+	}
+	if _, err := PolicyResolver("ask"); err == nil {
+		t.Error("an unknown policy was accepted")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := Diff([]string{"a", "b", "c"}, []string{"a", "c", "d"})
+	if want := []string{" a", "-b", " c", "+d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff returned %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,49 @@
+package manage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/* Copies src to dest keeping its permissions, directories are copied recursively
+Parent directories of dest are created as needed, see SetUmask for their permissions */
+func CopyTree(src string, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return makeDirs(target, info.Mode().Perm()|0700)
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src string, dest string, mode os.FileMode) error {
+	if err := makeDirs(filepath.Dir(dest), 0777); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dest, mode)
+}
@@ -0,0 +1,44 @@
+package manage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Umask applied to directories unless told otherwise, it leaves them readable by everyone
+const DefaultUmask = 0022
+
+// Permissions the directories tuckr creates for links and copies get, see SetUmask
+var dirMode os.FileMode = 0777 &^ DefaultUmask
+
+/* Sets the umask applied to the directories created for links and copies
+Unlike the process' umask it's applied exactly, so a umask of 077 keeps the trees
+tuckr creates private whatever umask it was started with */
+func SetUmask(mask os.FileMode) {
+	dirMode = 0777 &^ (mask & 0777)
+}
+
+/* Creates dir along with the parents it's missing like os.MkdirAll, giving the ones
+it creates the permissions of the umask set by SetUmask. dir itself gets perm masked by it */
+func makeDirs(dir string, perm os.FileMode) error {
+	var missing []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil || filepath.Dir(d) == d {
+			break
+		}
+		missing = append(missing, d)
+	}
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return err
+	}
+	for i, d := range missing {
+		mode := dirMode
+		if i == 0 {
+			mode &= perm
+		}
+		if err := os.Chmod(d, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package manage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCreatedDirectoriesGetTheUmask(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows has no umask")
+	}
+	t.Cleanup(func() { SetUmask(DefaultUmask) })
+	for _, test := range []struct {
+		umask os.FileMode
+		want  os.FileMode
+	}{{0077, 0700}, {0027, 0750}, {0, 0777}} {
+		src, home := tempTree(t)
+		writeFile(t, filepath.Join(src, ".config", "git", "config"), "")
+		writeFile(t, filepath.Join(src, "notes", "work", "todo.txt"), "")
+		if err := os.Mkdir(home, 0755); err != nil {
+			t.Fatal(err)
+		}
+		// Copied directories keep their own permissions, as far as the umask lets them
+		for _, rel := range []string{"notes", "notes/work"} {
+			if err := os.Chmod(filepath.Join(src, rel), 0777); err != nil {
+				t.Fatal(err)
+			}
+		}
+		SetUmask(test.umask)
+		if err := CreateLinks([]Link{{Source: filepath.Join(src, ".config", "git", "config"), Target: filepath.Join(home, ".config", "git", "config")}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := CopyTree(filepath.Join(src, "notes"), filepath.Join(home, "copies", "notes")); err != nil {
+			t.Fatal(err)
+		}
+		for _, rel := range []string{".config", ".config/git", "copies", "copies/notes", "copies/notes/work"} {
+			info, err := os.Stat(filepath.Join(home, rel))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != test.want {
+				t.Errorf("with the umask %03o ~/%s was created with %v, want %v", test.umask, rel, got, test.want)
+			}
+		}
+		// The directories that were already there are left as they are
+		if info, err := os.Stat(home); err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("the existing home was changed to %v %v", info.Mode().Perm(), err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package manage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The kinds of problems that can stop a link from being deployed correctly
+const (
+	// The target is a symlink that points to nothing
+	Broken = iota
+	// The target is taken by a file that's not a link to the store
+	Conflict
+	// The target links into the store but the store file no longer exists
+	Orphan
+	// The target is a link tuckr created into where the store was before it moved
+	Moved
+)
+
+// A link that's not deployed the way it should be
+type Problem struct {
+	Kind int
+	Link Link
+}
+
+func (p Problem) String() string {
+	switch p.Kind {
+	case Broken:
+		return "Broken link"
+	case Conflict:
+		return "Conflict"
+	case Moved:
+		return "Moved link"
+	default:
+		return "Orphan"
+	}
+}
+
+/* Checks the targets of links for problems
+Orphans are searched for all over the group's deployed target tree, looking for
+symlinks into src whose store file is gone. They come after the other problems
+sorted by path */
+func FindProblems(src string, links []Link) []Problem {
+	var problems []Problem
+	for _, l := range links {
+		if l.IsLinked() {
+			continue
+		}
+		info, err := os.Lstat(l.Target)
+		if err != nil {
+			continue
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(l.Target); err != nil {
+				problems = append(problems, Problem{Kind: Broken, Link: l})
+				continue
+			}
+		}
+		problems = append(problems, Problem{Kind: Conflict, Link: l})
+	}
+
+	for _, l := range strayLinks(src, links) {
+		if _, err := os.Stat(l.Source); os.IsNotExist(err) {
+			problems = append(problems, Problem{Kind: Orphan, Link: l})
+		}
+	}
+	return problems
+}
+
+/* Returns the symlinks into src in the tree the links deploy it into that none of
+the links want, sorted by target. Besides the directories holding a target, every
+directory under where each top level directory of src gets deployed is searched, so
+the links left in a directory whose store files are all gone are still found
+Directories linked whole aren't searched */
+func strayLinks(src string, links []Link) []Link {
+	expected := map[string]bool{}
+	dirs := map[string]bool{}
+	walked := map[string]bool{}
+	for _, l := range links {
+		expected[l.Target] = true
+		dirs[filepath.Dir(l.Target)] = true
+		rel, err := filepath.Rel(src, l.Source)
+		if err != nil || !IsWithin(l.Source, src) || !strings.HasSuffix(l.Target, string(filepath.Separator)+rel) {
+			continue
+		}
+		parts := strings.SplitN(rel, string(filepath.Separator), 2)
+		if len(parts) < 2 {
+			continue
+		}
+		top := filepath.Join(strings.TrimSuffix(l.Target, rel), parts[0])
+		if walked[top] {
+			continue
+		}
+		walked[top] = true
+		filepath.Walk(top, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() {
+				dirs[path] = true
+			}
+			return nil
+		})
+	}
+	var targets []string
+	stray := map[string]string{}
+	for dir := range dirs {
+		files, err := readDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			target := filepath.Join(dir, f.Name())
+			if expected[target] {
+				continue
+			}
+			dest, err := os.Readlink(target)
+			if err == nil && strings.HasPrefix(dest, src+string(filepath.Separator)) {
+				targets = append(targets, target)
+				stray[target] = dest
+			}
+		}
+	}
+	sort.Strings(targets)
+	var found []Link
+	for _, target := range targets {
+		found = append(found, Link{Source: stray[target], Target: target})
+	}
+	return found
+}
+
+// Replaces whatever is at the link's target with a symlink to its source
+func Relink(l Link) error {
+	if err := remove(l.Target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return symlink(l.Source, l.Target)
+}
+
+// Moves whatever is at the link's target to target.bak and links it
+func BackupAndLink(l Link) error {
+	if err := rename(l.Target, l.Target+".bak"); err != nil {
+		return err
+	}
+	return symlink(l.Source, l.Target)
+}
+
+/* Prompts for how to resolve each problem reading the answers from in
+Answering q stops resolving the remaining problems. Calls for several groups have
+to share in, a reader of their own would buffer away the answers meant for the next */
+func FixInteractively(problems []Problem, in *bufio.Reader) error {
+	for _, p := range problems {
+		fmt.Println(aurora.Yellow(p.String()+":"), p.Link.Target)
+		options := "[r]elink, [d]elete, [b]ackup and link, [s]kip, [q]uit"
+		if p.Kind == Orphan {
+			options = "[d]elete, [s]kip, [q]uit"
+		}
+		for {
+			fmt.Print(options, ": ")
+			answer, err := in.ReadString('\n')
+			if err != nil && answer == "" {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			if p.Kind == Orphan && (answer == "r" || answer == "b") {
+				answer = ""
+			}
+
+			err = nil
+			switch answer {
+			case "r":
+				err = Relink(p.Link)
+			case "d":
+				err = remove(p.Link.Target)
+			case "b":
+				err = BackupAndLink(p.Link)
+			case "s":
+			case "q":
+				return nil
+			default:
+				fmt.Println(aurora.Red("Invalid option:"), answer)
+				continue
+			}
+			if err != nil {
+				return errors.New("Error: Could not fix " + p.Link.Target + ": " + err.Error())
+			}
+			break
+		}
+	}
+	return nil
+}
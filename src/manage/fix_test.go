@@ -0,0 +1,139 @@
+package manage
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Creates a store directory and a target directory for it inside of a temporary directory
+func tempTree(t *testing.T) (string, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "tuckr-manage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "src"), filepath.Join(dir, "home")
+}
+
+// Writes contents to path creating its parent directories
+func writeFile(t *testing.T, path string, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Creates a symlink at target to dest creating its parent directories
+func linkFile(t *testing.T, dest string, target string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dest, target); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Returns the kind and target of every problem
+func describeProblems(problems []Problem) []string {
+	var described []string
+	for _, p := range problems {
+		described = append(described, p.String()+" "+p.Link.Target)
+	}
+	return described
+}
+
+func TestFindProblemsWalksTheDeployedTree(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, ".zshrc"), "")
+	writeFile(t, filepath.Join(src, ".config", "nvim", "init.vim"), "")
+	writeFile(t, filepath.Join(home, ".zshrc"), "mine")
+	linkFile(t, filepath.Join(src, ".config", "nvim", "init.vim"), filepath.Join(home, ".config", "nvim", "init.vim"))
+	// Every store file of .config/foo is gone so no link is left in that directory
+	orphan := filepath.Join(home, ".config", "foo", "bar.conf")
+	linkFile(t, filepath.Join(src, ".config", "foo", "bar.conf"), orphan)
+	linkFile(t, filepath.Join(src, "gone"), filepath.Join(home, "gone"))
+
+	links, err := PlanLinks(src, home, PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Conflict " + filepath.Join(home, ".zshrc"),
+		"Orphan " + orphan,
+		"Orphan " + filepath.Join(home, "gone"),
+	}
+	if got := describeProblems(FindProblems(src, links)); !reflect.DeepEqual(got, want) {
+		t.Errorf("problems %v, want %v", got, want)
+	}
+}
+
+func TestFixInteractivelyAppliesTheAnswers(t *testing.T) {
+	src, home := tempTree(t)
+	for _, name := range []string{"relink", "backup", "skip"} {
+		writeFile(t, filepath.Join(src, name), name)
+	}
+	relink := Link{Source: filepath.Join(src, "relink"), Target: filepath.Join(home, "relink")}
+	backup := Link{Source: filepath.Join(src, "backup"), Target: filepath.Join(home, "backup")}
+	skip := Link{Source: filepath.Join(src, "skip"), Target: filepath.Join(home, "skip")}
+	orphan := Link{Source: filepath.Join(src, "orphan"), Target: filepath.Join(home, "orphan")}
+	linkFile(t, filepath.Join(src, "moved"), relink.Target)
+	writeFile(t, backup.Target, "mine")
+	writeFile(t, skip.Target, "mine")
+	linkFile(t, orphan.Source, orphan.Target)
+	problems := []Problem{
+		{Kind: Broken, Link: relink},
+		{Kind: Conflict, Link: backup},
+		{Kind: Orphan, Link: orphan},
+		{Kind: Conflict, Link: skip},
+	}
+
+	// The invalid answer is asked again instead of moving on to the next problem
+	answers := bufio.NewReader(strings.NewReader("r\nx\nb\nd\ns\n"))
+	if err := FixInteractively(problems, answers); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []Link{relink, backup} {
+		if !l.IsLinked() {
+			t.Errorf("%s wasn't linked", l.Target)
+		}
+	}
+	if data, err := ioutil.ReadFile(backup.Target + ".bak"); err != nil || string(data) != "mine" {
+		t.Errorf("%s wasn't backed up: %q %v", backup.Target, data, err)
+	}
+	if _, err := os.Lstat(orphan.Target); !os.IsNotExist(err) {
+		t.Errorf("the orphan %s wasn't deleted", orphan.Target)
+	}
+	if data, err := ioutil.ReadFile(skip.Target); err != nil || string(data) != "mine" {
+		t.Errorf("the skipped %s was changed", skip.Target)
+	}
+}
+
+func TestFixInteractivelyStopsOnQuit(t *testing.T) {
+	src, home := tempTree(t)
+	first := Link{Source: filepath.Join(src, "first"), Target: filepath.Join(home, "first")}
+	second := Link{Source: filepath.Join(src, "second"), Target: filepath.Join(home, "second")}
+	for _, l := range []Link{first, second} {
+		writeFile(t, l.Source, "")
+		writeFile(t, l.Target, "mine")
+	}
+	answers := bufio.NewReader(strings.NewReader("q\nr\n"))
+	if err := FixInteractively([]Problem{{Kind: Conflict, Link: first}, {Kind: Conflict, Link: second}}, answers); err != nil {
+		t.Fatal(err)
+	}
+	if first.IsLinked() || second.IsLinked() {
+		t.Error("problems were fixed after quitting")
+	}
+}
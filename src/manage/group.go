@@ -0,0 +1,250 @@
+package manage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A symlink that should point from Target to the Source file in the store
+type Link struct {
+	Source string
+	Target string
+}
+
+// Options that change which links PlanLinks comes up with
+type PlanOptions struct {
+	// Directories, relative to src, that are linked whole instead of file by file
+	WholeDirs []string
+	// Symlinks in src get linked to the file they point to instead of to themselves
+	Dereference bool
+	// Files more than MaxDepth directories deep in src are left unlinked, 0 links every file
+	MaxDepth int
+}
+
+// Swapped out by tests to count how often a group is walked
+var walk = filepath.Walk
+
+// The links planned for a group along with the mtimes of the directories walked to plan them
+type cachedPlan struct {
+	dirs  map[string]time.Time
+	links []Link
+}
+
+/* Plans keyed by their src, dest and options
+Adding, removing or renaming a file changes the mtime of the directory it's in, so
+a plan is only made again once one of the directories walked for it has a different
+mtime. Plans that dereference symlinks depend on more than names so they aren't
+cached, neither are ones with a directory changed less than a second before the
+walk since a change right after in the same tick of the filesystem's clock would
+go unnoticed */
+var planCache = struct {
+	sync.Mutex
+	entries map[string]cachedPlan
+}{entries: map[string]cachedPlan{}}
+
+// Returns the cached links for key if none of the directories walked for them changed
+func cachedLinks(key string) ([]Link, bool) {
+	planCache.Lock()
+	cached, ok := planCache.entries[key]
+	planCache.Unlock()
+	if !ok {
+		return nil, false
+	}
+	for dir, modTime := range cached.dirs {
+		if info, err := lstat(dir); err != nil || !info.ModTime().Equal(modTime) {
+			return nil, false
+		}
+	}
+	return append([]Link(nil), cached.links...), true
+}
+
+/* Walks src and returns the links needed to mirror every file in it under dest
+Directories are never linked themselves, only the files inside of them, unless
+they're listed in the options' WholeDirs. Symlinks are linked as they are unless
+the options ask to dereference them and files deeper than their MaxDepth are left
+out, at depth 1 only the files at the top of src get linked. dest has to be a
+directory if it exists. Plans are cached, see planCache */
+func PlanLinks(src string, dest string, opts PlanOptions) ([]Link, error) {
+	if info, err := os.Stat(dest); err == nil && !info.IsDir() {
+		return nil, errors.New("Error: The destination " + dest + " is a file, it has to be a directory to link " + src + " into")
+	}
+	key := fmt.Sprintf("%q %q %q %d", src, dest, opts.WholeDirs, opts.MaxDepth)
+	if !opts.Dereference {
+		if links, ok := cachedLinks(key); ok {
+			return links, nil
+		}
+	}
+	var links []Link
+	dirs := map[string]time.Time{}
+	wholeDirs := map[string]bool{}
+	for _, dir := range opts.WholeDirs {
+		wholeDirs[filepath.Clean(dir)] = true
+	}
+	started := time.Now()
+	err := walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		depth := len(strings.Split(rel, string(filepath.Separator)))
+		if info.IsDir() {
+			if !wholeDirs[rel] {
+				if opts.MaxDepth > 0 && rel != "." && depth >= opts.MaxDepth {
+					return filepath.SkipDir
+				}
+				dirs[path] = info.ModTime()
+				return nil
+			}
+			links = append(links, Link{Source: path, Target: filepath.Join(dest, rel)})
+			return filepath.SkipDir
+		}
+		source := path
+		if opts.Dereference && info.Mode()&os.ModeSymlink != 0 {
+			if source, err = filepath.EvalSymlinks(path); err != nil {
+				return errors.New("Error: Could not dereference " + path + ": " + err.Error())
+			}
+		}
+		links = append(links, Link{Source: source, Target: filepath.Join(dest, rel)})
+		return nil
+	})
+	if err != nil || opts.Dereference {
+		return links, err
+	}
+	for _, modTime := range dirs {
+		if modTime.After(started.Add(-time.Second)) {
+			return links, nil
+		}
+	}
+	planCache.Lock()
+	planCache.entries[key] = cachedPlan{dirs: dirs, links: append([]Link(nil), links...)}
+	planCache.Unlock()
+	return links, nil
+}
+
+/* Returns target with the symlinks among its existing parent directories resolved
+so links land in the real directory, target itself is left alone since it's
+where the link goes. Parents that don't exist yet are kept as they are */
+func ResolveTarget(target string) string {
+	dir := filepath.Dir(target)
+	var missing []string
+	for {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = real
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return target
+		}
+		missing = append([]string{filepath.Base(dir)}, missing...)
+		dir = parent
+	}
+	return filepath.Join(append(append([]string{dir}, missing...), filepath.Base(target))...)
+}
+
+// Returns true if the link's target is already a symlink to its source
+func (l Link) IsLinked() bool {
+	dest, err := os.Readlink(l.Target)
+	return err == nil && dest == l.Source
+}
+
+/* Creates every link, making parent directories as needed
+Links that already exist are left alone and targets taken by other files are skipped */
+func CreateLinks(links []Link) error {
+	return CreateLinksResolving(links, nil, nil, nil)
+}
+
+/* Same as CreateLinks but asks resolve what to do with targets taken by other files
+A nil resolve skips them. Backed up targets are moved into backup, or to target.bak
+when it's nil. What happens to each link is recorded into results
+Conflicts are resolved one at a time since resolving can prompt, the links are then
+created with at most the limit set by SetFSConcurrency being created at once */
+func CreateLinksResolving(links []Link, resolve ConflictResolver, backup *Backup, results *Results) error {
+	var pending []Link
+	var outcomes []int
+	for _, l := range links {
+		if l.IsLinked() {
+			continue
+		}
+		outcome := Created
+		if _, err := os.Lstat(l.Target); err == nil {
+			resolution := ResolveSkip
+			if resolve != nil {
+				if resolution, err = resolve(l); err != nil {
+					return err
+				}
+			}
+			switch resolution {
+			case ResolveOverwrite:
+				if err := os.RemoveAll(l.Target); err != nil {
+					return err
+				}
+			case ResolveBackup:
+				if backup != nil {
+					err = backup.Save(l.Target)
+				} else {
+					err = rename(l.Target, l.Target+".bak")
+				}
+				if err != nil {
+					return err
+				}
+				outcome = BackedUp
+			case ResolveAbort:
+				return errors.New("Error: Aborted at " + l.Target)
+			default:
+				fmt.Println(aurora.Red("Skipping:"), l.Target, "already exists")
+				results.Add(Skipped, l.Target)
+				continue
+			}
+		}
+		pending = append(pending, l)
+		outcomes = append(outcomes, outcome)
+	}
+	return forEachLimited(len(pending), fsConcurrency, func(i int) error {
+		l := pending[i]
+		if err := makeDirs(filepath.Dir(l.Target), 0777); err != nil {
+			return err
+		}
+		if err := symlink(l.Source, l.Target); err != nil {
+			return err
+		}
+		results.Add(outcomes[i], l.Target)
+		return nil
+	})
+}
+
+// Removes every link whose target is a symlink to its source, anything else is left alone
+func RemoveLinks(links []Link) error {
+	for _, l := range links {
+		if !l.IsLinked() {
+			continue
+		}
+		if err := remove(l.Target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns true if path is dir or somewhere inside of it once both are made absolute
+func IsWithin(path string, dir string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,158 @@
+package manage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlanLinksLinksWholeDirsAsOne(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, ".vimrc"), "")
+	writeFile(t, filepath.Join(src, ".vim", "plugins", "fugitive", "plugin.vim"), "")
+	writeFile(t, filepath.Join(src, ".vim", "plugins", "surround.vim"), "")
+	writeFile(t, filepath.Join(src, ".vim", "colors", "dark.vim"), "")
+
+	links, err := PlanLinks(src, home, PlanOptions{WholeDirs: []string{filepath.Join(".vim", "plugins")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := func(rel string) Link {
+		return Link{Source: filepath.Join(src, rel), Target: filepath.Join(home, rel)}
+	}
+	want := []Link{link(".vim/colors/dark.vim"), link(".vim/plugins"), link(".vimrc")}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("PlanLinks planned %v, want %v", links, want)
+	}
+	if err := CreateLinks(links); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range want {
+		if !l.IsLinked() {
+			t.Errorf("%s wasn't linked", l.Target)
+		}
+	}
+	if (Link{Source: filepath.Join(src, ".vim", "colors"), Target: filepath.Join(home, ".vim", "colors")}).IsLinked() {
+		t.Error("a directory that isn't marked was linked whole")
+	}
+}
+
+func TestPlanLinksDereferencesStoreSymlinksOnlyWhenAsked(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, ".vimrc"), "")
+	linkFile(t, ".vimrc", filepath.Join(src, ".exrc"))
+	for _, dereference := range []bool{false, true} {
+		links, err := PlanLinks(src, home, PlanOptions{Dereference: dereference})
+		if err != nil {
+			t.Fatal(err)
+		}
+		source := filepath.Join(src, ".exrc")
+		if dereference {
+			source = filepath.Join(src, ".vimrc")
+		}
+		want := []Link{
+			{Source: source, Target: filepath.Join(home, ".exrc")},
+			{Source: filepath.Join(src, ".vimrc"), Target: filepath.Join(home, ".vimrc")},
+		}
+		if !reflect.DeepEqual(links, want) {
+			t.Errorf("PlanLinks with Dereference %v planned %v, want %v", dereference, links, want)
+		}
+	}
+
+	linkFile(t, "missing", filepath.Join(src, ".gone"))
+	if _, err := PlanLinks(src, home, PlanOptions{Dereference: true}); err == nil {
+		t.Error("dereferencing a broken symlink succeeded")
+	}
+}
+
+func TestResolveTargetResolvesSymlinkedParents(t *testing.T) {
+	src, home := tempTree(t)
+	real := filepath.Join(src, "..", "dotconfig")
+	if err := os.MkdirAll(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+	real, _ = filepath.EvalSymlinks(real)
+	linkFile(t, real, filepath.Join(home, ".config"))
+	tests := map[string]string{
+		filepath.Join(home, ".config", "git", "config"): filepath.Join(real, "git", "config"),
+		filepath.Join(home, ".config", "init.vim"):      filepath.Join(real, "init.vim"),
+		filepath.Join(home, ".config"):                  filepath.Join(home, ".config"),
+		filepath.Join(home, ".vimrc"):                   filepath.Join(home, ".vimrc"),
+		filepath.Join(home, "missing", "dir", ".rc"):    filepath.Join(home, "missing", "dir", ".rc"),
+	}
+	for target, want := range tests {
+		if got := ResolveTarget(target); got != want {
+			t.Errorf("ResolveTarget(%s) = %s, want %s", target, got, want)
+		}
+	}
+}
+
+func TestPlanLinksOnlyWalksAgainOnceADirectoryChanges(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, ".vimrc"), "")
+	writeFile(t, filepath.Join(src, ".config", "nvim", "init.vim"), "")
+	// Directories changed just before a walk aren't trusted so the tree is made older
+	old := func(age time.Duration, dirs ...string) {
+		t.Helper()
+		then := time.Now().Add(-age)
+		for _, dir := range dirs {
+			if err := os.Chtimes(dir, then, then); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	nvim := filepath.Join(src, ".config", "nvim")
+	old(2*time.Hour, src, filepath.Join(src, ".config"), nvim)
+	walks := 0
+	walk = func(root string, f filepath.WalkFunc) error {
+		walks++
+		return filepath.Walk(root, f)
+	}
+	t.Cleanup(func() { walk = filepath.Walk })
+	plan := func(opts PlanOptions) []Link {
+		t.Helper()
+		links, err := PlanLinks(src, home, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return links
+	}
+
+	first := plan(PlanOptions{})
+	if again := plan(PlanOptions{}); walks != 1 || !reflect.DeepEqual(again, first) {
+		t.Errorf("planning an unchanged group twice walked it %d times and planned %v then %v", walks, first, again)
+	}
+	plan(PlanOptions{MaxDepth: 1})
+	plan(PlanOptions{Dereference: true})
+	plan(PlanOptions{Dereference: true})
+	if walks != 4 {
+		t.Errorf("planning with other options walked %d times, want 4", walks)
+	}
+
+	writeFile(t, filepath.Join(nvim, "lsp.vim"), "")
+	old(time.Hour, nvim)
+	if links := plan(PlanOptions{}); walks != 5 || len(links) != len(first)+1 {
+		t.Errorf("adding a nested file walked %d times and planned %v", walks, links)
+	}
+	plan(PlanOptions{})
+	if walks != 5 {
+		t.Errorf("the new plan wasn't cached, walked %d times", walks)
+	}
+}
+
+func TestPlanLinksRefusesAFileAsTheDestination(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, ".vimrc"), "")
+	dest := filepath.Join(home, "notes.txt")
+	writeFile(t, dest, "")
+	_, err := PlanLinks(src, dest, PlanOptions{})
+	if want := "Error: The destination " + dest + " is a file, it has to be a directory to link " + src + " into"; err == nil || err.Error() != want {
+		t.Errorf("planning into a file failed with %v, want %q", err, want)
+	}
+	// A destination that doesn't exist yet gets made when linking
+	if _, err := PlanLinks(src, filepath.Join(home, "new"), PlanOptions{}); err != nil {
+		t.Errorf("planning into a missing destination failed with %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package manage
+
+import "sync"
+
+// How many filesystem operations linking runs at once unless told otherwise
+const DefaultFSConcurrency = 4
+
+// How many filesystem operations linking runs at once, see SetFSConcurrency
+var fsConcurrency = DefaultFSConcurrency
+
+/* Sets how many filesystem operations linking can have in flight at once
+Slow or network filesystems do better with a low limit, anything below 1 is taken as 1 */
+func SetFSConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	fsConcurrency = n
+}
+
+/* Runs op for every index below n with at most limit of them running at once
+Nothing new is started once an op fails and the first error is returned */
+func forEachLimited(n int, limit int, op func(i int) error) error {
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var first error
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		mu.Lock()
+		failed := first != nil
+		mu.Unlock()
+		if failed {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := op(i); err != nil {
+				mu.Lock()
+				if first == nil {
+					first = err
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return first
+}
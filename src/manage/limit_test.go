@@ -0,0 +1,59 @@
+package manage
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCreatingLinksKeepsToTheFSLimit(t *testing.T) {
+	src, home := tempTree(t)
+	var links []Link
+	for i := 0; i < 24; i++ {
+		name := ".rc" + strconv.Itoa(i)
+		writeFile(t, filepath.Join(src, name), "")
+		links = append(links, Link{Source: filepath.Join(src, name), Target: filepath.Join(home, "dir"+strconv.Itoa(i%3), name)})
+	}
+	var mu sync.Mutex
+	inFlight, most := 0, 0
+	osSymlink = func(source string, target string) error {
+		mu.Lock()
+		inFlight++
+		if inFlight > most {
+			most = inFlight
+		}
+		mu.Unlock()
+		// long enough for the others to pile up if nothing held them back
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return os.Symlink(source, target)
+	}
+	t.Cleanup(func() {
+		osSymlink = os.Symlink
+		SetFSConcurrency(DefaultFSConcurrency)
+	})
+
+	for _, limit := range []int{1, 3} {
+		most = 0
+		SetFSConcurrency(limit)
+		if err := RemoveLinks(links); err != nil {
+			t.Fatal(err)
+		}
+		if err := CreateLinks(links); err != nil {
+			t.Fatal(err)
+		}
+		if most != limit {
+			t.Errorf("with a limit of %d up to %d links were created at once", limit, most)
+		}
+		for _, l := range links {
+			if !l.IsLinked() {
+				t.Errorf("%s wasn't linked with a limit of %d", l.Target, limit)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package manage
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Makes readDir list directories in an order picked by seed instead of by name for the rest of the test
+func shuffleReadDir(t *testing.T, seed int64) {
+	t.Helper()
+	shuffle := rand.New(rand.NewSource(seed))
+	readDir = func(dir string) ([]os.FileInfo, error) {
+		files, err := ioutil.ReadDir(dir)
+		shuffle.Shuffle(len(files), func(i, j int) { files[i], files[j] = files[j], files[i] })
+		return files, err
+	}
+	t.Cleanup(func() { readDir = ioutil.ReadDir })
+}
+
+func TestDirectoryListingsDontDependOnTheFSOrder(t *testing.T) {
+	src, home := tempTree(t)
+	var orphans []string
+	for _, rel := range []string{"gone", "a", "z", ".config/d/x", ".config/d/b", ".config/c/y", ".config/e"} {
+		target := filepath.Join(home, filepath.FromSlash(rel))
+		linkFile(t, filepath.Join(src, rel), target)
+		orphans = append(orphans, "Orphan "+target)
+	}
+	sort.Strings(orphans)
+	writeFile(t, filepath.Join(src, ".zshrc"), "")
+	writeFile(t, filepath.Join(src, ".config", "keep"), "")
+	links, err := PlanLinks(src, home, PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backups := filepath.Join(home, "backups")
+	ids := []string{"20240501-120000", "20240501-130000", "20240501-130000.1", "20240502-090000"}
+	for _, id := range ids {
+		writeFile(t, filepath.Join(backups, id, backupManifest), "{}")
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		shuffleReadDir(t, seed)
+		if got := describeProblems(FindProblems(src, links)); !reflect.DeepEqual(got, orphans) {
+			t.Errorf("listed in the order of seed %d the problems are %v, want %v", seed, got, orphans)
+		}
+		if got, err := ListBackups(backups); err != nil || !reflect.DeepEqual(got, ids) {
+			t.Errorf("listed in the order of seed %d the backups are %v %v, want %v", seed, got, err, ids)
+		}
+	}
+}
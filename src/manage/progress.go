@@ -0,0 +1,75 @@
+package manage
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// How many characters wide the bar of a Progress is
+const progressWidth = 30
+
+/* Shows how far along creating a batch of links is, fed with the outcomes a Results
+records through Watch. On a terminal it's a bar redrawn in place, elsewhere a plain
+line gets printed every time another tenth of the links is done */
+type Progress struct {
+	w      io.Writer
+	label  string
+	total  int
+	done   int
+	tty    bool
+	drawn  bool
+	tenths int
+}
+
+// Returns a progress for total links that gets drawn into w
+func NewProgress(w io.Writer, label string, total int, tty bool) *Progress {
+	return &Progress{w: w, label: label, total: total, tty: tty}
+}
+
+// Counts one more link as done whatever its outcome was, it's meant to be passed to Results.Watch
+func (p *Progress) Record(outcome int, target string) {
+	if p.done < p.total {
+		p.done++
+	}
+	if p.tty {
+		// skips are reported while conflicts get resolved, before any link is created,
+		// so the bar only starts being drawn once the lines about them are done
+		if outcome != Skipped {
+			fmt.Fprint(p.w, "\r"+p.bar())
+			p.drawn = true
+		}
+		return
+	}
+	if tenths := p.done * 10 / p.total; tenths > p.tenths {
+		p.tenths = tenths
+		fmt.Fprintln(p.w, p.label+": "+p.count())
+	}
+}
+
+// Returns how much of the links are done, from 0 to 1
+func (p *Progress) Fraction() float64 {
+	if p.total == 0 {
+		return 1
+	}
+	return float64(p.done) / float64(p.total)
+}
+
+// Ends the bar's line so the output after it starts on its own line
+func (p *Progress) Finish() {
+	if p.drawn {
+		fmt.Fprintln(p.w)
+	}
+}
+
+// Returns the bar as it's drawn on a terminal
+func (p *Progress) bar() string {
+	filled := int(p.Fraction() * progressWidth)
+	return p.label + " [" + strings.Repeat("#", filled) + strings.Repeat(" ", progressWidth-filled) + "] " + p.count()
+}
+
+// Returns how many of the links are done out of all of them
+func (p *Progress) count() string {
+	return strconv.Itoa(p.done) + "/" + strconv.Itoa(p.total)
+}
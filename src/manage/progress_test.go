@@ -0,0 +1,66 @@
+package manage
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProgressCountsEveryRecordedOutcome(t *testing.T) {
+	for _, tty := range []bool{false, true} {
+		var out bytes.Buffer
+		results := &Results{}
+		progress := NewProgress(&out, "vim", 20, tty)
+		if progress.Fraction() != 0 {
+			t.Errorf("a new progress is %v done", progress.Fraction())
+		}
+		stop := results.Watch(progress.Record)
+		for i := 0; i < 5; i++ {
+			results.Add(Skipped, "skipped"+strconv.Itoa(i))
+		}
+		for i := 0; i < 9; i++ {
+			results.Add(Created, "created"+strconv.Itoa(i))
+		}
+		results.Add(BackedUp, "backed up")
+		if got := progress.Fraction(); got != 0.75 {
+			t.Errorf("15 out of 20 links left the progress %v done", got)
+		}
+		results.Fail("failed", errors.New("could not link"))
+		for i := 0; i < 4; i++ {
+			results.Add(Created, "rest"+strconv.Itoa(i))
+		}
+		// What's recorded past the total doesn't go over it
+		results.Add(Created, "extra")
+		if got := progress.Fraction(); got != 1 {
+			t.Errorf("every link left the progress %v done", got)
+		}
+		stop()
+		progress.Finish()
+		results.Add(Created, "after")
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if !tty {
+			if len(lines) != 10 || lines[9] != "vim: 20/20" || lines[0] != "vim: 2/20" || lines[7] != "vim: 16/20" {
+				t.Errorf("the plain progress was printed as %q, want a line per tenth", lines)
+			}
+			continue
+		}
+		if len(lines) != 1 || !strings.HasSuffix(out.String(), "\rvim ["+strings.Repeat("#", progressWidth)+"] 20/20\n") {
+			t.Errorf("the bar wasn't redrawn on a single line:\n%q", out.String())
+		}
+		// Skips happen before anything gets drawn so the bar starts at the first link
+		if !strings.HasPrefix(out.String(), "\rvim [") || strings.Contains(out.String(), "] 5/20") {
+			t.Errorf("the bar was drawn for the skips:\n%q", out.String())
+		}
+	}
+}
+
+func TestProgressOfNoLinksIsDone(t *testing.T) {
+	var out bytes.Buffer
+	progress := NewProgress(&out, "vim", 0, true)
+	progress.Finish()
+	if progress.Fraction() != 1 || out.Len() != 0 {
+		t.Errorf("an empty progress is %v done and drew %q", progress.Fraction(), out.String())
+	}
+}
@@ -0,0 +1,109 @@
+package manage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// The operations a reconcile plan can be made of
+const (
+	// The target is free and gets linked
+	AddLink = iota
+	// The target is a broken symlink or one to the wrong file of the store and gets linked again
+	FixLink
+	// The target is a symlink into the store that none of the links want anymore and gets removed
+	RemoveLink
+)
+
+// A single change needed to bring a target in line with the links
+type Operation struct {
+	Kind int
+	Link Link
+}
+
+func (o Operation) String() string {
+	switch o.Kind {
+	case AddLink:
+		return "Add link"
+	case FixLink:
+		return "Fix link"
+	default:
+		return "Remove link"
+	}
+}
+
+// The operations needed to go from what's in the targets to the links, nothing else
+type PlanResult struct {
+	Operations []Operation
+}
+
+/* Compares the links with what's at their targets and returns only the operations
+needed to make them match. Linked targets need nothing and targets taken by files
+or by symlinks out of src are left to the conflict policy, so neither is part of
+the plan. Symlinks into src anywhere in the tree the links deploy src into that no
+link wants are removed, sorted by path after the other operations */
+func Reconcile(src string, links []Link) PlanResult {
+	var plan PlanResult
+	for _, l := range links {
+		if l.IsLinked() {
+			continue
+		}
+		info, err := os.Lstat(l.Target)
+		if err != nil {
+			plan.Operations = append(plan.Operations, Operation{Kind: AddLink, Link: l})
+			continue
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		dest, err := os.Readlink(l.Target)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(l.Target); err != nil || IsWithin(dest, src) {
+			plan.Operations = append(plan.Operations, Operation{Kind: FixLink, Link: l})
+		}
+	}
+	for _, l := range strayLinks(src, links) {
+		plan.Operations = append(plan.Operations, Operation{Kind: RemoveLink, Link: l})
+	}
+	return plan
+}
+
+/* Carries out the operations of the plan, recording the links it creates into results
+Operations whose target already got where they would take it are skipped, so applying
+the same plan twice does nothing the second time */
+func (p PlanResult) Apply(results *Results) error {
+	for _, op := range p.Operations {
+		l := op.Link
+		switch op.Kind {
+		case AddLink:
+			if l.IsLinked() {
+				continue
+			}
+			if err := makeDirs(filepath.Dir(l.Target), 0777); err != nil {
+				return err
+			}
+			if err := symlink(l.Source, l.Target); err != nil {
+				return err
+			}
+			results.Add(Created, l.Target)
+		case FixLink:
+			if l.IsLinked() {
+				continue
+			}
+			if err := Relink(l); err != nil {
+				return err
+			}
+			results.Add(Created, l.Target)
+		case RemoveLink:
+			if dest, err := os.Readlink(l.Target); err != nil || dest != l.Source {
+				continue
+			}
+			if err := remove(l.Target); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package manage
+
+import (
+	"strconv"
+	"sync"
+)
+
+// The outcomes a link can have once deploying it was attempted
+const (
+	// The link was created, replacing whatever was at its target if it had to
+	Created = iota
+	// The target was taken and left alone
+	Skipped
+	// The target was taken and moved into a backup before linking
+	BackedUp
+	// The link could not be created
+	Errored
+)
+
+/* Collects the outcome of every link of a run for the summary at the end
+It's safe to use from several goroutines at once and a nil Results records nothing
+Every outcome is also passed on to the functions watching it as it's recorded */
+type Results struct {
+	mu       sync.Mutex
+	targets  [Errored + 1][]string
+	errors   []error
+	watchers []*func(outcome int, target string)
+}
+
+// Records that the link at target ended up with outcome
+func (r *Results) Add(outcome int, target string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.targets[outcome] = append(r.targets[outcome], target)
+	r.notify(outcome, target)
+}
+
+// Records that the link at target could not be created because of err
+func (r *Results) Fail(target string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.targets[Errored] = append(r.targets[Errored], target)
+	r.errors = append(r.errors, err)
+	r.notify(Errored, target)
+}
+
+/* Calls watch with every outcome recorded from now on until the returned function is called
+The outcomes are passed one at a time so watch doesn't have to lock anything, but it
+can't use the Results itself */
+func (r *Results) Watch(watch func(outcome int, target string)) func() {
+	if r == nil {
+		return func() {}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watchers = append(r.watchers, &watch)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for i, w := range r.watchers {
+			if w == &watch {
+				r.watchers = append(r.watchers[:i], r.watchers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Passes an outcome on to the watchers, r.mu has to be held
+func (r *Results) notify(outcome int, target string) {
+	for _, w := range r.watchers {
+		(*w)(outcome, target)
+	}
+}
+
+// Returns the targets recorded with outcome in the order they were recorded
+func (r *Results) Targets(outcome int) []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.targets[outcome]...)
+}
+
+// Returns how many targets were recorded with outcome
+func (r *Results) Count(outcome int) int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.targets[outcome])
+}
+
+// Returns the errors recorded with Fail
+func (r *Results) Errors() []error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]error{}, r.errors...)
+}
+
+// Returns a one line summary of how many links had each outcome
+func (r *Results) String() string {
+	return strconv.Itoa(r.Count(Created)) + " created, " +
+		strconv.Itoa(r.Count(Skipped)) + " skipped, " +
+		strconv.Itoa(r.Count(BackedUp)) + " backed up, " +
+		strconv.Itoa(r.Count(Errored)) + " failed"
+}
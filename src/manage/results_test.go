@@ -0,0 +1,68 @@
+package manage
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Meant to be run with go test -race, which catches any access the mutex doesn't cover
+func TestResultsAddsUpConcurrentOutcomes(t *testing.T) {
+	results := &Results{}
+	watched := map[int]int{}
+	stop := results.Watch(func(outcome int, target string) {
+		watched[outcome]++
+	})
+	const workers, each = 8, 400
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				target := strconv.Itoa(w) + "/" + strconv.Itoa(i)
+				switch i % 4 {
+				case 3:
+					results.Fail(target, errors.New("could not link "+target))
+				default:
+					results.Add(i%4, target)
+				}
+				// Reading while others write is just as safe
+				results.Count(Created)
+				_ = results.String()
+			}
+		}(w)
+	}
+	wg.Wait()
+	stop()
+	results.Add(Created, "after")
+
+	for _, outcome := range []int{Created, Skipped, BackedUp, Errored} {
+		want := workers * each / 4
+		if outcome == Created {
+			want++
+		}
+		if got := results.Count(outcome); got != want {
+			t.Errorf("recorded %d outcomes of %d, want %d", got, outcome, want)
+		}
+		if got := len(results.Targets(outcome)); got != want {
+			t.Errorf("listed %d targets for %d, want %d", got, outcome, want)
+		}
+		if watched[outcome] != workers*each/4 {
+			t.Errorf("the watcher saw %d outcomes of %d, want %d", watched[outcome], outcome, workers*each/4)
+		}
+	}
+	if got := len(results.Errors()); got != workers*each/4 {
+		t.Errorf("recorded %d errors, want %d", got, workers*each/4)
+	}
+	if want := "801 created, 800 skipped, 800 backed up, 800 failed"; results.String() != want {
+		t.Errorf("summarized as %q, want %q", results.String(), want)
+	}
+
+	var none *Results
+	none.Add(Created, "nowhere")
+	if none.Count(Created) != 0 || none.String() != "0 created, 0 skipped, 0 backed up, 0 failed" {
+		t.Error("a nil Results recorded something")
+	}
+}
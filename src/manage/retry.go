@@ -0,0 +1,68 @@
+package manage
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+/* How many times a filesystem operation is attempted before its error is returned
+and how long the first wait between attempts is, each wait doubles the last one */
+const (
+	fsAttempts = 4
+	fsBackoff  = 50 * time.Millisecond
+)
+
+// Waits between attempts, swapped out to avoid waiting for real
+var sleep = time.Sleep
+
+/* The filesystem operations that get retried, swapped out to watch how many links
+are being created at once or to make them fail */
+var (
+	osSymlink = os.Symlink
+	osRemove  = os.Remove
+	osRename  = os.Rename
+)
+
+// Errors network filesystems like NFS and SMB return when an operation may work if it's tried again
+var transientErrors = []error{syscall.EAGAIN, syscall.EINTR, syscall.EBUSY, syscall.ESTALE, syscall.ETIMEDOUT}
+
+// Returns true if err is one of the transientErrors
+func retryable(err error) bool {
+	for _, transient := range transientErrors {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Runs op until it succeeds, fails with an error that isn't retryable or runs out of
+attempts, waiting longer after each failure. The last error is returned */
+func retryFS(op func() error) error {
+	wait := fsBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = op(); err == nil || !retryable(err) || attempt == fsAttempts {
+			return err
+		}
+		sleep(wait)
+		wait *= 2
+	}
+}
+
+// Same as os.Symlink but retried while it fails transiently, see retryFS
+func symlink(source string, target string) error {
+	return retryFS(func() error { return osSymlink(source, target) })
+}
+
+// Same as os.Remove but retried while it fails transiently, see retryFS
+func remove(path string) error {
+	return retryFS(func() error { return osRemove(path) })
+}
+
+// Same as os.Rename but retried while it fails transiently, see retryFS
+func rename(from string, to string) error {
+	return retryFS(func() error { return osRename(from, to) })
+}
@@ -0,0 +1,94 @@
+package manage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+/* Makes the retried filesystem operations fail with err the first failures times each
+is called, and sleep record how long it was asked to wait, for the rest of the test */
+func flakyFS(t *testing.T, failures int, err syscall.Errno) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	failed := map[string]int{}
+	flaky := func(op string, path string) error {
+		if failed[op] < failures {
+			failed[op]++
+			return &os.PathError{Op: op, Path: path, Err: err}
+		}
+		return nil
+	}
+	osSymlink = func(source string, target string) error {
+		if err := flaky("symlink", target); err != nil {
+			return err
+		}
+		return os.Symlink(source, target)
+	}
+	osRemove = func(path string) error {
+		if err := flaky("remove", path); err != nil {
+			return err
+		}
+		return os.Remove(path)
+	}
+	osRename = func(from string, to string) error {
+		if err := flaky("rename", to); err != nil {
+			return err
+		}
+		return os.Rename(from, to)
+	}
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() {
+		osSymlink, osRemove, osRename = os.Symlink, os.Remove, os.Rename
+		sleep = time.Sleep
+	})
+	return &waits
+}
+
+func TestTransientFailuresAreRetriedWithinTheBudget(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, ".vimrc"), "")
+	writeFile(t, filepath.Join(home, ".exrc"), "")
+	link := Link{Source: filepath.Join(src, ".vimrc"), Target: filepath.Join(home, ".vimrc")}
+	waits := flakyFS(t, fsAttempts-1, syscall.ESTALE)
+
+	if err := CreateLinks([]Link{link}); err != nil || !link.IsLinked() {
+		t.Fatalf("a link failing %d times wasn't created in the end: %v", fsAttempts-1, err)
+	}
+	if want := []time.Duration{fsBackoff, 2 * fsBackoff, 4 * fsBackoff}; !reflect.DeepEqual(*waits, want) {
+		t.Errorf("the attempts waited %v, want %v", *waits, want)
+	}
+	if err := RemoveLinks([]Link{link}); err != nil || link.IsLinked() {
+		t.Errorf("a link failing to be removed %d times wasn't removed in the end: %v", fsAttempts-1, err)
+	}
+	if err := rename(filepath.Join(home, ".exrc"), filepath.Join(home, ".exrc.bak")); err != nil {
+		t.Errorf("a rename failing %d times didn't happen in the end: %v", fsAttempts-1, err)
+	}
+}
+
+func TestRetryingStopsAtTheBudgetAndOnOtherErrors(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, ".vimrc"), "")
+	writeFile(t, filepath.Join(home, ".exrc"), "")
+	waits := flakyFS(t, fsAttempts, syscall.EBUSY)
+	if err := symlink(filepath.Join(src, ".vimrc"), filepath.Join(home, ".vimrc")); err == nil {
+		t.Error("a symlink failing every attempt succeeded")
+	}
+	if len(*waits) != fsAttempts-1 {
+		t.Errorf("the symlink was retried after %v, want %d waits", *waits, fsAttempts-1)
+	}
+	if err := remove(filepath.Join(home, ".exrc")); err == nil {
+		t.Error("a remove failing every attempt succeeded")
+	}
+
+	waits = flakyFS(t, 1, syscall.EACCES)
+	if err := symlink(filepath.Join(src, ".vimrc"), filepath.Join(home, ".vimrc")); !os.IsPermission(err) {
+		t.Errorf("a permission error came back as %v", err)
+	}
+	if len(*waits) != 0 {
+		t.Errorf("a permission error was retried after %v", *waits)
+	}
+}
@@ -0,0 +1,49 @@
+package manage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+/* Overwrites every file under path with zeros before removing it so plaintext
+doesn't linger in the freed blocks. Symlinks are removed without following them
+and a path that doesn't exist is left alone */
+func Shred(path string) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return zeroFile(p, info.Size())
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+// Writes size zeros over the start of the file, syncing them to disk
+func zeroFile(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zeros := make([]byte, 32*1024)
+	for written := int64(0); written < size; {
+		n := int64(len(zeros))
+		if size-written < n {
+			n = size - written
+		}
+		if _, err := f.Write(zeros[:n]); err != nil {
+			return err
+		}
+		written += n
+	}
+	return f.Sync()
+}
@@ -6,22 +6,46 @@ import (
 	"github.com/logrusorgru/aurora"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
+// Reads the entries GetSymlinks scans, swapped out to make entries unreadable even as root
+var lstat = os.Lstat
+
+// Lists directories, swapped out by tests to list them in another order than by name
+var readDir = ioutil.ReadDir
+
 /* Returns files that are symlinked or not
 b = true returns symlinks
-b = false returns non symlinks */
+b = false returns non symlinks
+Entries that can't be read are skipped with a warning and reported together in
+the returned error, alongside the entries that could be read */
 func GetSymlinks(b bool) ([]os.FileInfo, error) {
 	var symlinks []os.FileInfo
-	dir, err := ioutil.ReadDir(".")
+	dir, err := os.Open(".")
+	if err != nil {
+		return symlinks, err
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
 	if err != nil {
 		return symlinks, err
 	}
+	sort.Strings(names)
+	var skipped []string
 	/* Loop over all files in the directory and check if its a symlink by trying to read
 	   the destination of the symlink, if there's no error it's a symlink if there's an error
 	   then it's not a symlink */
-	for _, f := range dir {
-		_, err := os.Readlink(f.Name())
+	for _, name := range names {
+		f, err := lstat(name)
+		if err != nil {
+			fmt.Println(aurora.Yellow("Skipping:"), name, "could not be read:", err)
+			skipped = append(skipped, name)
+			continue
+		}
+		_, err = os.Readlink(f.Name())
 		if b {
 			if err == nil {
 				symlinks = append(symlinks, f)
@@ -32,6 +56,9 @@ func GetSymlinks(b bool) ([]os.FileInfo, error) {
 			}
 		}
 	}
+	if len(skipped) > 0 {
+		return symlinks, errors.New("Error: Skipped unreadable entries: " + strings.Join(skipped, ", "))
+	}
 	return symlinks, nil
 }
 
@@ -55,8 +82,8 @@ func RemoveSymlink(src string) error {
 	return nil
 }
 
-/* Reads the current directory and symlinks it's files to the location specified by dest
-TODO function breaks if a string doesn't end with / */
+/* Reads the current directory and symlinks its files into the directory dest
+Nothing is linked when dest is a file, files that can't be linked are skipped */
 func CreateSymlinks(dest string) error {
 	dir, err := ioutil.ReadDir(".")
 	var currFile string
@@ -67,22 +94,24 @@ func CreateSymlinks(dest string) error {
 	if err != nil {
 		return err
 	}
+	if info, err := os.Stat(dest); err == nil && !info.IsDir() {
+		return errors.New("Error: The destination " + dest + " is a file, it has to be a directory to link " + currDir + " into")
+	}
 	for _, f := range dir {
 		currFile = f.Name()
 		// makes sure that it does not try to symlink a symlink
 		_, err := os.Readlink(currFile)
 		if err != nil {
-			err := os.Symlink(currDir+"/"+currFile, dest+currFile)
+			err := os.Symlink(filepath.Join(currDir, currFile), filepath.Join(dest, currFile))
 			if err != nil {
-				fmt.Println(aurora.Red("Skipping:"), currFile, "is already a symlink")
+				fmt.Println(aurora.Red("Skipping:"), currFile, "could not be linked:", err)
 			}
 		}
 	}
 	return nil
 }
 
-// Remove all symlinks from current directory
-//TODO function breaks if a string doesn't end with / */
+// Removes all symlinks from the directory src
 func RemoveSymlinks(src string) error {
 	dir, err := ioutil.ReadDir(src)
 	if err != nil {
@@ -90,7 +119,7 @@ func RemoveSymlinks(src string) error {
 	}
 	for _, f := range dir {
 		//skips non-symlinks
-		currFile := src + f.Name()
+		currFile := filepath.Join(src, f.Name())
 		_, err := os.Readlink(currFile)
 		if err != nil {
 			continue
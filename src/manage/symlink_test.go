@@ -0,0 +1,96 @@
+package manage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Runs the rest of the test from inside of dir
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestGetSymlinksSkipsUnreadableEntries(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, "plain"), "")
+	writeFile(t, filepath.Join(src, "locked"), "")
+	writeFile(t, filepath.Join(home, ".vimrc"), "")
+	linkFile(t, filepath.Join(home, ".vimrc"), filepath.Join(src, "link"))
+	chdir(t, src)
+	lstat = func(name string) (os.FileInfo, error) {
+		if name == "locked" {
+			return nil, &os.PathError{Op: "lstat", Path: name, Err: os.ErrPermission}
+		}
+		return os.Lstat(name)
+	}
+	defer func() { lstat = os.Lstat }()
+
+	for _, symlinks := range []bool{true, false} {
+		files, err := GetSymlinks(symlinks)
+		if err == nil || !strings.Contains(err.Error(), "locked") {
+			t.Errorf("the unreadable entry wasn't reported: %v", err)
+		}
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		want := "plain"
+		if symlinks {
+			want = "link"
+		}
+		if strings.Join(names, " ") != want {
+			t.Errorf("GetSymlinks(%v) returned %v, want [%s]", symlinks, names, want)
+		}
+	}
+}
+
+func TestSymlinksLinkIntoDirectoriesWithoutATrailingSlash(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, ".vimrc"), "")
+	writeFile(t, filepath.Join(src, ".zshrc"), "")
+	if err := os.MkdirAll(home, 0755); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, src)
+	if err := CreateSymlinks(home); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{".vimrc", ".zshrc"} {
+		if l := (Link{Source: filepath.Join(src, name), Target: filepath.Join(home, name)}); !l.IsLinked() {
+			t.Errorf("%s wasn't linked", l.Target)
+		}
+	}
+	if err := RemoveSymlinks(home); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{".vimrc", ".zshrc"} {
+		if _, err := os.Lstat(filepath.Join(home, name)); !os.IsNotExist(err) {
+			t.Errorf("the link %s is still there: %v", name, err)
+		}
+	}
+}
+
+func TestCreateSymlinksRefusesAFileAsTheDestination(t *testing.T) {
+	src, home := tempTree(t)
+	writeFile(t, filepath.Join(src, ".vimrc"), "")
+	dest := filepath.Join(home, "notes.txt")
+	writeFile(t, dest, "")
+	chdir(t, src)
+	err := CreateSymlinks(dest)
+	if want := "Error: The destination " + dest + " is a file, it has to be a directory to link " + src + " into"; err == nil || err.Error() != want {
+		t.Errorf("linking into a file failed with %v, want %q", err, want)
+	}
+	if _, err := os.Lstat(filepath.Join(home, "notes.txt", ".vimrc")); err == nil {
+		t.Error("a link was made inside of the file")
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/setup"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+/* Handles the mv-group command
+The group's directories get renamed in every folder of the store and when the
+group is set its links are recreated to point at the new directories */
+func runMvGroup(args []string) error {
+	if len(args) != 2 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := config.HomeDir()
+	if err != nil {
+		return err
+	}
+	return moveGroup(root, home, args[0], args[1])
+}
+
+/* Renames the group old to name everywhere in the store and in the state
+Its links are removed before the rename and created again after it, its secrets
+are shredded along with the old links and decrypted again for the new ones */
+func moveGroup(root string, home string, old string, name string) error {
+	if !store.HasGroup(root, old) {
+		return errors.New("Error: Group " + old + " does not exist")
+	}
+	if !validGroupName.MatchString(name) {
+		return errors.New("Error: " + strconv.Quote(name) + " is not a valid group name")
+	}
+	for _, folder := range store.Folders() {
+		if _, err := os.Lstat(filepath.Join(root, folder, name)); err == nil {
+			return errors.New("Error: " + filepath.Join(folder, name) + " already exists")
+		}
+	}
+	deployed, err := state.LoadDeployed()
+	if err != nil {
+		return err
+	}
+	setAt, wasSet := deployed[old]
+	if wasSet {
+		if err := forEachGroupLinks(root, home, old, manage.RemoveLinks); err != nil {
+			return err
+		}
+	}
+	if err := shredSecretCache(old); err != nil {
+		return err
+	}
+
+	for _, folder := range store.Folders() {
+		src := filepath.Join(root, folder, old)
+		if _, err := os.Lstat(src); err != nil {
+			continue
+		}
+		if err := os.Rename(src, filepath.Join(root, folder, name)); err != nil {
+			return err
+		}
+		fmt.Println(aurora.Green("Moved:"), filepath.Join(folder, old), "to", filepath.Join(folder, name))
+	}
+
+	if wasSet {
+		err := forEachGroupLinks(root, home, name, func(links []manage.Link) error {
+			if err := cacheLinks(root, links); err != nil {
+				return err
+			}
+			keepSecrets, err := decryptLinks(root, name, links, setup.ExecRunner{})
+			if err != nil {
+				return err
+			}
+			if err := manage.CreateLinks(links); err != nil {
+				return err
+			}
+			keepSecrets()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		delete(deployed, old)
+		deployed[name] = setAt
+		if err := deployed.Save(); err != nil {
+			return err
+		}
+	}
+	if err := moveGroupState(root, old, name); err != nil {
+		return err
+	}
+	warnDependents(root, old)
+	history := store.HistoryEntry{Operation: "mv-group", Groups: []string{old, name}}
+	if err := appendHistory(root, history); err != nil {
+		fmt.Println(aurora.Yellow("Warning:"), "could not record the move in the history:", err)
+	}
+	return nil
+}
+
+// Calls fn with the links of every deployment of the group
+func forEachGroupLinks(root string, home string, group string, fn func(links []manage.Link) error) error {
+	deployments, err := groupDeployments(root, group, home)
+	if err != nil {
+		return err
+	}
+	for _, d := range deployments {
+		links, err := groupLinks(root, group, d)
+		if err != nil {
+			return err
+		}
+		if err := fn(links); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Moves what the index, the disabled groups and the link registry know about the group
+over to its new name, the registry's links get the sources they have after the move */
+func moveGroupState(root string, old string, name string) error {
+	index, err := state.LoadIndex()
+	if err != nil {
+		return err
+	}
+	for _, folder := range store.Folders() {
+		index.Move(filepath.Join(root, folder, old), filepath.Join(root, folder, name))
+	}
+	if err := index.Save(); err != nil {
+		return err
+	}
+	disabled, err := state.LoadDisabled()
+	if err != nil {
+		return err
+	}
+	if at, ok := disabled[old]; ok {
+		delete(disabled, old)
+		disabled[name] = at
+		if err := disabled.Save(); err != nil {
+			return err
+		}
+	}
+	return moveRegistryGroup(root, old, name)
+}
+
+/* Renames the group in the entries of the link registry and points their sources at
+where the group's files are after the move, in the store, the cache or the secrets
+The registry is read even when link_registry is off since it may have been on before */
+func moveRegistryGroup(root string, old string, name string) error {
+	registry, err := state.LoadLinks()
+	if err != nil {
+		return err
+	}
+	cache, err := config.CacheDir()
+	if err != nil {
+		return err
+	}
+	oldSecrets, err := secretCacheDir(old)
+	if err != nil {
+		return err
+	}
+	secrets, err := secretCacheDir(name)
+	if err != nil {
+		return err
+	}
+	bases := map[string]string{root: root, cache: cache, oldSecrets: secrets}
+	moved := false
+	for target, entry := range registry {
+		if entry.Group != old {
+			continue
+		}
+		entry.Group = name
+		for from, to := range bases {
+			for _, folder := range store.Folders() {
+				dir := filepath.Join(from, folder, old)
+				if rel, err := filepath.Rel(dir, entry.Source); err == nil && manage.IsWithin(entry.Source, dir) {
+					entry.Source = filepath.Join(to, folder, name, rel)
+				}
+			}
+		}
+		registry[target] = entry
+		moved = true
+	}
+	if !moved {
+		return nil
+	}
+	return registry.Save()
+}
+
+// Warns about the groups whose dependsOn still refers to the group's old name
+func warnDependents(root string, old string) {
+	// Groups for other platforms and disabled ones still break when they're set
+	groups, err := store.ListGroups(root, store.GroupFilter{})
+	if err != nil {
+		return
+	}
+	for _, group := range groups {
+		conf, err := store.LoadGroupConfig(root, group)
+		if err != nil {
+			continue
+		}
+		for _, dep := range conf.DependsOn {
+			if dep == old {
+				fmt.Println(aurora.Yellow("Warning:"), group, "still depends on", old, "in its", store.GroupConfigName)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/state"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMvGroupRenamesADeployedGroup(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/vim/.config/nvim/init.vim", "")
+	e.write(t, "Bin/vim/vimdiff-all", "")
+	e.write(t, "Configs/ide/.tuckr.json", `{"dependsOn": ["vim"]}`)
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := runDisable([]string{"vim"}, true); err != nil {
+			t.Fatal(err)
+		}
+	})
+	out := captureOutput(t, func() {
+		if err := moveGroup(e.store, e.home, "vim", "editor"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), filepath.Join(e.store, "Configs", "editor", ".vimrc"))
+	assertLinked(t, e.inHome(".config/nvim/init.vim"), filepath.Join(e.store, "Configs", "editor", ".config", "nvim", "init.vim"))
+	assertLinked(t, e.inHome(".local/bin/vimdiff-all"), filepath.Join(e.store, "Bin", "editor", "vimdiff-all"))
+	if !strings.Contains(out, "ide still depends on vim") {
+		t.Errorf("mv-group printed %q, want the dependent group warned about", out)
+	}
+
+	status, err := checkGroup(e.store, "editor", e.home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.linked != 3 || len(status.problems) != 0 {
+		t.Errorf("status attributes %d links and %v to editor, want all 3 links", status.linked, status.problems)
+	}
+	deployed, err := state.LoadDeployed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := deployed["vim"]; ok {
+		t.Error("vim is still recorded as deployed")
+	}
+	if _, ok := deployed["editor"]; !ok {
+		t.Error("editor isn't recorded as deployed")
+	}
+	disabled, err := state.LoadDisabled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disabled.Has("vim") || !disabled.Has("editor") {
+		t.Errorf("the disabled groups are %v, want editor in place of vim", disabled)
+	}
+
+	if err := moveGroup(e.store, e.home, "editor", "ide"); err == nil {
+		t.Error("moving onto an existing group succeeded")
+	}
+	if err := moveGroup(e.store, e.home, "gone", "other"); err == nil {
+		t.Error("moving a group that doesn't exist succeeded")
+	}
+}
+
+func TestMvGroupMovesTheRegistrysLinks(t *testing.T) {
+	e := newTestEnv(t)
+	e.config(t, "[GENERAL]\nlink_registry = true\n")
+	e.write(t, "Configs/vim/.vimrc", "set nu")
+	e.write(t, "Bin/vim/vimdiff-all", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim", "zsh"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := moveGroup(e.store, e.home, "vim", "editor"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	registry, err := state.LoadLinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]state.LinkEntry{
+		e.inHome(".vimrc"):                 {Group: "editor", Source: filepath.Join(e.store, "Configs", "editor", ".vimrc")},
+		e.inHome(".local/bin/vimdiff-all"): {Group: "editor", Source: filepath.Join(e.store, "Bin", "editor", "vimdiff-all")},
+		e.inHome(".zshrc"):                 {Group: "zsh", Source: filepath.Join(e.store, "Configs", "zsh", ".zshrc")},
+	}
+	for target, entry := range want {
+		if got := registry[target]; got.Group != entry.Group || got.Source != entry.Source {
+			t.Errorf("the registry has %s as %+v, want it linked to %s of %s", target, got, entry.Source, entry.Group)
+		}
+	}
+
+	// With the entries moved the renamed group's links are still its own once the store moves
+	moved := filepath.Join(e.dir, "dotfiles")
+	if err := os.Rename(e.store, moved); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("TUCKR_STORE", moved)
+	captureOutput(t, func() {
+		if err := runSet([]string{"editor"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), filepath.Join(moved, "Configs", "editor", ".vimrc"))
+}
+
+func TestMvGroupDecryptsTheSecretsOfTheRenamedGroup(t *testing.T) {
+	e := newTestEnv(t)
+	e.config(t, "[GENERAL]\ndecrypt_cmd = cat\nlink_registry = true\n")
+	e.write(t, "Configs/ssh/.ssh/config", "")
+	e.write(t, "Configs/ssh/.ssh/id_ed25519.age", "private key")
+	dir, err := state.SecretsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	captureOutput(t, func() {
+		if err := runSet([]string{"ssh"}); err != nil {
+			t.Fatal(err)
+		}
+		runCleanups()
+		if err := moveGroup(e.store, e.home, "ssh", "keys"); err != nil {
+			t.Fatal(err)
+		}
+		runCleanups()
+	})
+	plaintext := filepath.Join(dir, "keys", "Configs", "keys", ".ssh", "id_ed25519")
+	assertLinked(t, e.inHome(".ssh/id_ed25519"), plaintext)
+	if data, err := ioutil.ReadFile(e.inHome(".ssh/id_ed25519")); err != nil || string(data) != "private key" {
+		t.Errorf("the relinked key holds %q %v", data, err)
+	}
+	assertMissing(t, filepath.Join(dir, "ssh"))
+	registry, err := state.LoadLinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := registry[e.inHome(".ssh/id_ed25519")]; entry.Group != "keys" || entry.Source != plaintext {
+		t.Errorf("the registry has the key as %+v, want it linked to %s", entry, plaintext)
+	}
+}
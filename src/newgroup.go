@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+/* Handles the new-group command which starts a group in the store's Configs
+The group gets a README and, with --config, a .tuckr.json listing every setting */
+func runNewGroup(args []string) error {
+	flags := flag.NewFlagSet("new-group", flag.ExitOnError)
+	withConfig := flags.Bool("config", false, "also write a "+store.GroupConfigName+" with every setting left empty")
+	desc := flags.String("description", "", "first line of the group's "+store.ReadmeName)
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) != 1 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	files, err := scaffoldGroup(root, args[0], *desc, *withConfig)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		fmt.Println(aurora.Green("Created:"), f)
+	}
+	return nil
+}
+
+/* Creates the directory of a new group along with its placeholder files and returns their paths
+The name has to be valid and not taken by a directory in any folder of the store */
+func scaffoldGroup(root string, name string, desc string, withConfig bool) ([]string, error) {
+	if !validGroupName.MatchString(name) {
+		return nil, errors.New("Error: " + strconv.Quote(name) + " is not a valid group name")
+	}
+	for _, folder := range store.Folders() {
+		if _, err := os.Lstat(filepath.Join(root, folder, name)); err == nil {
+			return nil, errors.New("Error: " + filepath.Join(folder, name) + " already exists")
+		}
+	}
+	dir := store.GroupPath(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if desc == "" {
+		desc = name + " dotfiles"
+	}
+	readme := filepath.Join(dir, store.ReadmeName)
+	if err := ioutil.WriteFile(readme, []byte(desc+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	files := []string{dir, readme}
+	if !withConfig {
+		return files, nil
+	}
+	data, err := json.MarshalIndent(store.GroupConfigSkeleton(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, store.GroupConfigName)
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return append(files, path), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/raphgl/tuckr/store"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewGroupScaffoldsAGroupThatParses(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	files, err := scaffoldGroup(e.store, "zsh", "Shell settings", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(e.store, "Configs", "zsh")
+	if want := []string{dir, filepath.Join(dir, store.ReadmeName), filepath.Join(dir, store.GroupConfigName)}; !reflect.DeepEqual(files, want) {
+		t.Errorf("new-group created %v, want %v", files, want)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, store.ReadmeName)); err != nil || string(data) != "Shell settings\n" {
+		t.Errorf("the README holds %q %v", data, err)
+	}
+	if conf, err := store.LoadGroupConfig(e.store, "zsh"); err != nil || !reflect.DeepEqual(conf, store.GroupConfigSkeleton()) {
+		t.Errorf("the scaffolded %s loaded as %+v %v", store.GroupConfigName, conf, err)
+	}
+	// Every setting is listed so the skeleton shows what a group can have
+	data, err := ioutil.ReadFile(filepath.Join(dir, store.GroupConfigName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys map[string]interface{}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		t.Fatal(err)
+	}
+	fields := reflect.TypeOf(store.GroupConfig{})
+	for i := 0; i < fields.NumField(); i++ {
+		key := fields.Field(i).Tag.Get("json")
+		if _, ok := keys[key]; !ok {
+			t.Errorf("the skeleton leaves out %q", key)
+		}
+	}
+	if groups, err := store.Groups(e.store); err != nil || !reflect.DeepEqual(groups, []string{"vim", "zsh"}) {
+		t.Errorf("the store has the groups %v %v after new-group", groups, err)
+	}
+
+	for name, want := range map[string]string{"vim": "already exists", "../etc": "is not a valid group name"} {
+		if _, err := scaffoldGroup(e.store, name, "", false); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("scaffolding %s failed with %v, want %q", name, err, want)
+		}
+	}
+}
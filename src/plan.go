@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/setup"
+	"github.com/raphgl/tuckr/store"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/* Everything a set would do, written by set --dry-run --plan-out so it can be
+reviewed and later applied as is with set --plan */
+type setPlan struct {
+	Root   string         `json:"root"`
+	Home   string         `json:"home"`
+	Groups []plannedGroup `json:"groups"`
+}
+
+/* What set would do for a single group
+Scripts are the group's set_ scripts in the order they run, relative to its hooks */
+type plannedGroup struct {
+	Group   string        `json:"group"`
+	Links   []plannedLink `json:"links"`
+	Scripts []string      `json:"scripts,omitempty"`
+}
+
+/* A link that set would create
+Conflict is how a target taken by another file gets resolved, one of the
+conflict policies, and it's empty when the target is free */
+type plannedLink struct {
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Executable bool   `json:"executable,omitempty"`
+	Conflict   string `json:"conflict,omitempty"`
+}
+
+// The names plans record conflict resolutions by, the same as the conflict policies
+var resolutionNames = map[int]string{
+	manage.ResolveSkip:      "skip",
+	manage.ResolveOverwrite: "overwrite",
+	manage.ResolveBackup:    "backup",
+	manage.ResolveAbort:     "abort",
+}
+
+/* Adds the links of a deployment that aren't linked yet to the group's plan
+executable tells whether a link's store file has to be made executable and resolve
+decides how the targets taken by other files would be resolved, like set does */
+func (g *plannedGroup) addLinks(links []manage.Link, executable func(l manage.Link) bool, resolve manage.ConflictResolver) error {
+	for _, l := range links {
+		if l.IsLinked() {
+			continue
+		}
+		planned := plannedLink{Source: l.Source, Target: l.Target, Executable: executable(l)}
+		if _, err := os.Lstat(l.Target); err == nil {
+			resolution, err := resolve(l)
+			if err != nil {
+				return err
+			}
+			planned.Conflict = resolutionNames[resolution]
+		}
+		g.Links = append(g.Links, planned)
+	}
+	return nil
+}
+
+/* Returns the links of the group that the plan would create, a link that isn't
+in the plan or whose store file changed since it was made is left out */
+func (plan *setPlan) links(group string, links []manage.Link) []manage.Link {
+	planned := map[manage.Link]bool{}
+	for _, g := range plan.Groups {
+		if g.Group != group {
+			continue
+		}
+		for _, pl := range g.Links {
+			planned[manage.Link{Source: pl.Source, Target: pl.Target}] = true
+		}
+	}
+	var kept []manage.Link
+	for _, l := range links {
+		if planned[manage.Link{Source: l.Source, Target: l.Target}] {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+/* Returns a resolver that resolves conflicts the way the plan recorded, a target
+that got taken since the plan was made is skipped */
+func (plan *setPlan) resolver() manage.ConflictResolver {
+	resolutions := map[string]int{}
+	for _, g := range plan.Groups {
+		for _, pl := range g.Links {
+			for resolution, name := range resolutionNames {
+				if pl.Conflict == name {
+					resolutions[pl.Target] = resolution
+				}
+			}
+		}
+	}
+	return func(l manage.Link) (int, error) {
+		return resolutions[l.Target], nil
+	}
+}
+
+// Returns true if the plan runs the group's script, script is relative to the group's hooks
+func (plan *setPlan) runs(group string, script string) bool {
+	for _, g := range plan.Groups {
+		if g.Group != group {
+			continue
+		}
+		for _, planned := range g.Scripts {
+			if planned == script {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/* Returns the scripts runHooks would run for the group with the prefix
+They're relative to the group's hooks so the ones for a platform start with its name */
+func plannedScripts(root string, group string, prefix string) ([]string, error) {
+	handles, err := hookHandles(root, group)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := store.HooksPath(root, group)
+	if err != nil {
+		return nil, err
+	}
+	var scripts []string
+	for _, handle := range handles {
+		for _, script := range handle.Scripts(prefix) {
+			rel, err := filepath.Rel(dir, filepath.Join(handle.Dir, script))
+			if err != nil {
+				return nil, err
+			}
+			scripts = append(scripts, filepath.ToSlash(rel))
+		}
+	}
+	return scripts, nil
+}
+
+// Writes the plan to path as indented json
+func writePlan(path string, plan *setPlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Println(aurora.Green("Wrote plan:"), path)
+	return nil
+}
+
+/* Writes a shell script to path that does what the plan would when applied
+except for taken targets being backed up to target.bak, the script can't keep backup
+generations. Every path is single quoted so the script works with any file name
+Scripts are run with the shell set runs them with and get their .env, and when the
+config has script_commands the script makes the same restricted $PATH for them */
+func writeShellScript(path string, plan *setPlan) error {
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	sh := setup.Shell()
+	if found, err := exec.LookPath(sh); err == nil {
+		sh = found
+	}
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n# Written by tuckr set --emit-sh, running it sets the groups the same way tuckr would\nset -e\n")
+	if len(conf.General.ScriptCommands) > 0 {
+		commands, missing, err := setup.CommandPaths(conf.General.ScriptCommands)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			fmt.Println(aurora.Yellow("Warning:"), "script_commands lists commands that aren't installed:", strings.Join(missing, ", "))
+		}
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		script.WriteString("\n# Scripts only get the commands in script_commands\ntuckr_path=$(mktemp -d)\ntrap 'rm -rf \"$tuckr_path\"' EXIT\n")
+		for _, name := range names {
+			script.WriteString("ln -s " + shellQuote(commands[name]) + " \"$tuckr_path\"/" + shellQuote(name) + "\n")
+		}
+	}
+	for _, g := range plan.Groups {
+		script.WriteString("\n# " + g.Group + "\n")
+		for _, l := range g.Links {
+			switch l.Conflict {
+			case "skip":
+				script.WriteString("# " + l.Target + " already exists and is left alone\n")
+				continue
+			case "abort":
+				script.WriteString("echo " + shellQuote("Aborted at "+l.Target) + " >&2\nexit 1\n")
+				continue
+			}
+			script.WriteString("mkdir -p " + shellQuote(filepath.Dir(l.Target)) + "\n")
+			switch l.Conflict {
+			case "backup":
+				script.WriteString("mv " + shellQuote(l.Target) + " " + shellQuote(l.Target+".bak") + "\n")
+			case "overwrite":
+				script.WriteString("rm -rf " + shellQuote(l.Target) + "\n")
+			}
+			script.WriteString("ln -s " + shellQuote(l.Source) + " " + shellQuote(l.Target) + "\n")
+			if l.Executable {
+				script.WriteString("chmod +x " + shellQuote(l.Source) + "\n")
+			}
+		}
+		if len(g.Scripts) == 0 {
+			continue
+		}
+		handles, err := hookHandles(plan.Root, g.Group)
+		if err != nil {
+			return err
+		}
+		hooks, err := store.HooksPath(plan.Root, g.Group)
+		if err != nil {
+			return err
+		}
+		for _, name := range g.Scripts {
+			scriptPath := filepath.Join(hooks, filepath.FromSlash(name))
+			var env []string
+			for _, handle := range handles {
+				if handle.Dir == filepath.Dir(scriptPath) {
+					env = handle.Env
+				}
+			}
+			line := shellQuote(sh) + " " + shellQuote(scriptPath)
+			var vars []string
+			for _, v := range env {
+				vars = append(vars, shellQuote(v))
+			}
+			if len(conf.General.ScriptCommands) > 0 {
+				vars = append(vars, `"PATH=$tuckr_path"`)
+			}
+			if len(vars) > 0 {
+				line = "env " + strings.Join(vars, " ") + " " + line
+			}
+			script.WriteString(line + "\n")
+		}
+	}
+	if err := ioutil.WriteFile(path, []byte(script.String()), 0755); err != nil {
+		return err
+	}
+	fmt.Println(aurora.Green("Wrote script:"), path)
+	return nil
+}
+
+/* Writes a systemd-tmpfiles.d config to path with an L entry for every planned link
+tmpfiles never replaces what's at a path with L so taken targets are left alone, and
+scripts and executable bits aren't part of it since tmpfiles only manages the links */
+func writeTmpfiles(path string, plan *setPlan) error {
+	var config strings.Builder
+	config.WriteString("# Written by tuckr set --emit-tmpfiles, systemd-tmpfiles --create links the groups\n")
+	for _, g := range plan.Groups {
+		config.WriteString("\n# " + g.Group + "\n")
+		for _, l := range g.Links {
+			config.WriteString("L " + tmpfilesEscape(l.Target) + " - - - - " + tmpfilesEscape(l.Source) + "\n")
+		}
+	}
+	if err := ioutil.WriteFile(path, []byte(config.String()), 0644); err != nil {
+		return err
+	}
+	fmt.Println(aurora.Green("Wrote tmpfiles config:"), path)
+	return nil
+}
+
+/* Escapes s for a field of a tmpfiles.d line
+Whitespace, quotes and backslashes become C escapes and % is doubled so it's not taken as a specifier */
+func tmpfilesEscape(s string) string {
+	var escaped strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\':
+			escaped.WriteString(`\\`)
+		case r == '%':
+			escaped.WriteString("%%")
+		case r == ' ' || r == '\t' || r == '\n' || r == '"' || r == '\'':
+			escaped.WriteString(fmt.Sprintf(`\x%02x`, r))
+		default:
+			escaped.WriteRune(r)
+		}
+	}
+	return escaped.String()
+}
+
+// Quotes s for a posix shell so it's passed as a single argument as is
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// Reads a plan written by set --plan-out
+func readPlan(path string) (*setPlan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan setPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, errors.New("Error: Invalid plan " + path + ": " + err.Error())
+	}
+	return &plan, nil
+}
+
+/* Applies a plan written by set --plan-out
+The plan's groups are set into the home it was made for the same way set sets them,
+except that only the planned links get created, conflicts are resolved the way the
+plan recorded and only the planned scripts are run */
+func applyPlan(plan *setPlan, opts setOptions) error {
+	var groups []string
+	for _, g := range plan.Groups {
+		groups = append(groups, g.Group)
+	}
+	opts.apply = plan
+	return setGroups(plan.Root, plan.Home, groups, opts)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/state"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+/* Fills the store with a group that has a conflict, an executable, an action and a
+set_ script, the action and script touch files in ran */
+func planStore(t *testing.T, e *testEnv, policy string) {
+	t.Helper()
+	ran := filepath.Join(e.dir, "ran")
+	e.config(t, "[GENERAL]\nlink_registry = true\nconflict_policy = "+policy+"\n")
+	e.write(t, "Configs/vim/.vimrc", "set nu")
+	e.write(t, "Configs/vim/.exrc", "")
+	e.write(t, "Configs/vim/.tuckr.json", `{"actions": {".exrc": ["touch `+filepath.Join(ran, "action")+`"]}}`)
+	e.write(t, "Bin/vim/vimdiff-all", "")
+	e.write(t, "Hooks/vim/set_plugins.sh", "touch "+filepath.Join(ran, "script")+"\n")
+	writeTestFile(t, e.inHome(".vimrc"), "mine")
+	if err := os.MkdirAll(ran, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/* Returns what's in the home, the store and ran along with the recorded links, with
+the test's directory left out of paths so that two environments can be compared
+The store's history is left out */
+func planSnapshot(t *testing.T, e *testEnv) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	for _, root := range []string{e.home, e.store, filepath.Join(e.dir, "ran")} {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel := strings.TrimPrefix(path, e.dir)
+			if info.Mode()&os.ModeSymlink != 0 {
+				dest, err := os.Readlink(path)
+				files[rel] = "-> " + strings.TrimPrefix(dest, e.dir)
+				return err
+			}
+			if info.Name() == "tuckr.history.jsonl" {
+				// the entries only differ by when they were made
+				return nil
+			}
+			data, err := ioutil.ReadFile(path)
+			files[rel] = strings.Replace(string(data), e.dir, "", -1) + " " + info.Mode().Perm().String()
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry, err := state.LoadLinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for target, entry := range registry {
+		files["registry "+strings.TrimPrefix(target, e.dir)] = entry.Group + " " + entry.Rel
+	}
+	return files
+}
+
+func TestApplyingAPlanMatchesSettingDirectly(t *testing.T) {
+	for _, policy := range []string{"skip", "backup"} {
+		planned := newTestEnv(t)
+		planStore(t, planned, policy)
+		path := filepath.Join(planned.dir, "plan.json")
+		before := planSnapshot(t, planned)
+		captureOutput(t, func() {
+			if err := runSet([]string{"--plan-out", path, "vim"}); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if after := planSnapshot(t, planned); !reflect.DeepEqual(after, before) {
+			t.Errorf("writing the %s plan changed %v into %v", policy, before, after)
+		}
+		plan, err := readPlan(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range plan.Groups[0].Links {
+			if want := map[bool]string{true: policy}[l.Target == planned.inHome(".vimrc")]; l.Conflict != want {
+				t.Errorf("the %s plan resolves %s with %q, want %q", policy, l.Target, l.Conflict, want)
+			}
+		}
+		captureOutput(t, func() {
+			if err := runSet([]string{"--plan", path}); err != nil {
+				t.Fatal(err)
+			}
+		})
+		applied := planSnapshot(t, planned)
+
+		direct := newTestEnv(t)
+		planStore(t, direct, policy)
+		captureOutput(t, func() {
+			if err := runSet([]string{"vim"}); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if want := planSnapshot(t, direct); !reflect.DeepEqual(applied, want) {
+			t.Errorf("applying the %s plan left\n%v\nsetting directly left\n%v", policy, applied, want)
+		}
+		if _, ok := applied["/ran/script"]; !ok {
+			t.Errorf("applying the %s plan didn't run the set_ script", policy)
+		}
+	}
+}
+
+func TestEmittedShellScriptReproducesTheLinks(t *testing.T) {
+	e := newTestEnv(t)
+	out := filepath.Join(e.dir, "ran")
+	e.config(t, "[GENERAL]\nscript_commands = touch\n")
+	e.write(t, "Configs/vim/.vimrc", "")
+	quoted := e.write(t, "Configs/vim/it's \"here\".conf", "")
+	tool := e.write(t, "Bin/vim/vimdiff-all", "")
+	e.write(t, "Hooks/vim/.env", "GREETING='hello there'\n")
+	e.write(t, "Hooks/vim/set_plugins.sh", `touch "`+out+`-$GREETING"
+if command -v ls; then touch "`+out+`-unrestricted"; fi
+`)
+	writeTestFile(t, e.inHome(".vimrc"), "mine")
+	script := filepath.Join(e.dir, "set.sh")
+	captureOutput(t, func() {
+		if err := runSet([]string{"--emit-sh", script, "vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertMissing(t, e.inHome("it's \"here\".conf"))
+	if output, err := exec.Command("sh", script).CombinedOutput(); err != nil {
+		t.Fatalf("the script failed with %v:\n%s", err, output)
+	}
+
+	// The default conflict policy leaves the taken .vimrc alone just like set does
+	if data, err := ioutil.ReadFile(e.inHome(".vimrc")); err != nil || string(data) != "mine" {
+		t.Errorf("~/.vimrc holds %q (%v), want it left alone", data, err)
+	}
+	assertMissing(t, e.inHome(".vimrc.bak"))
+	assertLinked(t, e.inHome("it's \"here\".conf"), quoted)
+	assertLinked(t, e.inHome(".local/bin/vimdiff-all"), tool)
+	if info, err := os.Stat(tool); err != nil || info.Mode()&0111 == 0 {
+		t.Errorf("%s wasn't made executable: %v", tool, err)
+	}
+	if _, err := os.Stat(out + "-hello there"); err != nil {
+		t.Errorf("the set_ script didn't run with its .env: %v", err)
+	}
+	// ls isn't in script_commands so the script can't find it
+	assertMissing(t, out+"-unrestricted")
+}
+
+func TestEmittedTmpfilesConfigHasAnEntryPerPlannedLink(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	notes := e.write(t, "Configs/vim/my 100% notes.txt", "")
+	tool := e.write(t, "Bin/vim/vimdiff-all", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	path := filepath.Join(e.dir, "tuckr.conf")
+	captureOutput(t, func() {
+		if err := runSet([]string{"--emit-tmpfiles", path, "vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertMissing(t, e.inHome(".vimrc"))
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			entries = append(entries, line)
+		}
+	}
+	escape := func(s string) string {
+		return strings.Replace(strings.Replace(s, "%", "%%", -1), " ", `\x20`, -1)
+	}
+	want := []string{
+		"L " + e.inHome(".vimrc") + " - - - - " + vimrc,
+		"L " + escape(e.inHome("my 100% notes.txt")) + " - - - - " + escape(notes),
+		"L " + e.inHome(".local/bin/vimdiff-all") + " - - - - " + tool,
+	}
+	sort.Strings(entries)
+	sort.Strings(want)
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("the tmpfiles config has\n%s\nwant\n%s", strings.Join(entries, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/setup"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"strings"
+	"time"
+)
+
+/* Handles the plan command which lists the operations set --reconcile would carry
+out to bring the targets of the groups in line with the store, every deployable
+group is planned when none are given */
+func runPlan(args []string) error {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	userName := flags.String("user", "", "plan the links in this user's home instead")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) == 0 {
+		args = []string{"*"}
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := targetHome(*userName)
+	if err != nil {
+		return err
+	}
+	filter, err := deployFilter(root)
+	if err != nil {
+		return err
+	}
+	groups, err := selectGroups(root, args, filter)
+	if err != nil {
+		return err
+	}
+	return reconcileGroups(root, home, groups, true)
+}
+
+/* Compares what's in home with the groups of the store at root and returns only the
+operations needed to make them match, see manage.Reconcile. It's what plan prints and
+set --reconcile applies. The removals come after every other operation and a link one
+deployment would remove is kept when another deployment, of any of the groups, wants it */
+func planGroups(root string, home string, groups []string) (manage.PlanResult, error) {
+	var plan manage.PlanResult
+	var removals []manage.Operation
+	wanted := map[string]bool{}
+	for _, group := range groups {
+		deployments, err := groupDeployments(root, group, home)
+		if err != nil {
+			return plan, err
+		}
+		for _, d := range deployments {
+			links, err := groupLinks(root, group, d)
+			if err != nil {
+				return plan, err
+			}
+			for _, l := range links {
+				wanted[l.Target] = true
+			}
+			for _, op := range manage.Reconcile(d.src, links).Operations {
+				if op.Kind == manage.RemoveLink {
+					removals = append(removals, op)
+				} else {
+					plan.Operations = append(plan.Operations, op)
+				}
+			}
+		}
+	}
+	for _, op := range removals {
+		if !wanted[op.Link.Target] {
+			plan.Operations = append(plan.Operations, op)
+		}
+	}
+	return plan, nil
+}
+
+// Returns the links of every deployment of the group into home
+func reconciledLinks(root string, home string, group string) ([]manage.Link, error) {
+	deployments, err := groupDeployments(root, group, home)
+	if err != nil {
+		return nil, err
+	}
+	var all []manage.Link
+	for _, d := range deployments {
+		links, err := groupLinks(root, group, d)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, links...)
+	}
+	return all, nil
+}
+
+/* Plans the groups with planGroups and carries the plan out, with dryRun it's only printed
+Before it's carried out the groups' files get cached and decrypted like a set would, and
+after it the groups get recorded like a set, in the index and as deployed, but no scripts
+are run since only the links get reconciled. Applying again once the targets match does
+nothing */
+func reconcileGroups(root string, home string, groups []string, dryRun bool) error {
+	plan, err := planGroups(root, home, groups)
+	if err != nil {
+		return err
+	}
+	for _, op := range plan.Operations {
+		label := op.String() + ":"
+		if dryRun {
+			label = "Would " + strings.ToLower(label)
+		}
+		if op.Kind == manage.RemoveLink {
+			fmt.Println(aurora.Cyan(label), op.Link.Target)
+		} else {
+			fmt.Println(aurora.Cyan(label), op.Link.Target, "->", op.Link.Source)
+		}
+	}
+	if len(plan.Operations) == 0 {
+		fmt.Println(aurora.Green("Nothing to change, the targets match the store"))
+		return nil
+	}
+	if dryRun {
+		return nil
+	}
+	index, err := state.LoadIndex()
+	if err != nil {
+		return err
+	}
+	deployedGroups, err := state.LoadDeployed()
+	if err != nil {
+		return err
+	}
+	cache, err := config.CacheDir()
+	if err != nil {
+		return err
+	}
+	var all []manage.Link
+	var keep []func()
+	for _, group := range groups {
+		links, err := reconciledLinks(root, home, group)
+		if err == nil {
+			err = cacheLinks(root, links)
+		}
+		keepSecrets := func() {}
+		if err == nil {
+			keepSecrets, err = decryptLinks(root, group, links, setup.ExecRunner{})
+		}
+		if err != nil {
+			fmt.Println(aurora.Red("Failed:"), group, "could not be reconciled")
+			return err
+		}
+		all = append(all, links...)
+		keep = append(keep, keepSecrets)
+	}
+	results := &manage.Results{}
+	if err := plan.Apply(results); err != nil {
+		fmt.Println(aurora.Red("Failed:"), "the plan could not be carried out")
+		return err
+	}
+	for _, l := range all {
+		if err := index.Record(origin(root, cache, l)); err != nil {
+			return err
+		}
+	}
+	for _, keepSecrets := range keep {
+		keepSecrets()
+	}
+	for _, group := range groups {
+		deployedGroups[group] = time.Now()
+	}
+	if err := index.Save(); err != nil {
+		return err
+	}
+	if err := deployedGroups.Save(); err != nil {
+		return err
+	}
+	fmt.Println(aurora.Green("Links:"), results)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/manage"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReconcileOnlyDoesWhatAPartialDeploymentLacks(t *testing.T) {
+	e := newTestEnv(t)
+	srv := filepath.Join(e.dir, "srv")
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	gvimrc := e.write(t, "Configs/vim/.gvimrc", "")
+	e.write(t, "Configs/vim/.exrc", "")
+	initVim := e.write(t, "Configs/vim/.config/nvim/init.vim", "")
+	lsp := e.write(t, "Configs/vim/.config/nvim/lsp.vim", "")
+	tool := e.write(t, "Bin/vim/vimdiff-all", "")
+	site := e.write(t, "Configs/web/site.conf", "")
+	e.write(t, "Configs/web/.tuckr.json", `{"targets": ["`+srv+`"]}`)
+	zshrc := e.write(t, "Configs/zsh/.zshrc", "")
+	zshenv := e.write(t, "Configs/zsh/.zshenv", "")
+	vim := filepath.Join(e.store, "Configs", "vim")
+
+	// Linked already
+	linkTestFile(t, e.inHome(".vimrc"), vimrc)
+	// Linked to a file the store doesn't have anymore
+	linkTestFile(t, e.inHome(".config/nvim/lsp.vim"), filepath.Join(vim, ".config", "nvim", "old.vim"))
+	// Broken and pointing out of the store
+	linkTestFile(t, e.inHome(".gvimrc"), filepath.Join(e.dir, "gone"))
+	// Taken by a file, which is up to the conflict policy
+	writeTestFile(t, e.inHome(".exrc"), "mine")
+	// Left deep in a directory none of vim's links are in
+	stale := e.inHome(".config/nvim/plugged/fugitive/plugin.vim")
+	linkTestFile(t, stale, filepath.Join(vim, ".config", "nvim", "plugged", "fugitive", "plugin.vim"))
+	// Left by vim where zsh now wants its own link
+	linkTestFile(t, e.inHome(".zshenv"), filepath.Join(vim, ".zshenv"))
+	// Not into the store at all
+	local := e.inHome(".config/nvim/plugged/local.vim")
+	linkTestFile(t, local, filepath.Join(e.dir, "gone"))
+
+	groups := []string{"vim", "web", "zsh"}
+	plan, err := planGroups(e.store, e.home, groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := func(kind int, source string, target string) manage.Operation {
+		return manage.Operation{Kind: kind, Link: manage.Link{Source: source, Target: target}}
+	}
+	want := []manage.Operation{
+		op(manage.AddLink, initVim, e.inHome(".config/nvim/init.vim")),
+		op(manage.FixLink, lsp, e.inHome(".config/nvim/lsp.vim")),
+		op(manage.FixLink, gvimrc, e.inHome(".gvimrc")),
+		op(manage.AddLink, tool, e.inHome(".local/bin/vimdiff-all")),
+		op(manage.AddLink, site, filepath.Join(srv, "site.conf")),
+		op(manage.FixLink, zshenv, e.inHome(".zshenv")),
+		op(manage.AddLink, zshrc, e.inHome(".zshrc")),
+		op(manage.RemoveLink, filepath.Join(vim, ".config", "nvim", "plugged", "fugitive", "plugin.vim"), stale),
+	}
+	if !reflect.DeepEqual(plan.Operations, want) {
+		t.Errorf("the plan is\n%v\nwant\n%v", plan.Operations, want)
+	}
+
+	captureOutput(t, func() {
+		if err := runSet([]string{"--reconcile", "vim", "web", "zsh"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, op := range want[:len(want)-1] {
+		assertLinked(t, op.Link.Target, op.Link.Source)
+	}
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertMissing(t, stale)
+	if _, err := os.Lstat(local); err != nil {
+		t.Errorf("a symlink out of the store got removed: %v", err)
+	}
+	if data, err := ioutil.ReadFile(e.inHome(".exrc")); err != nil || string(data) != "mine" {
+		t.Errorf("the taken ~/.exrc holds %q %v, want it left alone", data, err)
+	}
+	if again, err := planGroups(e.store, e.home, groups); err != nil || len(again.Operations) != 0 {
+		t.Errorf("planning once set --reconcile ran gave %v %v, want nothing", again.Operations, err)
+	}
+}
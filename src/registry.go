@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/state"
+	"os"
+	"path/filepath"
+)
+
+// Returns the registry of the links set created, nil when the config's link_registry is off
+func loadRegistry() (state.LinkRegistry, error) {
+	conf, err := config.LoadConfig()
+	if err != nil || !conf.General.LinkRegistry {
+		return nil, err
+	}
+	return state.LoadLinks()
+}
+
+// Returns the path, relative to the deployment's directory, of the store file a link is for
+func registryRel(root string, cache string, d deployment, l manage.Link) (string, error) {
+	return filepath.Rel(d.src, origin(root, cache, l))
+}
+
+/* Returns the links of the deployment whose targets are links set created into where
+the store was before it moved, see LinkRegistry.Owns */
+func movedLinks(registry state.LinkRegistry, root string, cache string, group string, d deployment, links []manage.Link) ([]manage.Link, error) {
+	var moved []manage.Link
+	for _, l := range links {
+		if registry == nil || l.IsLinked() {
+			continue
+		}
+		rel, err := registryRel(root, cache, d, l)
+		if err != nil {
+			return nil, err
+		}
+		if registry.Owns(l.Target, group, rel, l.Source) {
+			moved = append(moved, l)
+		}
+	}
+	return moved, nil
+}
+
+/* Removes the links of the deployment that point to where the store was before it moved
+so set can create them again instead of taking them for conflicts */
+func removeMovedLinks(registry state.LinkRegistry, root string, cache string, group string, d deployment, links []manage.Link, quiet bool) error {
+	moved, err := movedLinks(registry, root, cache, group, d, links)
+	if err != nil {
+		return err
+	}
+	for _, l := range moved {
+		if !quiet {
+			fmt.Println(aurora.Cyan("Relinking:"), l.Target, "still points to where the store used to be")
+		}
+		if err := os.Remove(l.Target); err != nil {
+			return err
+		}
+		delete(registry, l.Target)
+	}
+	return nil
+}
+
+// Records the links of the deployment that are in place into the registry
+func recordLinks(registry state.LinkRegistry, root string, cache string, group string, d deployment, links []manage.Link) error {
+	for _, l := range links {
+		if registry == nil || !l.IsLinked() {
+			continue
+		}
+		rel, err := registryRel(root, cache, d, l)
+		if err != nil {
+			return err
+		}
+		if err := registry.Record(l.Target, l.Source, group, rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drops the links from the registry once they're removed, nothing happens when it's off
+func forgetLinks(links []manage.Link) error {
+	registry, err := loadRegistry()
+	if err != nil || registry == nil {
+		return err
+	}
+	for _, l := range links {
+		delete(registry, l.Target)
+	}
+	return registry.Save()
+}
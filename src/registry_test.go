@@ -0,0 +1,88 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/state"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTheRegistryRecognizesLinksAfterTheStoreMoves(t *testing.T) {
+	e := newTestEnv(t)
+	e.config(t, "[GENERAL]\nlink_registry = true\n")
+	e.write(t, "Configs/vim/.vimrc", "set nu")
+	e.write(t, "Configs/vim/.exrc", "set ai")
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	old := filepath.Join(e.store, "Configs", "vim")
+	// A link into the store that set didn't create
+	e.write(t, "Configs/vim/.gvimrc", "")
+	linkTestFile(t, e.inHome(".gvimrc"), filepath.Join(old, ".gvimrc"))
+
+	moved := filepath.Join(e.dir, "dotfiles")
+	if err := os.Rename(e.store, moved); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("TUCKR_STORE", moved)
+	// The file that took .exrc's place isn't the one that was linked
+	writeTestFile(t, filepath.Join(moved, "Configs", "vim", ".exrc"), "set noai")
+
+	status, err := checkGroup(moved, "vim", e.home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]int{}
+	for _, p := range status.problems {
+		kinds[filepath.Base(p.Link.Target)] = p.Kind
+	}
+	if kinds[".vimrc"] != manage.Moved {
+		t.Errorf("~/.vimrc was reported as %v, want a moved link", kinds[".vimrc"])
+	}
+	for _, name := range []string{".exrc", ".gvimrc"} {
+		if kind, ok := kinds[name]; !ok || kind == manage.Moved {
+			t.Errorf("~/%s was reported as %v %v, want it not taken for a moved link", name, kind, ok)
+		}
+	}
+
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), filepath.Join(moved, "Configs", "vim", ".vimrc"))
+	for _, name := range []string{".exrc", ".gvimrc"} {
+		assertLinked(t, e.inHome(name), filepath.Join(old, name))
+	}
+	registry, err := state.LoadLinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := registry[e.inHome(".vimrc")]; entry.Source != filepath.Join(moved, "Configs", "vim", ".vimrc") {
+		t.Errorf("the registry has ~/.vimrc linked to %q after the set", entry.Source)
+	}
+
+	captureOutput(t, func() {
+		if err := runUnset([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertMissing(t, e.inHome(".vimrc"))
+	if registry, err = state.LoadLinks(); err != nil || len(registry) != 0 {
+		t.Errorf("the registry still has %v %v after the unset", registry, err)
+	}
+}
+
+// Creates a symlink at path to dest creating its parent directories
+func linkTestFile(t *testing.T, path string, dest string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dest, path); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/state"
+	"os"
+	"sort"
+	"strconv"
+)
+
+/* Handles the restore command
+Every set that backs up taken targets keeps them in a generation of its own,
+--list prints the generations and --from puts the files of one back */
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	list := flags.Bool("list", false, "list the backup generations from the oldest to the newest")
+	from := flags.String("from", "", "restore the files of the backup generation with this id over their targets")
+	flags.Parse(args)
+	if *list == (*from != "") || flags.NArg() != 0 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := state.BackupsDir()
+	if err != nil {
+		return err
+	}
+	if *list {
+		return listBackups(root)
+	}
+	backup, err := manage.LoadBackup(root, *from)
+	if err != nil {
+		return err
+	}
+	if err := backup.Restore(); err != nil {
+		return err
+	}
+	var targets []string
+	for _, target := range backup.Targets {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		fmt.Println(aurora.Green("Restored:"), target)
+	}
+	return nil
+}
+
+// Prints the ID of every backup generation with how many files it holds
+func listBackups(root string) error {
+	ids, err := manage.ListBackups(root)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Println(aurora.Green("No backups"))
+		return nil
+	}
+	for _, id := range ids {
+		backup, err := manage.LoadBackup(root, id)
+		if err != nil {
+			return err
+		}
+		fmt.Println(id, aurora.Cyan("("+strconv.Itoa(len(backup.Targets))+" files)"))
+	}
+	return nil
+}
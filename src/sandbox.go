@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+/* Handles the sandbox command which sets groups into a throwaway directory and
+prints where it is so what they deploy can be looked at without touching home
+The sandbox is left behind for inspecting it, deleting it is up to the user */
+func runSandbox(args []string) error {
+	flags := flag.NewFlagSet("sandbox", flag.ExitOnError)
+	scripts := flags.Bool("scripts", false, "also run the groups' set_ scripts with $HOME pointing into the sandbox")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) == 0 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	filter, err := deployFilter(root)
+	if err != nil {
+		return err
+	}
+	groups, err := selectGroups(root, args, filter)
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "tuckr-sandbox-")
+	if err != nil {
+		return err
+	}
+	err = sandboxGroups(root, dir, groups, *scripts)
+	fmt.Println(aurora.Green("Sandbox:"), filepath.Join(dir, "home"))
+	return err
+}
+
+/* Sets the groups into the home directory inside of dir
+tuckr's state and cache are kept in dir too and $HOME points into it so the
+targets the groups and the config's TARGETS give relative to home land in the
+sandbox, links outside of it are refused. The store's history is left alone */
+func sandboxGroups(root string, dir string, groups []string, scripts bool) error {
+	conf, err := config.Path()
+	if err != nil {
+		return err
+	}
+	home := filepath.Join(dir, "home")
+	if err := os.MkdirAll(home, 0755); err != nil {
+		return err
+	}
+	for name, value := range map[string]string{
+		"TUCKR_CONFIG":    conf,
+		"TUCKR_STATE_DIR": filepath.Join(dir, "state"),
+		"XDG_CACHE_HOME":  filepath.Join(dir, "cache"),
+		"HOME":            home,
+	} {
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+	return setGroups(root, home, groups, setOptions{confineHome: true, noScripts: !scripts, noHistory: true})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSandboxDeploysAwayFromHome(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	colors := e.write(t, "Configs/vim/.vim/colors/dark.vim", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	e.write(t, "Hooks/vim/set_plugins.sh", `touch "$HOME/ran"`+"\n")
+	state := os.Getenv("TUCKR_STATE_DIR")
+
+	out := captureOutput(t, func() {
+		if err := runSandbox([]string{"--scripts", "vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	var sandbox string
+	for _, line := range strings.Split(uncolored(out), "\n") {
+		if strings.HasPrefix(line, "Sandbox: ") {
+			sandbox = strings.TrimPrefix(line, "Sandbox: ")
+		}
+	}
+	if sandbox == "" {
+		t.Fatalf("the sandbox's path wasn't printed:\n%s", out)
+	}
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(sandbox)) })
+
+	assertLinked(t, filepath.Join(sandbox, ".vimrc"), vimrc)
+	assertLinked(t, filepath.Join(sandbox, ".vim/colors/dark.vim"), colors)
+	assertMissing(t, filepath.Join(sandbox, ".zshrc"))
+	if _, err := os.Stat(filepath.Join(sandbox, "ran")); err != nil {
+		t.Errorf("the set_ script didn't run inside of the sandbox: %v", err)
+	}
+	for _, path := range []string{e.inHome(".vimrc"), e.inHome(".vim"), e.inHome("ran"), state, filepath.Join(e.store, store.HistoryName)} {
+		assertMissing(t, path)
+	}
+}
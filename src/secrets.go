@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/setup"
+	"github.com/raphgl/tuckr/state"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extension that marks a file of a group as encrypted with age
+const encryptedExt = ".age"
+
+// Returns the directory the decrypted secrets of a group are kept in while it's set
+func secretCacheDir(group string) (string, error) {
+	dir, err := state.SecretsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, group), nil
+}
+
+/* Writes the plaintext of a secret of the group to rel inside of its cache, readable
+only by the owner, and returns its path. The file gets shredded when the command
+ends unless the returned keep is called once it's linked, so a set that fails
+after decrypting never leaves the plaintext behind */
+func cachePlaintext(group string, rel string, plaintext []byte) (string, func(), error) {
+	dir, err := secretCacheDir(group)
+	if err != nil {
+		return "", nil, err
+	}
+	path := filepath.Join(dir, rel)
+	keep := registerCleanup(func() {
+		if err := manage.Shred(path); err != nil {
+			fmt.Println(aurora.Yellow("Warning:"), "could not shred", path+":", err)
+		}
+	})
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", nil, err
+	}
+	if err := ioutil.WriteFile(path, plaintext, 0600); err != nil {
+		return "", nil, err
+	}
+	return path, keep, nil
+}
+
+// Shreds the decrypted secrets cached for the group once its links are gone
+func shredSecretCache(group string) error {
+	dir, err := secretCacheDir(group)
+	if err != nil {
+		return err
+	}
+	return manage.Shred(dir)
+}
+
+/* Points the links of the group's encrypted files at where their plaintext gets
+decrypted to and drops the extension from their targets, so key.age in the store
+gets linked as key. Directories linked whole are left as they are */
+func decryptedLinks(root string, group string, links []manage.Link) ([]manage.Link, error) {
+	cache, err := config.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := secretCacheDir(group)
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range links {
+		source := origin(root, cache, l)
+		if isDecrypted(l) || !strings.HasSuffix(source, encryptedExt) {
+			continue
+		}
+		if info, err := os.Stat(source); err == nil && info.IsDir() {
+			continue
+		}
+		rel, err := filepath.Rel(root, strings.TrimSuffix(source, encryptedExt))
+		if err != nil {
+			return nil, err
+		}
+		links[i] = manage.Link{Source: filepath.Join(dir, rel), Target: strings.TrimSuffix(l.Target, encryptedExt)}
+	}
+	return links, nil
+}
+
+// Returns true if the link points at the plaintext of a secret instead of into the store
+func isDecrypted(l manage.Link) bool {
+	dir, err := state.SecretsDir()
+	return err == nil && manage.IsWithin(l.Source, dir)
+}
+
+/* Returns the encrypted file in the store that a decrypted link's plaintext comes from
+The plaintext is kept at the encrypted file's path relative to the store inside of its group's cache */
+func encryptedOrigin(root string, l manage.Link) string {
+	dir, err := state.SecretsDir()
+	if err != nil {
+		return l.Source
+	}
+	rel, err := filepath.Rel(dir, l.Source)
+	parts := strings.SplitN(rel, string(filepath.Separator), 2)
+	if err != nil || len(parts) < 2 {
+		return l.Source
+	}
+	return filepath.Join(root, parts[1]) + encryptedExt
+}
+
+// Returns the config's decrypt_cmd split into words, or age with the default identity when it's not set
+func decryptCommand() ([]string, error) {
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := setup.SplitCommand(conf.General.DecryptCmd)
+	if err != nil || len(cmd) > 0 {
+		return cmd, err
+	}
+	dir, err := config.BaseDir("XDG_CONFIG_HOME")
+	if err != nil {
+		return nil, err
+	}
+	return []string{"age", "--decrypt", "-i", filepath.Join(dir, "age", "keys.txt")}, nil
+}
+
+/* Decrypts the encrypted files of the links pointing at decrypted secrets into the
+group's cache with decryptCommand. The returned keep has to be called once they're
+linked, otherwise their plaintext gets shredded when the command ends */
+func decryptLinks(root string, group string, links []manage.Link, runner setup.CommandRunner) (func(), error) {
+	dir, err := secretCacheDir(group)
+	if err != nil {
+		return nil, err
+	}
+	var cmd []string
+	var keeps []func()
+	for _, l := range links {
+		if !isDecrypted(l) {
+			continue
+		}
+		if cmd == nil {
+			if cmd, err = decryptCommand(); err != nil {
+				return nil, err
+			}
+		}
+		source := encryptedOrigin(root, l)
+		plaintext, err := runner.Output(cmd[0], append(append([]string{}, cmd[1:]...), source)...)
+		if err != nil {
+			return nil, errors.New("Error: Could not decrypt " + source + ": " + err.Error())
+		}
+		rel, err := filepath.Rel(dir, l.Source)
+		if err != nil {
+			return nil, err
+		}
+		_, keep, err := cachePlaintext(group, rel, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		keeps = append(keeps, keep)
+	}
+	return func() {
+		for _, keep := range keeps {
+			keep()
+		}
+	}, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/state"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecryptedSecretsAreShreddedUnlessTheyGetLinked(t *testing.T) {
+	e := newTestEnv(t)
+	e.config(t, "[GENERAL]\ndecrypt_cmd = cat\nconflict_policy = abort\n")
+	e.write(t, "Configs/ssh/.ssh/id_ed25519.age", "private key")
+	e.write(t, "Configs/ssh/.ssh/config", "")
+	writeTestFile(t, e.inHome(".ssh/config"), "mine")
+	dir, err := state.SecretsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := filepath.Join(dir, "ssh", "Configs", "ssh", ".ssh", "id_ed25519")
+
+	// The taken config aborts the set after the key got decrypted
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"ssh"}, setOptions{}); err == nil {
+			t.Fatal("setting over a taken target with the abort policy succeeded")
+		}
+	})
+	if _, err := os.Stat(plaintext); err != nil {
+		t.Fatalf("the key wasn't decrypted before the set failed: %v", err)
+	}
+	runCleanups()
+	assertMissing(t, plaintext)
+
+	if err := os.Remove(e.inHome(".ssh/config")); err != nil {
+		t.Fatal(err)
+	}
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"ssh"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	runCleanups()
+	assertLinked(t, e.inHome(".ssh/id_ed25519"), plaintext)
+	if data, err := ioutil.ReadFile(e.inHome(".ssh/id_ed25519")); err != nil || string(data) != "private key" {
+		t.Errorf("the linked key holds %q %v", data, err)
+	}
+	captureOutput(t, func() {
+		if err := unsetGroups(e.store, e.home, []string{"ssh"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertMissing(t, filepath.Join(dir, "ssh"))
+}
+
+func TestAgeFilesOfAGroupAreLinkedDecrypted(t *testing.T) {
+	e := newTestEnv(t)
+	e.config(t, "[GENERAL]\ndecrypt_cmd = cat\n")
+	e.write(t, "Configs/git/.gitconfig", "[user]\n")
+	e.write(t, "Configs/git/.config/git/credentials.age", "token")
+	dir, err := state.SecretsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := filepath.Join(dir, "git", "Configs", "git", ".config", "git", "credentials")
+	captureOutput(t, func() {
+		if err := runSet([]string{"git"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	runCleanups()
+	assertLinked(t, e.inHome(".gitconfig"), filepath.Join(e.store, "Configs", "git", ".gitconfig"))
+	assertLinked(t, e.inHome(".config/git/credentials"), plaintext)
+	assertMissing(t, e.inHome(".config/git/credentials.age"))
+	if data, err := ioutil.ReadFile(e.inHome(".config/git/credentials")); err != nil || string(data) != "token" {
+		t.Errorf("the linked credentials hold %q %v", data, err)
+	}
+	if info, err := os.Stat(plaintext); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("the plaintext can be read with %v %v, want only its owner to", info.Mode().Perm(), err)
+	}
+	status, err := checkGroup(e.store, "git", e.home)
+	if err != nil || status.linked != 2 || len(status.problems) != 0 {
+		t.Errorf("status has %d of git's links linked and %v %v, want both", status.linked, status.problems, err)
+	}
+}
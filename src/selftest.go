@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+/* Handles the selftest command
+It checks that symlinks can actually be created here before anything gets deployed */
+func runSelftest(args []string) error {
+	dir, err := ioutil.TempDir("", "tuckr-selftest")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := selftest(dir, os.Symlink); err != nil {
+		fmt.Println(aurora.Red("Failed:"), "symlinks can't be created")
+		return err
+	}
+	fmt.Println(aurora.Green("Passed:"), "symlinks can be created and removed")
+	return nil
+}
+
+/* Creates a file in dir, links to it with symlink and removes the link again
+The error says which step failed and how to fix it when the platform is known for it */
+func selftest(dir string, symlink func(oldname string, newname string) error) error {
+	source := filepath.Join(dir, "source")
+	target := filepath.Join(dir, "target")
+	if err := ioutil.WriteFile(source, []byte("tuckr\n"), 0644); err != nil {
+		return errors.New("Error: Could not create a file in " + dir + ": " + err.Error())
+	}
+	if err := symlink(source, target); err != nil {
+		hint := ""
+		if runtime.GOOS == "windows" {
+			hint = ", enable Developer Mode or run tuckr as an administrator to allow symlinks"
+		}
+		return errors.New("Error: Could not create a symlink in " + dir + ": " + err.Error() + hint)
+	}
+	if dest, err := os.Readlink(target); err != nil || dest != source {
+		return errors.New("Error: The symlink created in " + dir + " doesn't point to where it should")
+	}
+	if err := os.Remove(target); err != nil {
+		return errors.New("Error: Could not remove the symlink in " + dir + ": " + err.Error())
+	}
+	return nil
+}
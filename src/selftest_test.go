@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelftestPassesWhereSymlinksWork(t *testing.T) {
+	e := newTestEnv(t)
+	if err := selftest(e.dir, os.Symlink); err != nil {
+		t.Fatalf("selftest failed with working symlinks: %v", err)
+	}
+	assertMissing(t, filepath.Join(e.dir, "target"))
+	out := captureOutput(t, func() {
+		if err := runSelftest(nil); err != nil {
+			t.Error(err)
+		}
+	})
+	if !strings.Contains(out, "Passed:") {
+		t.Errorf("selftest printed %q, want it to pass", out)
+	}
+}
+
+func TestSelftestDescribesADeniedSymlink(t *testing.T) {
+	e := newTestEnv(t)
+	denied := func(oldname string, newname string) error {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+	err := selftest(e.dir, denied)
+	if err == nil || !strings.Contains(err.Error(), "Could not create a symlink in "+e.dir) || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("a denied symlink failed with %v, want what was denied and where", err)
+	}
+
+	// A symlink that ends up pointing elsewhere doesn't pass either
+	misplaced := func(oldname string, newname string) error {
+		return os.Symlink(filepath.Join(e.dir, "elsewhere"), newname)
+	}
+	if err := selftest(e.dir, misplaced); err == nil || !strings.Contains(err.Error(), "doesn't point to where it should") {
+		t.Errorf("a misplaced symlink failed with %v", err)
+	}
+}
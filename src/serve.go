@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/store"
+	"net"
+	"net/http"
+	"os"
+)
+
+// The deployment status of the store as the health endpoint reports it
+type healthReport struct {
+	Status string        `json:"status"`
+	Groups []groupHealth `json:"groups"`
+}
+
+// How much of a group is deployed as the health endpoint reports it
+type groupHealth struct {
+	Name     string   `json:"name"`
+	Files    int      `json:"files"`
+	Linked   int      `json:"linked"`
+	Problems []string `json:"problems"`
+}
+
+/* Handles the serve command which reports the deployment status over http for monitoring
+Nothing gets served unless --http is given so the endpoint is never opened by accident */
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("http", "", "serve the deployment status as json on this address, like localhost:8080")
+	userName := flags.String("user", "", "report on the links in this user's home instead")
+	flags.Parse(args)
+	if *addr == "" || flags.NArg() != 0 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := targetHome(*userName)
+	if err != nil {
+		return err
+	}
+	// listening first gives the real port when the address asks for any free one
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	fmt.Println(aurora.Green("Serving:"), "the status of", root, "on http://"+listener.Addr().String()+"/health")
+	return http.Serve(listener, healthHandler(root, home))
+}
+
+/* Returns the handler for /health which checks every group on each request
+It answers 200 when everything is linked without problems and 503 otherwise */
+func healthHandler(root string, home string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		report, inSync, err := checkHealth(root, home)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !inSync {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+	return mux
+}
+
+// Checks every group of the store the same way status does
+func checkHealth(root string, home string) (healthReport, bool, error) {
+	report := healthReport{Groups: []groupHealth{}}
+	filter, err := platformFilter(root)
+	if err != nil {
+		return report, false, err
+	}
+	groups, err := store.ListGroups(root, filter)
+	if err != nil {
+		return report, false, err
+	}
+	var statuses []groupStatus
+	for _, group := range groups {
+		status, err := checkGroup(root, group, home)
+		if err != nil {
+			return report, false, err
+		}
+		statuses = append(statuses, status)
+		health := groupHealth{Name: group, Files: len(status.links), Linked: status.linked, Problems: []string{}}
+		for _, p := range status.problems {
+			health.Problems = append(health.Problems, p.String()+": "+p.Link.Target)
+		}
+		report.Groups = append(report.Groups, health)
+	}
+	_, inSync := summarize(statuses)
+	report.Status = "in-sync"
+	if !inSync {
+		report.Status = "drifted"
+	}
+	return report, inSync, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestHealthEndpointServesTheStatus(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/vim/.exrc", "")
+	if err := setGroups(e.store, e.home, []string{"vim"}, setOptions{quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go http.Serve(listener, healthHandler(e.store, e.home))
+	url := "http://" + listener.Addr().String() + "/health"
+	get := func() (int, healthReport) {
+		t.Helper()
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var report healthReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, report
+	}
+
+	code, report := get()
+	want := healthReport{Status: "in-sync", Groups: []groupHealth{{Name: "vim", Files: 2, Linked: 2, Problems: []string{}}}}
+	if code != http.StatusOK || !reflect.DeepEqual(report, want) {
+		t.Errorf("an in sync store got %d %+v, want 200 %+v", code, report, want)
+	}
+
+	e.write(t, "Configs/vim/.gvimrc", "")
+	code, report = get()
+	if code != http.StatusServiceUnavailable || report.Status != "drifted" || report.Groups[0].Files != 3 || report.Groups[0].Linked != 2 {
+		t.Errorf("a drifted store got %d %+v", code, report)
+	}
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+}
@@ -0,0 +1,1032 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/setup"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options that change how groups get deployed by set
+type setOptions struct {
+	dryRun      bool
+	incremental bool
+	confineHome bool
+	onlyNew     bool
+	// Collects how long linking and scripts take, nil unless --timings is passed
+	timings *timings
+	// Collects what a dry run would do, nil unless --plan-out is passed
+	plan *setPlan
+	// The plan set --plan applies, only what it planned gets done
+	apply *setPlan
+	/* Where the answers to --confirm-each's prompts are read from, nil approves everything
+	The links' and scripts' prompts share it so neither buffers away the other's answers */
+	confirmIn *bufio.Reader
+	// Links point into the cache as if the store was read-only, for stores deleted after set
+	copyStore bool
+	// Symlinks in the store get linked to what they point to instead of to themselves
+	dereference bool
+	// Files more than this many directories deep in a group aren't linked, 0 links them all
+	maxDepth int
+	// Only the files of a group whose top level entry starts with a dot are linked, its other folder types all are
+	dotfilesOnly bool
+	// A group fails instead of creating the missing parent directories of its targets
+	noParents bool
+	// Only problems get printed so that a set with nothing to do prints nothing
+	quiet bool
+	// Why files and groups are left alone gets printed
+	explain bool
+	// The command links the current user can't create are created through, nil
+	// creates every link as the current user
+	escalation []string
+	// The environment scripts get is printed before they run
+	dumpEnv bool
+	// Only the links get created, the groups' set_ scripts aren't run
+	noScripts bool
+	// The set isn't recorded in the store's history, for sets that don't deploy for real
+	noHistory bool
+}
+
+/* Handles the set command
+Every group's files are symlinked into its targets and its set_ scripts are run
+With --dry-run the links are only printed and the scripts are only syntax checked,
+--plan-out also writes them to a file that --plan applies later */
+func runSet(args []string) error {
+	var opts setOptions
+	// --dump-env is a debugging aid so it's left out of the flags shown by -h
+	if len(args) > 0 && args[len(args)-1] == "--dump-env" {
+		opts.dumpEnv = true
+		args = args[:len(args)-1]
+	}
+	flags := flag.NewFlagSet("set", flag.ExitOnError)
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "print what would be done without doing it")
+	flags.BoolVar(&opts.incremental, "incremental", false, "only deploy files that changed since the last set")
+	flags.BoolVar(&opts.confineHome, "confine-home", false, "refuse to create links outside of $HOME")
+	flags.BoolVar(&opts.dereference, "dereference", false, "link symlinks in the store to the files they point to instead of to the symlinks")
+	flags.IntVar(&opts.maxDepth, "max-depth", 0, "only link the files of a group at most this many directories deep, 0 links them all")
+	flags.BoolVar(&opts.dotfilesOnly, "dotfiles-only", false, "only link the entries of a group whose names start with a dot and what's inside of them, its Bin and other folder types are linked whole")
+	reconcile := flags.Bool("reconcile", false, "only add, fix and remove the links needed to match the store, without running scripts")
+	flags.BoolVar(&opts.noParents, "no-parents", false, "fail instead of creating the directories the links go in when they're missing")
+	flags.BoolVar(&opts.quiet, "quiet", false, "only print problems, nothing is printed when everything is already linked")
+	flags.BoolVar(&opts.explain, "explain", false, "print why each file or group that isn't linked is left alone")
+	flags.BoolVar(&opts.onlyNew, "new", false, "only deploy groups that weren't set before, all groups are considered if none are given")
+	userName := flags.String("user", "", "deploy into this user's home instead")
+	confirmEach := flags.Bool("confirm-each", false, "ask before creating each link and running each script")
+	showTimings := flags.Bool("timings", false, "print how long linking and running scripts took")
+	escalate := flags.Bool("escalate", false, "create the links the current user can't, like the ones in /etc, through the config's escalation_cmd")
+	planOut := flags.String("plan-out", "", "write what would be done to this file as json, implies --dry-run")
+	emitSh := flags.String("emit-sh", "", "write a shell script that does what would be done to this file, implies --dry-run")
+	emitTmpfiles := flags.String("emit-tmpfiles", "", "write the links that would be created to this file as a systemd-tmpfiles.d config, implies --dry-run")
+	planIn := flags.String("plan", "", "apply a plan written by --plan-out instead of working one out")
+	from := flags.String("from", "", "clone this git url into a temporary store and deploy from it, all groups are considered if none are given")
+	keep := flags.Bool("keep", false, "keep the store cloned by --from instead of deleting it")
+	fromFile := flags.String("from-file", "", "also set the groups listed in this file, one group or glob per line")
+	tags := flags.String("tag", "", "also set the groups whose .tuckr.json carries any of these comma separated tags")
+	since := flags.String("since", "", "only deploy groups that changed between this git ref and HEAD, all groups are considered if none are given")
+	flags.Parse(args)
+	args = flags.Args()
+	if opts.maxDepth < 0 {
+		return errors.New("Error: --max-depth can't be negative")
+	}
+	if *showTimings {
+		opts.timings = newTimings(time.Now)
+	}
+	if *fromFile != "" {
+		listed, err := readGroupList(*fromFile)
+		if err != nil {
+			return err
+		}
+		if len(listed) == 0 {
+			return errors.New("Error: " + *fromFile + " lists no groups")
+		}
+		args = append(args, listed...)
+	}
+	if *confirmEach {
+		opts.confirmIn = bufio.NewReader(os.Stdin)
+	}
+	if *escalate {
+		conf, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
+		if opts.escalation, err = setup.SplitCommand(conf.General.EscalationCmd); err != nil {
+			return err
+		}
+		if opts.escalation == nil {
+			opts.escalation = []string{}
+		}
+	}
+	if *planIn != "" {
+		plan, err := readPlan(*planIn)
+		if err != nil {
+			return err
+		}
+		return maintain(applyPlan(plan, opts), setup.ExecRunner{})
+	}
+	if len(args) == 0 && (opts.onlyNew || *since != "" || *from != "") {
+		args = []string{"*"}
+	}
+	if len(args) == 0 && *tags == "" {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	var root string
+	var err error
+	if *from != "" && opts.dryRun {
+		return previewClone(*from)
+	}
+	if *from != "" {
+		if root, err = cloneTemporaryStore(*from, setup.ExecRunner{}); err != nil {
+			return err
+		}
+		if *keep {
+			defer fmt.Println(aurora.Green("Kept store:"), root)
+		} else {
+			defer os.RemoveAll(root)
+			opts.copyStore = true
+		}
+	} else if root, err = store.Root(); err != nil {
+		return err
+	}
+	home, err := targetHome(*userName)
+	if err != nil {
+		return err
+	}
+	filter, err := deployFilter(root)
+	if err != nil {
+		return err
+	}
+	groups, err := selectGroups(root, args, filter)
+	if err != nil {
+		return err
+	}
+	if *tags != "" {
+		if groups, err = addTagged(root, groups, filter, *tags); err != nil {
+			return err
+		}
+	}
+	if opts.explain {
+		if err := printFilteredGroups(root, args, groups); err != nil {
+			return err
+		}
+	}
+	if *since != "" {
+		if groups, err = sinceRef(root, groups, *since, setup.ExecRunner{}); err != nil {
+			return err
+		}
+		if len(groups) == 0 {
+			fmt.Println(aurora.Green("No groups changed since"), *since)
+			return nil
+		}
+	}
+	if opts.onlyNew {
+		deployed, err := state.LoadDeployed()
+		if err != nil {
+			return err
+		}
+		groups = deployed.New(groups)
+		if len(groups) == 0 {
+			fmt.Println(aurora.Green("No new groups to set"))
+			return nil
+		}
+	}
+	if *reconcile {
+		err = reconcileGroups(root, home, groups, opts.dryRun)
+		if opts.dryRun {
+			return err
+		}
+		return maintain(err, setup.ExecRunner{})
+	}
+	if *planOut != "" || *emitSh != "" || *emitTmpfiles != "" {
+		opts.dryRun = true
+		opts.plan = &setPlan{Root: root, Home: home}
+	}
+	err = setGroups(root, home, groups, opts)
+	opts.timings.print()
+	if opts.dryRun {
+		if err == nil && *planOut != "" {
+			err = writePlan(*planOut, opts.plan)
+		}
+		if err == nil && *emitSh != "" {
+			err = writeShellScript(*emitSh, opts.plan)
+		}
+		if err == nil && *emitTmpfiles != "" {
+			err = writeTmpfiles(*emitTmpfiles, opts.plan)
+		}
+		return err
+	}
+	return maintain(err, setup.ExecRunner{})
+}
+
+/* Clones url into a new temporary directory with the config's clone command and
+returns the directory, nothing is left behind if the clone fails */
+func cloneTemporaryStore(url string, runner setup.CommandRunner) (string, error) {
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	dir, err := ioutil.TempDir("", "tuckr-store")
+	if err != nil {
+		return "", err
+	}
+	general := conf.General
+	general.DotfilesRepo = url
+	general.DotfilesDest = dir
+	if err := setup.CloneFiles(general, runner); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+/* Prints the command --from would clone url with
+Nothing is cloned so there's no store to work out the links from */
+func previewClone(url string) error {
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	general := conf.General
+	general.DotfilesRepo = url
+	general.DotfilesDest = filepath.Join(os.TempDir(), "tuckr-store*")
+	cmd, err := setup.CloneCommand(general)
+	if err != nil {
+		return err
+	}
+	fmt.Println(aurora.Cyan("Would clone:"), strings.Join(cmd, " "))
+	return nil
+}
+
+// Returns the groups out of groups that changed between ref and the store's HEAD
+func sinceRef(root string, groups []string, ref string, runner setup.CommandRunner) ([]string, error) {
+	changed, err := changedGroups(root, ref, "HEAD", runner)
+	if err != nil {
+		return nil, err
+	}
+	selected := map[string]bool{}
+	for _, group := range groups {
+		selected[group] = true
+	}
+	var result []string
+	for _, group := range changed {
+		if selected[group] {
+			result = append(result, group)
+		}
+	}
+	return result, nil
+}
+
+/* Deploys every group into its targets and runs its set_ scripts
+The files that get deployed are recorded in the index so that an incremental set
+can skip the ones that haven't changed since, and groups that get deployed are
+recorded so that set --new can skip them. Disabled groups are skipped and groups
+are set after the ones they depend on */
+func setGroups(root string, home string, groups []string, opts setOptions) error {
+	groups, err := store.SortByDependencies(root, groups)
+	if err != nil {
+		return err
+	}
+	index, err := state.LoadIndex()
+	if err != nil {
+		return err
+	}
+	deployedGroups, err := state.LoadDeployed()
+	if err != nil {
+		return err
+	}
+	cache, err := config.CacheDir()
+	if err != nil {
+		return err
+	}
+	resolve, err := conflictResolver()
+	if err != nil {
+		return err
+	}
+	if opts.apply != nil {
+		resolve = opts.apply.resolver()
+	}
+	disabled, err := state.LoadDisabled()
+	if err != nil {
+		return err
+	}
+	backups, err := state.BackupsDir()
+	if err != nil {
+		return err
+	}
+	backup := manage.NewBackup(backups, time.Now())
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	failed := false
+	results := &manage.Results{}
+	history := store.HistoryEntry{Operation: "set", Groups: groups}
+	for _, group := range groups {
+		reason, err := groupSkipReason(root, group, disabled)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			if !opts.quiet {
+				fmt.Println(aurora.Yellow("Skipping:"), group, reason)
+			}
+			continue
+		}
+		conf, err := store.LoadGroupConfig(root, group)
+		if err != nil {
+			return err
+		}
+		deployments, err := groupDeployments(root, group, home)
+		if err != nil {
+			return err
+		}
+		deployed := 0
+		changed := 0
+		planned := plannedGroup{Group: group}
+		stopLinking := opts.timings.start("linking")
+		for _, d := range deployments {
+			d.dereference = opts.dereference
+			d.maxDepth = opts.maxDepth
+			d.dotfilesOnly = opts.dotfilesOnly
+			links, err := groupLinks(root, group, d)
+			if err == nil && opts.copyStore {
+				links, err = linksIntoCache(root, links)
+			}
+			if err == nil && opts.explain {
+				var skipped []skippedLink
+				if skipped, err = skippedLinks(root, group, d, links); err == nil {
+					// linking reports the conflicts it skips by itself
+					if opts.dryRun {
+						printSkipped(skipped, skipIgnored, skipLinked, skipConflict)
+					} else {
+						printSkipped(skipped, skipIgnored, skipLinked)
+					}
+				}
+			}
+			if err == nil && opts.incremental {
+				links, err = changedLinks(root, index, links)
+			}
+			if err == nil && opts.confineHome {
+				err = confineToHome(links, home)
+			}
+			if err == nil && opts.noParents {
+				err = requireParents(links)
+			}
+			if err == nil {
+				err = outsideStore(links, root)
+			}
+			if err == nil && manage.CaseInsensitive(runtime.GOOS) {
+				err = caseCollisions(links)
+			}
+			if err == nil && opts.apply != nil {
+				links = opts.apply.links(group, links)
+			}
+			if err == nil && opts.confirmIn != nil && !opts.dryRun {
+				links = approvedLinks(links, opts.confirmIn)
+			}
+			changed += len(links)
+			keepSecrets := func() {}
+			if err == nil {
+				if opts.dryRun {
+					for _, l := range links {
+						if !l.IsLinked() {
+							fmt.Println(aurora.Cyan("Would link:"), l.Target, "->", l.Source)
+						}
+					}
+					if opts.plan != nil {
+						err = planned.addLinks(links, func(l manage.Link) bool {
+							return isExecutable(root, cache, d, conf, l)
+						}, resolve)
+					}
+				} else if err = cacheLinks(root, links); err == nil {
+					if err = removeMovedLinks(registry, root, cache, group, d, links, opts.quiet); err == nil {
+						keepSecrets, err = decryptLinks(root, group, links, setup.ExecRunner{})
+					}
+				}
+				if err == nil && !opts.dryRun {
+					var fresh []manage.Link
+					for _, l := range links {
+						if !l.IsLinked() {
+							fresh = append(fresh, l)
+						}
+					}
+					unprivileged, privileged := links, []manage.Link(nil)
+					if opts.escalation != nil {
+						unprivileged, privileged = splitPrivileged(links)
+					}
+					stopProgress := showProgress(group, unprivileged, results, opts.quiet)
+					err = manage.CreateLinksResolving(unprivileged, resolve, backup, results)
+					stopProgress()
+					if err == nil && len(privileged) > 0 {
+						err = createPrivilegedLinks(privileged, opts.escalation, escalationRunner, results)
+					}
+					if err == nil {
+						err = runActions(root, cache, d, conf.Actions, fresh, setup.ExecRunner{})
+					}
+				}
+			}
+			if err == nil && !opts.dryRun {
+				var executables []manage.Link
+				for _, l := range links {
+					if isExecutable(root, cache, d, conf, l) {
+						executables = append(executables, l)
+					}
+				}
+				err = makeExecutable(executables)
+			}
+			if err == nil && !opts.dryRun {
+				for _, l := range links {
+					if err = index.Record(origin(root, cache, l)); err != nil {
+						break
+					}
+				}
+			}
+			if err == nil && !opts.dryRun {
+				err = recordLinks(registry, root, cache, group, d, links)
+			}
+			if err != nil {
+				fmt.Println(aurora.Red("Failed:"), group, "could not be deployed to", d.target)
+				fmt.Println(err)
+				results.Fail(d.target, err)
+				failed = true
+				history.Failed++
+				continue
+			}
+			keepSecrets()
+			history.Links += len(links)
+			deployed++
+		}
+		stopLinking()
+		if deployed == 0 {
+			continue
+		}
+		if !opts.dryRun {
+			deployedGroups[group] = time.Now()
+		}
+		if opts.incremental && changed == 0 {
+			continue
+		}
+		if opts.plan != nil {
+			if planned.Scripts, err = plannedScripts(root, group, "set_"); err != nil {
+				return err
+			}
+			opts.plan.Groups = append(opts.plan.Groups, planned)
+		}
+		if opts.noScripts {
+			continue
+		}
+		stopScripts := opts.timings.start("scripts")
+		err = runHooks(root, group, "set_", opts)
+		stopScripts()
+		if err != nil {
+			fmt.Println(aurora.Red(err))
+			failed = true
+		}
+	}
+	if !opts.dryRun {
+		if err := index.Save(); err != nil {
+			return err
+		}
+		if err := deployedGroups.Save(); err != nil {
+			return err
+		}
+		if registry != nil {
+			if err := registry.Save(); err != nil {
+				return err
+			}
+		}
+		if !opts.noHistory {
+			if err := appendHistory(root, history); err != nil {
+				fmt.Println(aurora.Yellow("Warning:"), "could not record the set in the history:", err)
+			}
+		}
+		if !opts.quiet {
+			fmt.Println(aurora.Green("Links:"), results)
+		}
+	}
+	if failed {
+		return errors.New("Error: Some groups could not be fully deployed")
+	}
+	return nil
+}
+
+// How many links a deployment needs to create for set to show its progress
+const progressThreshold = 100
+
+/* Shows the progress of creating the links once there are enough of them that it
+takes a while, it's drawn from the outcomes recorded into results until the returned
+function is called. It's a bar when stdout is a terminal and plain lines otherwise */
+func showProgress(label string, links []manage.Link, results *manage.Results, quiet bool) func() {
+	total := 0
+	for _, l := range links {
+		if !l.IsLinked() {
+			total++
+		}
+	}
+	if quiet || total < progressThreshold {
+		return func() {}
+	}
+	progress := manage.NewProgress(os.Stdout, label, total, isTerminal(os.Stdout))
+	stop := results.Watch(progress.Record)
+	return func() {
+		stop()
+		progress.Finish()
+	}
+}
+
+/* Returns true if f is a terminal, any character device is taken for one except for
+the null device, which is what stdin usually is for cron jobs and services */
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+/* Returns how set resolves targets taken by other files
+When stdin is a terminal each conflict is prompted for, otherwise the config's
+conflict_policy is used */
+func conflictResolver() (manage.ConflictResolver, error) {
+	if isTerminal(os.Stdin) {
+		return manage.PromptResolver(os.Stdin), nil
+	}
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return manage.PolicyResolver(conf.General.ConflictPolicy)
+}
+
+/* Runs the actions declared for the files of the links that were just created
+Each command is split into words like a shell would and any {} argument becomes the
+link's path as a single argument no matter what characters it has */
+func runActions(root string, cache string, d deployment, actions map[string][]string, links []manage.Link, runner setup.CommandRunner) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	for _, l := range links {
+		if !l.IsLinked() {
+			continue
+		}
+		rel, err := filepath.Rel(d.src, origin(root, cache, l))
+		if err != nil {
+			return err
+		}
+		for _, action := range actions[filepath.ToSlash(rel)] {
+			cmd, err := setup.SplitCommand(action)
+			if err != nil {
+				return err
+			}
+			if len(cmd) == 0 {
+				continue
+			}
+			for i, arg := range cmd {
+				if arg == "{}" {
+					cmd[i] = l.Target
+				}
+			}
+			fmt.Println(aurora.Green("Running action:"), action, "for", l.Target)
+			if err := runner.Run(cmd[0], cmd[1:]...); err != nil {
+				return errors.New("Error: Action " + action + " failed for " + l.Target + ": " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the links that are already linked or that get a yes when asked about reading from in
+func approvedLinks(links []manage.Link, in *bufio.Reader) []manage.Link {
+	var approved []manage.Link
+	for _, l := range links {
+		if l.IsLinked() || confirm(in, "Link "+l.Target+" -> "+l.Source+"?") {
+			approved = append(approved, l)
+		}
+	}
+	return approved
+}
+
+/* Returns an error for the first link whose target is inside of the store
+That happens when a parent of the target is a symlink into the store, like a
+directory that used to be linked whole, and linking would write into the store */
+func outsideStore(links []manage.Link, root string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+	for _, l := range links {
+		if manage.IsWithin(l.Target, root) || manage.IsWithin(l.Target, realRoot) {
+			return errors.New("Error: " + l.Target + " is inside of the store, one of its parent directories is a symlink into it")
+		}
+	}
+	return nil
+}
+
+// Returns an error naming the first pair of links whose targets only differ in case
+func caseCollisions(links []manage.Link) error {
+	collisions := manage.CaseCollisions(links)
+	if len(collisions) == 0 {
+		return nil
+	}
+	pair := collisions[0]
+	return errors.New("Error: " + pair[0].Source + " and " + pair[1].Source + " would overwrite each other on a case-insensitive filesystem")
+}
+
+/* Returns true if the link's store file has to be executable
+Every file of an executable deployment like Bin is, and so are the files listed
+under executable in the group's .tuckr.json */
+func isExecutable(root string, cache string, d deployment, conf store.GroupConfig, l manage.Link) bool {
+	if d.executable {
+		return true
+	}
+	rel, err := filepath.Rel(d.src, origin(root, cache, l))
+	if err != nil {
+		return false
+	}
+	for _, file := range conf.Executable {
+		if filepath.Clean(file) == rel {
+			return true
+		}
+	}
+	return false
+}
+
+/* Makes the store files of the links executable by everyone who can read them
+A warning is printed when the file's filesystem doesn't keep the execute bit */
+func makeExecutable(links []manage.Link) error {
+	for _, l := range links {
+		info, err := os.Stat(l.Source)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			continue
+		}
+		mode := info.Mode().Perm()
+		if err := os.Chmod(l.Source, mode|(mode&0444)>>2); err != nil {
+			return err
+		}
+		if runtime.GOOS == "windows" {
+			continue
+		}
+		if info, err := os.Stat(l.Source); err == nil && info.Mode().Perm()&0111 == 0 {
+			fmt.Println(aurora.Yellow("Warning:"), l.Source, "is on a filesystem that can't mark it executable")
+		}
+	}
+	return nil
+}
+
+// Returns an error naming the first link whose target is outside of home
+func confineToHome(links []manage.Link, home string) error {
+	realHome, err := filepath.EvalSymlinks(home)
+	if err != nil {
+		realHome = home
+	}
+	for _, l := range links {
+		if !manage.IsWithin(l.Target, home) && !manage.IsWithin(l.Target, realHome) {
+			return errors.New("Error: " + l.Target + " is outside of " + home)
+		}
+	}
+	return nil
+}
+
+/* Returns an error naming the first link that isn't linked yet whose target's parent
+directory is missing, so nothing gets linked into directories set would have to create */
+func requireParents(links []manage.Link) error {
+	for _, l := range links {
+		if l.IsLinked() {
+			continue
+		}
+		parent := filepath.Dir(l.Target)
+		if info, err := os.Stat(parent); err != nil || !info.IsDir() {
+			return errors.New("Error: " + parent + " doesn't exist to link " + l.Target + " into, create it or set without --no-parents")
+		}
+	}
+	return nil
+}
+
+// Returns the links whose store file changed since it was recorded in the index
+func changedLinks(root string, index state.FileIndex, links []manage.Link) ([]manage.Link, error) {
+	cache, err := config.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	var changed []manage.Link
+	for _, l := range links {
+		ok, err := index.Changed(origin(root, cache, l))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			fmt.Println(aurora.Cyan("Changed:"), origin(root, cache, l))
+			changed = append(changed, l)
+		}
+	}
+	return changed, nil
+}
+
+/* Handles the unset command by removing the links of every group and running its unset_ scripts
+When the first argument is a group and the second one isn't, the arguments after
+the group are taken as files of it and only their links are removed */
+func runUnset(args []string) error {
+	flags := flag.NewFlagSet("unset", flag.ExitOnError)
+	userName := flags.String("user", "", "remove the links from this user's home instead")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) == 0 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := targetHome(*userName)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 && store.HasGroup(root, args[0]) && !store.HasGroup(root, args[1]) {
+		return maintain(unsetFiles(root, home, args[0], args[1:]), setup.ExecRunner{})
+	}
+	groups, err := selectGroups(root, args, store.GroupFilter{})
+	if err != nil {
+		return err
+	}
+	return maintain(unsetGroups(root, home, groups), setup.ExecRunner{})
+}
+
+/* Removes only the links of the given files of a group, leaving the rest of it set
+Files are relative to the group's directory and a directory stands for every file
+in it. No scripts are run since the group stays set */
+func unsetFiles(root string, home string, group string, files []string) error {
+	cache, err := config.CacheDir()
+	if err != nil {
+		return err
+	}
+	deployments, err := groupDeployments(root, group, home)
+	if err != nil {
+		return err
+	}
+	matched := map[string]bool{}
+	var links []manage.Link
+	for _, d := range deployments {
+		candidates, err := groupLinks(root, group, d)
+		if err != nil {
+			return err
+		}
+		for _, l := range candidates {
+			rel, err := filepath.Rel(d.src, origin(root, cache, l))
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				file = filepath.Clean(file)
+				if rel == file || strings.HasPrefix(rel, file+string(filepath.Separator)) {
+					matched[file] = true
+					links = append(links, l)
+					break
+				}
+			}
+		}
+	}
+	for _, file := range files {
+		if !matched[filepath.Clean(file)] {
+			return errors.New("Error: " + file + " is not a file of " + group)
+		}
+	}
+	removed := 0
+	for _, l := range links {
+		if l.IsLinked() {
+			removed++
+			fmt.Println(aurora.Green("Unlinked:"), l.Target)
+		}
+	}
+	if err := manage.RemoveLinks(links); err != nil {
+		return err
+	}
+	if err := forgetLinks(links); err != nil {
+		return err
+	}
+	history := store.HistoryEntry{Operation: "unset", Groups: []string{group}, Links: removed}
+	if err := appendHistory(root, history); err != nil {
+		fmt.Println(aurora.Yellow("Warning:"), "could not record the unset in the history:", err)
+	}
+	return nil
+}
+
+/* Removes the links of every group from its targets and runs its unset_ scripts
+Links the registry knows set created into where the store was before it moved are removed too */
+func unsetGroups(root string, home string, groups []string) error {
+	deployedGroups, err := state.LoadDeployed()
+	if err != nil {
+		return err
+	}
+	cache, err := config.CacheDir()
+	if err != nil {
+		return err
+	}
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	history := store.HistoryEntry{Operation: "unset", Groups: groups}
+	defer func() {
+		if err := appendHistory(root, history); err != nil {
+			fmt.Println(aurora.Yellow("Warning:"), "could not record the unset in the history:", err)
+		}
+	}()
+	for _, group := range groups {
+		deployments, err := groupDeployments(root, group, home)
+		if err != nil {
+			return err
+		}
+		for _, d := range deployments {
+			links, err := groupLinks(root, group, d)
+			if err != nil {
+				return err
+			}
+			moved, err := movedLinks(registry, root, cache, group, d, links)
+			if err != nil {
+				return err
+			}
+			for _, l := range moved {
+				if err := os.Remove(l.Target); err != nil {
+					history.Failed++
+					return err
+				}
+			}
+			if err := manage.RemoveLinks(links); err != nil {
+				history.Failed++
+				return err
+			}
+			history.Links += len(links)
+			for _, l := range links {
+				delete(registry, l.Target)
+			}
+		}
+		if registry != nil {
+			if err := registry.Save(); err != nil {
+				return err
+			}
+		}
+		if err := shredSecretCache(group); err != nil {
+			return err
+		}
+		delete(deployedGroups, group)
+		if err := deployedGroups.Save(); err != nil {
+			return err
+		}
+		if err := runHooks(root, group, "unset_", setOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Prints the environment the scripts of the handle get with secrets redacted
+Variables that come from the hooks' .env are marked since tuckr adds them */
+func dumpEnv(handle setup.SetupHandle) {
+	fmt.Println(aurora.Cyan("Environment:"), "of the scripts in", handle.Dir)
+	values := map[string]string{}
+	var names []string
+	for _, kv := range setup.RedactEnv(handle.ScriptEnv()) {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		if _, ok := values[kv[:i]]; !ok {
+			names = append(names, kv[:i])
+		}
+		values[kv[:i]] = kv[i+1:]
+	}
+	fromEnv := map[string]bool{}
+	for _, kv := range handle.Env {
+		if i := strings.Index(kv, "="); i > 0 {
+			fromEnv[kv[:i]] = true
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if fromEnv[name] {
+			fmt.Println("  "+name+"="+values[name], aurora.Cyan("(from "+setup.EnvName+")"))
+		} else {
+			fmt.Println("  " + name + "=" + values[name])
+		}
+	}
+}
+
+/* Returns the handles for a group's hook scripts
+The scripts shared by every platform are in Hooks/<group>, or the hooks directory of
+its .tuckr.json, followed by the ones in its <os> subdirectory for the platform
+tuckr runs on which also get the shared .env. Both get the $PATH of scriptPath */
+func hookHandles(root string, group string) ([]setup.SetupHandle, error) {
+	dir, err := store.HooksPath(root, group)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	shared, err := setup.NewSetupHandleAt(dir)
+	if err != nil {
+		return nil, err
+	}
+	if shared.Path, err = scriptPath(); err != nil {
+		return nil, err
+	}
+	handles := []setup.SetupHandle{shared}
+	osDir := filepath.Join(dir, runtime.GOOS)
+	if info, err := os.Stat(osDir); err != nil || !info.IsDir() {
+		return handles, nil
+	}
+	platform, err := setup.NewSetupHandleAt(osDir)
+	if err != nil {
+		return nil, err
+	}
+	platform.Env = append(append([]string{}, shared.Env...), platform.Env...)
+	platform.Path = shared.Path
+	return append(handles, platform), nil
+}
+
+/* Runs the scripts of a group that start with prefix, the shared ones first and
+then the ones for the current platform
+With --dry-run the scripts are only syntax checked instead, with --confirm-each
+each script is only run if it gets a yes, with --plan only the planned ones are run
+and with --quiet they're run silently */
+func runHooks(root string, group string, prefix string, opts setOptions) error {
+	handles, err := hookHandles(root, group)
+	if err != nil {
+		return err
+	}
+	hooks, err := store.HooksPath(root, group)
+	if err != nil {
+		return err
+	}
+	dryRun, confirmIn := opts.dryRun, opts.confirmIn
+	var failure error
+	for _, handle := range handles {
+		handle.Quiet = opts.quiet
+		if opts.dumpEnv && len(handle.Scripts(prefix)) > 0 {
+			dumpEnv(handle)
+		}
+		if !dryRun {
+			if err := flagUnlistedScripts(root, handle.Dir, handle.Scripts(prefix)); err != nil {
+				return err
+			}
+		}
+		if !dryRun && (confirmIn != nil || opts.apply != nil) {
+			for _, script := range handle.Scripts(prefix) {
+				path := filepath.Join(handle.Dir, script)
+				if opts.apply != nil {
+					rel, err := filepath.Rel(hooks, path)
+					if err != nil || !opts.apply.runs(group, filepath.ToSlash(rel)) {
+						continue
+					}
+				}
+				if confirmIn != nil && !confirm(confirmIn, "Run "+path+"?") {
+					continue
+				}
+				if err := handle.RunScript(script); err != nil {
+					fmt.Println(aurora.Red(err))
+					failure = errors.New("Error: Some scripts in " + handle.Dir + " failed")
+				}
+			}
+			continue
+		}
+		if !dryRun {
+			if err := handle.RunScriptsWithPrefix(prefix); err != nil {
+				failure = err
+			}
+			continue
+		}
+		broken, err := handle.CheckScripts()
+		if err != nil {
+			fmt.Println(aurora.Yellow("Warning:"), err)
+			continue
+		}
+		var scripts []string
+		for script := range broken {
+			scripts = append(scripts, script)
+		}
+		sort.Strings(scripts)
+		for _, script := range scripts {
+			fmt.Println(aurora.Red("Syntax error:"), filepath.Join(handle.Dir, script))
+			fmt.Println(broken[script])
+		}
+	}
+	return failure
+}
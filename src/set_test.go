@@ -0,0 +1,942 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/setup"
+	"github.com/raphgl/tuckr/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSetLinksIntoEveryTarget(t *testing.T) {
+	e := newTestEnv(t)
+	first := filepath.Join(e.dir, "first")
+	second := filepath.Join(e.dir, "second")
+	e.write(t, "Configs/app/app.conf", "")
+	e.write(t, "Configs/app/.tuckr.json", `{"targets": ["`+first+`", "`+second+`"]}`)
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"app"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	source := filepath.Join(e.store, "Configs", "app", "app.conf")
+	assertLinked(t, filepath.Join(first, "app.conf"), source)
+	assertLinked(t, filepath.Join(second, "app.conf"), source)
+	assertMissing(t, e.inHome("app.conf"))
+}
+
+func TestSetKeepsGoingWhenOneTargetFails(t *testing.T) {
+	e := newTestEnv(t)
+	broken := writeTestFile(t, filepath.Join(e.dir, "broken"), "a file, not a directory")
+	working := filepath.Join(e.dir, "working")
+	e.write(t, "Configs/app/app.conf", "")
+	e.write(t, "Configs/app/.tuckr.json", `{"targets": ["`+broken+`", "`+working+`"]}`)
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"app"}, setOptions{}); err == nil {
+			t.Error("set succeeded although a target is a file")
+		}
+	})
+	assertLinked(t, filepath.Join(working, "app.conf"), filepath.Join(e.store, "Configs", "app", "app.conf"))
+}
+
+func TestIncrementalSetOnlyDeploysChangedFiles(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/dots/.vimrc", "set nu")
+	e.write(t, "Configs/dots/.zshrc", "")
+	if err := setGroups(e.store, e.home, []string{"dots"}, setOptions{quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	e.write(t, "Configs/dots/.vimrc", "set nu rnu")
+	// With the links gone only the files that get processed show up again
+	for _, name := range []string{".vimrc", ".zshrc"} {
+		if err := os.Remove(e.inHome(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := setGroups(e.store, e.home, []string{"dots"}, setOptions{quiet: true, incremental: true}); err != nil {
+		t.Fatal(err)
+	}
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertMissing(t, e.inHome(".zshrc"))
+
+	// Once recorded the changed file isn't processed again either
+	os.Remove(e.inHome(".vimrc"))
+	if err := setGroups(e.store, e.home, []string{"dots"}, setOptions{quiet: true, incremental: true}); err != nil {
+		t.Fatal(err)
+	}
+	assertMissing(t, e.inHome(".vimrc"))
+}
+
+func TestConfineHomeRejectsTargetsOutsideOfHome(t *testing.T) {
+	e := newTestEnv(t)
+	etc := filepath.Join(e.dir, "etc")
+	e.write(t, "Configs/system/hosts", "")
+	e.write(t, "Configs/system/.tuckr.json", `{"targets": ["`+etc+`"]}`)
+	e.write(t, "Configs/escape/.config/app.conf", "")
+	e.write(t, "Configs/home/.vimrc", "")
+	// A symlink inside of home that leads out of it doesn't make a target part of home
+	if err := os.Mkdir(etc, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(etc, e.inHome(".config")); err != nil {
+		t.Fatal(err)
+	}
+	opts := setOptions{quiet: true, confineHome: true}
+	var err error
+	captureOutput(t, func() {
+		for _, group := range []string{"system", "escape"} {
+			if err = setGroups(e.store, e.home, []string{group}, opts); err == nil {
+				t.Errorf("%s was set outside of home", group)
+			}
+		}
+		err = setGroups(e.store, e.home, []string{"home"}, opts)
+	})
+	if err != nil {
+		t.Errorf("a group inside of home wasn't set: %v", err)
+	}
+	assertMissing(t, filepath.Join(etc, "hosts"))
+	assertMissing(t, filepath.Join(etc, "app.conf"))
+	assertLinked(t, e.inHome(".vimrc"), filepath.Join(e.store, "Configs", "home", ".vimrc"))
+}
+
+func TestFolderTypesLandInTheirRoots(t *testing.T) {
+	e := newTestEnv(t)
+	services := filepath.Join(e.dir, "services")
+	e.config(t, "[TARGETS]\nservices = "+services+"\n")
+	vimrc := e.write(t, "Configs/tools/.vimrc", "")
+	script := e.write(t, "Bin/tools/backup", "#!/bin/sh\n")
+	unit := e.write(t, "Services/sync/sync.service", "")
+	// The metadata of a group that's only in Bin is read from there and never linked
+	e.write(t, "Bin/scripts/README", "Handy scripts")
+	e.write(t, "Bin/scripts/.tuckr.json", `{"links": {"run.sh": "run"}}`)
+	run := e.write(t, "Bin/scripts/run.sh", "")
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"tools", "sync", "scripts"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	bin := e.inHome(".local/bin")
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertLinked(t, filepath.Join(bin, "backup"), script)
+	assertLinked(t, filepath.Join(services, "sync.service"), unit)
+	assertLinked(t, filepath.Join(bin, "run"), run)
+	assertMissing(t, filepath.Join(bin, "README"))
+	assertMissing(t, filepath.Join(bin, ".tuckr.json"))
+	for _, path := range []string{script, run} {
+		if info, err := os.Stat(path); err != nil || info.Mode()&0111 == 0 {
+			t.Errorf("%s from Bin wasn't made executable", path)
+		}
+	}
+	if info, err := os.Stat(unit); err != nil || info.Mode()&0111 != 0 {
+		t.Errorf("%s from Services was made executable", unit)
+	}
+	if got := store.Description(e.store, "scripts"); got != "Handy scripts" {
+		t.Errorf("the Bin only group is described as %q", got)
+	}
+}
+
+func TestSetAndUnsetAppendToTheHistory(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/vim/.exrc", "")
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"vim"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := unsetGroups(e.store, e.home, []string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	data, err := ioutil.ReadFile(filepath.Join(e.store, store.HistoryName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ops []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry store.HistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("%q isn't json: %v", line, err)
+		}
+		if !reflect.DeepEqual(entry.Groups, []string{"vim"}) || entry.Links != 2 {
+			t.Errorf("%s recorded %+v", entry.Operation, entry)
+		}
+		ops = append(ops, entry.Operation)
+	}
+	if want := []string{"set", "unset"}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("history recorded %v, want %v", ops, want)
+	}
+}
+
+func TestEnableWhenTogglesTheGroup(t *testing.T) {
+	e := newTestEnv(t)
+	gitconfig := e.write(t, "Configs/work/.gitconfig", "")
+	e.write(t, "Configs/work/.tuckr.json", `{"enableWhen": "$TUCKR_TEST_WORK_MACHINE"}`)
+	defer os.Unsetenv("TUCKR_TEST_WORK_MACHINE")
+	for _, enabled := range []bool{false, true} {
+		if enabled {
+			os.Setenv("TUCKR_TEST_WORK_MACHINE", "1")
+		}
+		out := captureOutput(t, func() {
+			if err := setGroups(e.store, e.home, []string{"work"}, setOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if enabled {
+			assertLinked(t, e.inHome(".gitconfig"), gitconfig)
+		} else {
+			assertMissing(t, e.inHome(".gitconfig"))
+			if !strings.Contains(out, "work is not enabled") {
+				t.Errorf("set didn't say why work was skipped:\n%s", out)
+			}
+		}
+	}
+}
+
+func TestReadOnlyStoreLinksFromTheCache(t *testing.T) {
+	e := newTestEnv(t)
+	e.config(t, "[GENERAL]\nread_only_store = true\n")
+	e.write(t, "Configs/vim/.vimrc", "set nu")
+	e.write(t, "Configs/vim/.config/nvim/init.vim", "source ~/.vimrc")
+	snapshot := func() []string {
+		var paths []string
+		filepath.Walk(e.store, func(path string, info os.FileInfo, err error) error {
+			paths = append(paths, path+" "+info.Mode().String())
+			return err
+		})
+		return paths
+	}
+	filepath.Walk(e.store, func(path string, info os.FileInfo, err error) error {
+		return os.Chmod(path, info.Mode()&^0222)
+	})
+	defer filepath.Walk(e.store, func(path string, info os.FileInfo, err error) error {
+		return os.Chmod(path, info.Mode()|0200)
+	})
+	before := snapshot()
+
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"vim"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	cache := filepath.Join(e.dir, "cache", "tuckr")
+	for rel, contents := range map[string]string{".vimrc": "set nu", ".config/nvim/init.vim": "source ~/.vimrc"} {
+		dest, err := os.Readlink(e.inHome(rel))
+		if err != nil || !strings.HasPrefix(dest, cache+string(filepath.Separator)) {
+			t.Errorf("%s links to %q %v, want it in the cache", rel, dest, err)
+			continue
+		}
+		if data, err := ioutil.ReadFile(e.inHome(rel)); err != nil || string(data) != contents {
+			t.Errorf("%s holds %q %v, want %q", rel, data, err, contents)
+		}
+	}
+	if after := snapshot(); !reflect.DeepEqual(after, before) {
+		t.Errorf("set wrote into the read-only store\nbefore %v\nafter  %v", before, after)
+	}
+	if _, err := os.Stat(filepath.Join(e.dir, "state", store.HistoryName)); err != nil {
+		t.Errorf("the history wasn't kept in the state dir: %v", err)
+	}
+}
+
+func TestActionsRunOnlyForTheirFileOnceLinked(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/ssh/.ssh/config", "")
+	e.write(t, "Configs/ssh/.ssh/known_hosts", "")
+	e.write(t, "Configs/ssh/.tuckr.json", `{"actions": {".ssh/config": ["chmod 600 {}", "notify 'ssh config' {}"]}}`)
+	conf, err := store.LoadGroupConfig(e.store, "ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployments, err := groupDeployments(e.store, "ssh", e.home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := deployments[0]
+	links, err := groupLinks(e.store, "ssh", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &stubRunner{}
+	if err := runActions(e.store, "", d, conf.Actions, links, runner); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("actions ran before their file was linked: %v", runner.calls)
+	}
+	if err := manage.CreateLinks(links); err != nil {
+		t.Fatal(err)
+	}
+	captureOutput(t, func() {
+		if err := runActions(e.store, "", d, conf.Actions, links, runner); err != nil {
+			t.Fatal(err)
+		}
+	})
+	target := e.inHome(".ssh/config")
+	if want := []string{"chmod 600 " + target, "notify ssh config " + target}; !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("ran %q, want %q", runner.calls, want)
+	}
+}
+
+func TestSetNewOnlyDeploysGroupsAddedSinceTheLastSet(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"*"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	os.Remove(e.inHome(".vimrc"))
+	zshrc := e.write(t, "Configs/zsh/.zshrc", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"--new", "*"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".zshrc"), zshrc)
+	assertMissing(t, e.inHome(".vimrc"))
+
+	out := captureOutput(t, func() {
+		if err := runSet([]string{"--new", "*"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, "No new groups to set") {
+		t.Errorf("a second set --new found new groups:\n%s", out)
+	}
+}
+
+func TestNonInteractiveSetFallsBackToTheConflictPolicy(t *testing.T) {
+	e := newTestEnv(t)
+	e.config(t, "[GENERAL]\nconflict_policy = overwrite\n")
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	writeTestFile(t, e.inHome(".vimrc"), "mine")
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"vim"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+}
+
+func TestSetSinceOnlyDeploysTheGroupsChangedSinceTheRef(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	e.write(t, "Hooks/zsh/set_plugins.sh", "")
+	tool := e.write(t, "Bin/tools/backup", "")
+	e.write(t, "Configs/git/.gitconfig", "")
+	runner := &stubRunner{output: func(args ...string) ([]byte, error) {
+		if want := "git -C " + e.store + " diff --name-only -z v1..HEAD"; strings.Join(args, " ") != want {
+			return nil, errors.New("unexpected command " + strings.Join(args, " "))
+		}
+		changed := []string{"Configs/vim/.vimrc", "Hooks/zsh/set_plugins.sh", "Bin/tools/backup", "README.md", "Configs/removed/.bashrc"}
+		return []byte(strings.Join(changed, "\x00") + "\x00"), nil
+	}}
+	var groups []string
+	var err error
+	captureOutput(t, func() {
+		groups, err = sinceRef(e.store, []string{"git", "tools", "vim"}, "v1", runner)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// zsh changed but isn't one of the groups asked for
+	if want := []string{"tools", "vim"}; !reflect.DeepEqual(groups, want) {
+		t.Errorf("set --since v1 picked %v, want %v", groups, want)
+	}
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, groups, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertLinked(t, e.inHome(".local/bin/backup"), tool)
+	assertMissing(t, e.inHome(".gitconfig"))
+	assertMissing(t, e.inHome(".zshrc"))
+}
+
+func TestSetHandlesSpacesQuotesAndUnicode(t *testing.T) {
+	e := newTestEnv(t)
+	names := []string{"my file.conf", `it's "quoted".conf`, "ünïcödé/ファイル.conf", " leading space"}
+	for _, name := range names {
+		e.write(t, "Configs/odd/"+name, name)
+	}
+	ran := filepath.Join(e.dir, "ran file")
+	e.write(t, "Hooks/odd/set_with space.sh", "printf ok > '"+ran+"'\n")
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"odd"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, name := range names {
+		assertLinked(t, e.inHome(name), filepath.Join(e.store, "Configs", "odd", filepath.FromSlash(name)))
+	}
+	if data, err := ioutil.ReadFile(ran); err != nil || string(data) != "ok" {
+		t.Errorf("the script with a space in its name didn't run: %q %v", data, err)
+	}
+}
+
+func TestSetOnlyRunsTheScriptsForThisPlatform(t *testing.T) {
+	e := newTestEnv(t)
+	other := "windows"
+	if runtime.GOOS == other {
+		other = "linux"
+	}
+	log := filepath.Join(e.dir, "ran")
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Hooks/vim/set_shared.sh", "echo shared >> "+log+"\n")
+	e.write(t, "Hooks/vim/"+runtime.GOOS+"/set_native.sh", "echo native >> "+log+"\n")
+	e.write(t, "Hooks/vim/"+other+"/set_foreign.sh", "echo foreign >> "+log+"\n")
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"vim"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	ran, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "shared\nnative\n"; string(ran) != want {
+		t.Errorf("set ran %q, want the shared scripts and then the %s ones %q", ran, runtime.GOOS, want)
+	}
+}
+
+func TestConfirmEachOnlyAppliesWhatWasApproved(t *testing.T) {
+	e := newTestEnv(t)
+	log := filepath.Join(e.dir, "ran")
+	for _, name := range []string{".a", ".b", ".c"} {
+		e.write(t, "Configs/vim/"+name, "")
+	}
+	e.write(t, "Hooks/vim/set_1.sh", "echo 1 >> "+log+"\n")
+	e.write(t, "Hooks/vim/set_2.sh", "echo 2 >> "+log+"\n")
+	// The links are asked about in order and then the scripts
+	answers := bufio.NewReader(strings.NewReader("y\nn\nyes\nn\ny\n"))
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"vim"}, setOptions{confirmIn: answers}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".a"), filepath.Join(e.store, "Configs", "vim", ".a"))
+	assertMissing(t, e.inHome(".b"))
+	assertLinked(t, e.inHome(".c"), filepath.Join(e.store, "Configs", "vim", ".c"))
+	if ran, err := ioutil.ReadFile(log); err != nil || string(ran) != "2\n" {
+		t.Errorf("ran %q (%v), want only the approved set_2.sh", ran, err)
+	}
+}
+
+func TestSetFromAURLDeploysAClonedStore(t *testing.T) {
+	e := newTestEnv(t)
+	url := "https://example.com/dotfiles.git"
+	runner := &stubRunner{run: func(name string, args ...string) error {
+		// The clone lands in the directory it's given last
+		dest := args[len(args)-1]
+		writeTestFile(t, filepath.Join(dest, "Configs", "vim", ".vimrc"), "set nu")
+		writeTestFile(t, filepath.Join(dest, "Hooks", "vim", "set_plugins.sh"), "touch "+filepath.Join(e.dir, "ran")+"\n")
+		return nil
+	}}
+	var root string
+	captureOutput(t, func() {
+		var err error
+		if root, err = cloneTemporaryStore(url, runner); err != nil {
+			t.Fatal(err)
+		}
+		groups, err := selectGroups(root, []string{"*"}, store.GroupFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := setGroups(root, e.home, groups, setOptions{copyStore: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := []string{"git clone " + url + " " + root}; !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("ran %v, want %v", runner.calls, want)
+	}
+	// set --from deletes the store once it's done so the links have to outlive it
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(e.inHome(".vimrc")); err != nil || string(data) != "set nu" {
+		t.Errorf(".vimrc reads %q (%v) once the store is gone, want the cloned file", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(e.dir, "ran")); err != nil {
+		t.Errorf("the cloned store's set_ script didn't run: %v", err)
+	}
+}
+
+func TestDotPrefixLinksFilesWithADot(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/vimrc", "")
+	zshrc := e.write(t, "Configs/zsh/zshrc", "")
+	e.write(t, "Configs/vim/.tuckr.json", `{"dotPrefix": true}`)
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"vim", "zsh"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertMissing(t, e.inHome("vimrc"))
+	assertLinked(t, e.inHome("zshrc"), zshrc)
+	assertMissing(t, e.inHome(".zshrc"))
+}
+
+func TestQuietSetPrintsNothingWhenEverythingIsLinked(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/vim/.config/nvim/init.vim", "")
+	e.write(t, "Bin/vim/vimdiff-all", "")
+	e.write(t, "Hooks/vim/set_plugins.sh", "echo installing plugins\n")
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	out := captureOutput(t, func() {
+		if err := runSet([]string{"--quiet", "*"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if out != "" {
+		t.Errorf("a quiet set with nothing to do printed %q", out)
+	}
+
+	// A failing script is a problem so what it printed is shown
+	e.write(t, "Hooks/vim/set_update.sh", "echo fetching updates\nexit 2\n")
+	out = captureOutput(t, func() {
+		if err := runSet([]string{"--quiet", "vim"}); err == nil {
+			t.Error("a set with a failing script succeeded")
+		}
+	})
+	if !strings.Contains(out, "fetching updates") || !strings.Contains(out, "exited with status 2") || strings.Contains(out, "installing plugins") {
+		t.Errorf("a quiet set with a failing script printed %q, want only the failing script's output", out)
+	}
+}
+
+func TestSetLinksIntoTheRealDirectoryOfASymlinkedParent(t *testing.T) {
+	e := newTestEnv(t)
+	real := filepath.Join(e.dir, "synced", "config")
+	if err := os.MkdirAll(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(real, e.inHome(".config")); err != nil {
+		t.Fatal(err)
+	}
+	initVim := e.write(t, "Configs/vim/.config/nvim/init.vim", "")
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"vim"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, filepath.Join(real, "nvim", "init.vim"), initVim)
+	if info, err := os.Lstat(e.inHome(".config")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("~/.config was replaced instead of linked into: %v", err)
+	}
+
+	// A parent that links into the store would have the link written into the store
+	e.write(t, "Configs/zsh/.zsh/prompt.zsh", "")
+	if err := os.Symlink(filepath.Join(e.store, "Configs", "zsh", ".zsh"), e.inHome(".zsh")); err != nil {
+		t.Fatal(err)
+	}
+	out := captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"zsh"}, setOptions{}); err == nil {
+			t.Error("linking through a parent that's a symlink into the store succeeded")
+		}
+	})
+	if !strings.Contains(out, "is inside of the store") {
+		t.Errorf("set printed %q, want the target reported as inside of the store", out)
+	}
+}
+
+func TestUnsetFilesLeavesTheRestOfTheGroupLinked(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/vim/.config/nvim/init.vim", "")
+	e.write(t, "Configs/vim/.config/nvim/lua/plugins.lua", "")
+	gvimrc := e.write(t, "Configs/vim/.gvimrc", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := runUnset([]string{"vim", ".config/nvim", ".gvimrc"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertMissing(t, e.inHome(".gvimrc"))
+	assertMissing(t, e.inHome(".config/nvim/init.vim"))
+	assertMissing(t, e.inHome(".config/nvim/lua/plugins.lua"))
+
+	for _, file := range []string{".bashrc", ".vim", "../zsh/.zshrc"} {
+		if err := unsetFiles(e.store, e.home, "vim", []string{file}); err == nil || !strings.Contains(err.Error(), "is not a file of vim") {
+			t.Errorf("unsetting %s failed with %v, want it rejected", file, err)
+		}
+	}
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".gvimrc"), gvimrc)
+}
+
+func TestSetMakesDeclaredExecutablesRunnable(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/tools/.local/share/tools/build.sh", "")
+	e.write(t, "Configs/tools/.local/share/tools/notes.txt", "")
+	e.write(t, "Configs/tools/.tuckr.json", `{"executable": [".local/share/tools/build.sh"]}`)
+	e.write(t, "Bin/tools/deploy", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"tools"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for rel, want := range map[string]bool{
+		".local/share/tools/build.sh":  true,
+		".local/bin/deploy":            true,
+		".local/share/tools/notes.txt": false,
+	} {
+		// Stat follows the link so this is the mode of the store file it runs
+		info, err := os.Stat(e.inHome(rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode()&0111 == 0111; got != want {
+			t.Errorf("~/%s is linked with mode %v, want executable %v", rel, info.Mode(), want)
+		}
+	}
+}
+
+func TestExplainSaysWhyEachFileIsSkipped(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/vim/.exrc", "")
+	e.write(t, "Configs/vim/.vimrc.swp", "")
+	e.write(t, "Configs/vim/"+store.IgnoreName, "*.swp\n")
+	e.write(t, "Configs/plan9/.profile", "")
+	e.write(t, "Configs/plan9/.tuckr.json", `{"platforms": ["plan9"]}`)
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err := os.Remove(e.inHome(".exrc")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, e.inHome(".exrc"), "mine")
+	out := uncolored(captureOutput(t, func() {
+		if err := runSet([]string{"--explain", "--dry-run", "*"}); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	for _, want := range []string{
+		"Skipping: plan9 is not meant for " + runtime.GOOS,
+		"Skipping: " + e.inHome(".vimrc.swp") + ` is ignored by the pattern "*.swp" in vim's ` + store.IgnoreName,
+		"Skipping: " + e.inHome(".vimrc") + " is already linked",
+		"Skipping: " + e.inHome(".exrc") + " is taken by a file that isn't a link to the store",
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("set --explain didn't print %q in\n%s", want, out)
+		}
+	}
+
+	out = uncolored(captureOutput(t, func() {
+		if err := runStatus([]string{"--explain"}); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	if want := "Skipped: " + e.inHome(".vimrc.swp") + ` is ignored by the pattern "*.swp"`; !strings.Contains(out, want) {
+		t.Errorf("status --explain didn't print %q in\n%s", want, out)
+	}
+}
+
+func TestEachStoreFormatDeploysIntoItsTargets(t *testing.T) {
+	tests := []struct {
+		format string
+		files  []string
+		// Where the files get linked to relative to the directory holding the store
+		want map[string]string
+	}{
+		{store.FormatTuckr, []string{"Configs/vim/.vimrc", "Bin/vim/vimdiff-all"}, map[string]string{
+			"home/.vimrc":                 "Configs/vim/.vimrc",
+			"home/.local/bin/vimdiff-all": "Bin/vim/vimdiff-all",
+		}},
+		{store.FormatFlat, []string{"vim/.vimrc", "vim/.config/nvim/init.vim", "Hooks/vim/set_x.sh"}, map[string]string{
+			"home/.vimrc":                "vim/.vimrc",
+			"home/.config/nvim/init.vim": "vim/.config/nvim/init.vim",
+		}},
+		{store.FormatStow, []string{"vim/.vimrc", "vim/.config/nvim/init.vim"}, map[string]string{
+			".vimrc":                "vim/.vimrc",
+			".config/nvim/init.vim": "vim/.config/nvim/init.vim",
+		}},
+	}
+	for _, test := range tests {
+		e := newTestEnv(t)
+		format := store.Format
+		store.Format = test.format
+		for _, file := range test.files {
+			e.write(t, file, "")
+		}
+		// Hooks isn't a group of a flat store
+		groups, err := store.Groups(e.store)
+		if err == nil && !reflect.DeepEqual(groups, []string{"vim"}) {
+			t.Errorf("the %s store has the groups %v, want only vim", test.format, groups)
+		}
+		if err == nil {
+			err = setGroups(e.store, e.home, []string{"vim"}, setOptions{quiet: true})
+		}
+		store.Format = format
+		if err != nil {
+			t.Fatalf("setting a %s store failed: %v", test.format, err)
+		}
+		parent := filepath.Dir(e.store)
+		for target, source := range test.want {
+			assertLinked(t, filepath.Join(parent, target), filepath.Join(e.store, source))
+		}
+	}
+}
+
+func TestSetReportsATargetThatIsAFile(t *testing.T) {
+	e := newTestEnv(t)
+	srv := writeTestFile(t, filepath.Join(e.dir, "srv"), "not a directory")
+	e.write(t, "Configs/web/site.conf", "")
+	e.write(t, "Configs/web/.tuckr.json", `{"targets": ["`+srv+`"]}`)
+	var err error
+	out := captureOutput(t, func() {
+		err = setGroups(e.store, e.home, []string{"web"}, setOptions{})
+	})
+	if err == nil || !strings.Contains(out, "The destination "+srv+" is a file") {
+		t.Errorf("setting into a file failed with %v and printed %q, want the file named", err, out)
+	}
+	if data, _ := ioutil.ReadFile(srv); string(data) != "not a directory" {
+		t.Errorf("the file the group targets now holds %q", data)
+	}
+}
+
+func TestDumpEnvPrintsTheScriptsEnvironmentRedacted(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Hooks/vim/.env", "GREETING=hello\nGITHUB_TOKEN=ghp_shh\n")
+	e.write(t, "Hooks/vim/set_plugins.sh", "true\n")
+	os.Setenv("TUCKR_TEST_DB_PASSWORD", "hunter2")
+	defer os.Unsetenv("TUCKR_TEST_DB_PASSWORD")
+	out := uncolored(captureOutput(t, func() {
+		if err := runSet([]string{"vim", "--dump-env"}); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	for _, want := range []string{
+		"  GREETING=hello (from " + setup.EnvName + ")\n",
+		"  GITHUB_TOKEN=<redacted> (from " + setup.EnvName + ")\n",
+		"  TUCKR_TEST_DB_PASSWORD=<redacted>\n",
+		"  HOME=" + e.home + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("--dump-env didn't print %q in\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{"ghp_shh", "hunter2"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("--dump-env printed the secret %q", secret)
+		}
+	}
+	// Without scripts to run there's no environment to print
+	e.write(t, "Configs/zsh/.zshrc", "")
+	out = captureOutput(t, func() {
+		if err := runSet([]string{"zsh", "--dump-env"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(out, "HOME=") {
+		t.Errorf("--dump-env without scripts to run printed\n%s", out)
+	}
+}
+
+func TestAFullManifestDescribesTheWholeGroup(t *testing.T) {
+	e := newTestEnv(t)
+	ran := filepath.Join(e.dir, "ran")
+	srv := filepath.Join(e.dir, "srv")
+	vimrc := e.write(t, "Configs/vim/vimrc", "")
+	e.write(t, "Configs/vim/.vimrc.swp", "")
+	plugins := e.write(t, "Configs/vim/.vim/plugins/surround.vim", "")
+	tool := e.write(t, "Configs/vim/bin/vim-all", "")
+	e.write(t, "Configs/vim/scripts/set_plugins.sh", "touch "+ran+"\n")
+	// The separate files are only fallbacks for what the manifest leaves empty
+	e.write(t, "Configs/vim/"+store.IgnoreName, "vimrc\n")
+	e.write(t, "Configs/vim/links.map", "vimrc .exrc\n")
+	e.write(t, "Configs/vim/.tuckr.json", `{
+		"targets": ["`+e.home+`", "`+srv+`"],
+		"platforms": ["`+runtime.GOOS+`"],
+		"linkAsDirectory": [".vim/plugins"],
+		"executable": ["bin/vim-all"],
+		"ignore": ["*.swp"],
+		"links": {"vimrc": ".vimrc"},
+		"hooks": "scripts",
+		"tags": ["editor"]
+	}`)
+	e.write(t, "Configs/zsh/zshrc", "")
+	e.write(t, "Configs/zsh/zshrc.bak", "")
+	e.write(t, "Configs/zsh/"+store.IgnoreName, "*.bak\n")
+	e.write(t, "Configs/zsh/links.map", "zshrc .zshrc\n")
+	captureOutput(t, func() {
+		if err := runSet([]string{"--tag", "editor", "zsh"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, target := range []string{e.home, srv} {
+		assertLinked(t, filepath.Join(target, ".vimrc"), vimrc)
+		assertLinked(t, filepath.Join(target, ".vim", "plugins"), filepath.Dir(plugins))
+		assertLinked(t, filepath.Join(target, "bin", "vim-all"), tool)
+		for _, rel := range []string{".vimrc.swp", "vimrc", ".exrc", "scripts", store.IgnoreName, "links.map", ".tuckr.json"} {
+			assertMissing(t, filepath.Join(target, rel))
+		}
+	}
+	if info, err := os.Stat(tool); err != nil || info.Mode()&0111 == 0 {
+		t.Errorf("%s wasn't made executable: %v", tool, err)
+	}
+	if _, err := os.Stat(ran); err != nil {
+		t.Errorf("the set_ script in the manifest's hooks didn't run: %v", err)
+	}
+	assertLinked(t, e.inHome(".zshrc"), filepath.Join(e.store, "Configs", "zsh", "zshrc"))
+	assertMissing(t, e.inHome("zshrc.bak"))
+	assertMissing(t, e.inHome("zshrc"))
+}
+
+func TestMaxDepthLeavesDeeperFilesUnlinked(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	shallow := e.write(t, "Configs/vim/.config/nvim.conf", "")
+	e.write(t, "Configs/vim/.config/nvim/init.vim", "")
+	e.write(t, "Configs/vim/.config/nvim/lua/plugins.lua", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"--max-depth", "2", "vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertLinked(t, e.inHome(".config/nvim.conf"), shallow)
+	assertMissing(t, e.inHome(".config/nvim"))
+
+	if err := runSet([]string{"--max-depth", "-1", "vim"}); err == nil {
+		t.Error("a negative --max-depth was accepted")
+	}
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".config/nvim/lua/plugins.lua"), filepath.Join(e.store, "Configs", "vim", ".config", "nvim", "lua", "plugins.lua"))
+}
+
+func TestDotfilesOnlyLeavesTheOtherEntriesUnlinked(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	init := e.write(t, "Configs/vim/.config/nvim/init.vim", "")
+	e.write(t, "Configs/vim/README.md", "")
+	e.write(t, "Configs/vim/assets/.logo.png", "")
+	tool := e.write(t, "Bin/vim/vimdiff-all", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"--dotfiles-only", "vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertLinked(t, e.inHome(".config/nvim/init.vim"), init)
+	// Bin's tools land in .local/bin so they count as dotfiles too
+	assertLinked(t, e.inHome(".local/bin/vimdiff-all"), tool)
+	assertMissing(t, e.inHome("README.md"))
+	assertMissing(t, e.inHome("assets"))
+
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome("README.md"), filepath.Join(e.store, "Configs", "vim", "README.md"))
+	assertLinked(t, e.inHome("assets/.logo.png"), filepath.Join(e.store, "Configs", "vim", "assets", ".logo.png"))
+}
+
+func TestSetTagSelectsExactlyTheTaggedGroups(t *testing.T) {
+	e := newTestEnv(t)
+	for group, tags := range map[string]string{"vim": `["cli", "editor"]`, "git": `["cli"]`, "firefox": `["gui"]`, "tmux": `["cli"]`, "zsh": `[]`} {
+		e.write(t, "Configs/"+group+"/."+group+"rc", "")
+		e.write(t, "Configs/"+group+"/.tuckr.json", `{"tags": `+tags+`}`)
+	}
+	e.write(t, "Configs/paint/.paintrc", "")
+	e.write(t, "Configs/paint/.tuckr.json", `{"tags": ["cli"], "platforms": ["plan9"]}`)
+	captureOutput(t, func() {
+		if err := runDisable([]string{"tmux"}, true); err != nil {
+			t.Fatal(err)
+		}
+	})
+	linked := func() []string {
+		var groups []string
+		for _, group := range []string{"firefox", "git", "paint", "tmux", "vim", "zsh"} {
+			if _, err := os.Lstat(e.inHome("." + group + "rc")); err == nil {
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	}
+
+	captureOutput(t, func() {
+		if err := runSet([]string{"--tag", "cli"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if got := linked(); !reflect.DeepEqual(got, []string{"git", "vim"}) {
+		t.Errorf("--tag cli set %v, want [git vim]", got)
+	}
+	captureOutput(t, func() {
+		if err := runSet([]string{"--tag", "gui,editor", "z*"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if got := linked(); !reflect.DeepEqual(got, []string{"firefox", "git", "vim", "zsh"}) {
+		t.Errorf("--tag gui,editor z* left %v set, want firefox and zsh added", got)
+	}
+	if err := runSet([]string{"--tag", "server"}); err == nil {
+		t.Error("a tag no group carries was accepted")
+	}
+}
+
+func TestNoParentsRefusesToCreateMissingDirectories(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	init := e.write(t, "Configs/vim/.config/nvim/init.vim", "")
+	out := uncolored(captureOutput(t, func() {
+		if err := runSet([]string{"--no-parents", "vim"}); err == nil {
+			t.Error("setting into a missing directory with --no-parents succeeded")
+		}
+	}))
+	if !strings.Contains(out, e.inHome(".config/nvim")+" doesn't exist") {
+		t.Errorf("set didn't name the missing directory:\n%s", out)
+	}
+	// The group fails as a whole before anything is linked
+	assertMissing(t, e.inHome(".vimrc"))
+	assertMissing(t, e.inHome(".config"))
+
+	if err := os.MkdirAll(e.inHome(".config/nvim"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	captureOutput(t, func() {
+		if err := runSet([]string{"--no-parents", "vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertLinked(t, e.inHome(".config/nvim/init.vim"), init)
+
+	e.write(t, "Configs/zsh/.config/zsh/.zshrc", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"zsh"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".config/zsh/.zshrc"), filepath.Join(e.store, "Configs", "zsh", ".config", "zsh", ".zshrc"))
+}
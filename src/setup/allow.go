@@ -0,0 +1,51 @@
+package setup
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+/* Creates a directory holding links to only the commands, found through $PATH, for
+scripts to get as their $PATH so they can't run anything else by its name. Scripts
+can still run commands through their absolute paths so it's a best-effort restriction
+The commands that aren't installed are returned, removing the directory is up to the caller */
+func RestrictedPath(commands []string) (string, []string, error) {
+	found, missing, err := CommandPaths(commands)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := ioutil.TempDir("", "tuckr-path-")
+	if err != nil {
+		return "", nil, err
+	}
+	for name, path := range found {
+		if err := os.Symlink(path, filepath.Join(dir, name)); err != nil {
+			os.RemoveAll(dir)
+			return "", nil, err
+		}
+	}
+	return dir, missing, nil
+}
+
+/* Returns the absolute paths $PATH finds the commands at keyed by the names they
+get in a RestrictedPath, along with the commands that aren't installed */
+func CommandPaths(commands []string) (map[string]string, []string, error) {
+	found := map[string]string{}
+	var missing []string
+	for _, name := range commands {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			missing = append(missing, name)
+			continue
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			return nil, nil, err
+		}
+		if _, ok := found[filepath.Base(name)]; !ok {
+			found[filepath.Base(name)] = path
+		}
+	}
+	return found, missing, nil
+}
@@ -0,0 +1,34 @@
+package setup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRestrictedPathOnlyHoldsTheCommands(t *testing.T) {
+	dir, missing, err := RestrictedPath([]string{"sh", "/bin/cat", "tuckr-not-a-command"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if !reflect.DeepEqual(missing, []string{"tuckr-not-a-command"}) {
+		t.Errorf("the missing commands are %v", missing)
+	}
+	var names []string
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"cat", "sh"}) {
+		t.Errorf("the path holds %v, want [cat sh]", names)
+	}
+	if dest, err := os.Readlink(filepath.Join(dir, "cat")); err != nil || dest != "/bin/cat" {
+		t.Errorf("cat links to %q %v", dest, err)
+	}
+}
@@ -0,0 +1,47 @@
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"os"
+	"path/filepath"
+)
+
+/* Returns the command that clones the dotfiles repo into the destination set in the config
+The clone command is split into words like a shell would and gets the repo and destination appended */
+func CloneCommand(general config.General) ([]string, error) {
+	if general.DotfilesRepo == "" {
+		return nil, errors.New("Error: No dotfiles_repo set in config")
+	}
+	if general.DotfilesDest == "" {
+		return nil, errors.New("Error: No dotfiles_dest set in config")
+	}
+	cmd, err := SplitCommand(general.CloneDotfilesCmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(cmd) == 0 {
+		cmd = []string{"git", "clone"}
+	}
+	return append(cmd, general.DotfilesRepo, general.DotfilesDest), nil
+}
+
+/* Clones the dotfiles repo into the destination set in the config with CloneCommand
+Nothing is cloned when the destination already holds a repo, so cloning again is harmless */
+func CloneFiles(general config.General, runner CommandRunner) error {
+	cmd, err := CloneCommand(general)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(general.DotfilesDest, ".git")); err == nil {
+		fmt.Println(aurora.Yellow("Skipping:"), general.DotfilesDest, "already holds a repo")
+		return nil
+	}
+	fmt.Println(aurora.Green("Cloning:"), general.DotfilesRepo, "into", general.DotfilesDest)
+	if err := runner.Run(cmd[0], cmd[1:]...); err != nil {
+		return errors.New("Error: Could not clone " + general.DotfilesRepo + ": " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package setup
+
+import (
+	"github.com/raphgl/tuckr/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Records the commands it's asked to run and runs them through run
+type fakeRunner struct {
+	calls []string
+	run   func(name string, args ...string) error
+}
+
+func (r *fakeRunner) Run(name string, args ...string) error {
+	r.calls = append(r.calls, strings.Join(append([]string{name}, args...), " "))
+	return r.run(name, args...)
+}
+
+func (r *fakeRunner) Output(name string, args ...string) ([]byte, error) {
+	return nil, r.Run(name, args...)
+}
+
+func TestCloneFilesSkipsADestinationThatHoldsARepo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tuckr-clone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	general := config.General{DotfilesRepo: "https://example.com/dotfiles.git", DotfilesDest: filepath.Join(dir, "dotfiles")}
+	runner := &fakeRunner{run: func(name string, args ...string) error {
+		// like git, cloning into a repo fails
+		if _, err := os.Stat(filepath.Join(general.DotfilesDest, ".git")); err == nil {
+			return os.ErrExist
+		}
+		return os.MkdirAll(filepath.Join(general.DotfilesDest, ".git"), 0755)
+	}}
+	for i := 0; i < 2; i++ {
+		if err := CloneFiles(general, runner); err != nil {
+			t.Fatalf("cloning for the %d. time failed with %v", i+1, err)
+		}
+	}
+	if len(runner.calls) != 1 || runner.calls[0] != "git clone "+general.DotfilesRepo+" "+general.DotfilesDest {
+		t.Errorf("cloning twice ran %v, want a single clone", runner.calls)
+	}
+}
@@ -0,0 +1,86 @@
+package setup
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Name of the file in a group's hooks whose variables are passed to its scripts
+const EnvName = ".env"
+
+/* Parses KEY=VALUE lines into the form used by exec.Cmd's Env
+Blank lines and # comments are skipped, a leading export is allowed and values
+may be wrapped in quotes. Nothing is expanded or run, values are taken literally */
+func ParseEnv(r io.Reader) ([]string, error) {
+	var env []string
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return env, errors.New("Error: Invalid line " + strconv.Itoa(lineNo) + " in " + EnvName + ": " + line)
+		}
+		key := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env = append(env, key+"="+value)
+	}
+	return env, scanner.Err()
+}
+
+// Reads the .env file at path, a missing file means no extra variables
+func loadEnv(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return ParseEnv(f)
+}
+
+/* Returns the environment scripts of the handle run with
+It's tuckr's own environment with the variables of the hooks' .env on top of it
+and the handle's Path as $PATH when it has one */
+func (s SetupHandle) ScriptEnv() []string {
+	env := append(os.Environ(), s.Env...)
+	if s.Path != "" {
+		env = append(env, "PATH="+s.Path)
+	}
+	return env
+}
+
+// Parts of variable names that mark their values as secrets not to be printed
+var sensitiveNames = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "CREDENTIAL", "PRIVATE", "API_KEY", "AUTH"}
+
+/* Replaces the values of the variables in env whose names look like they hold
+secrets, like GITHUB_TOKEN or DB_PASSWORD, with <redacted> */
+func RedactEnv(env []string) []string {
+	var redacted []string
+	for _, kv := range env {
+		i := strings.Index(kv, "=")
+		if i > 0 {
+			name := strings.ToUpper(kv[:i])
+			for _, sensitive := range sensitiveNames {
+				if strings.Contains(name, sensitive) {
+					kv = kv[:i] + "=<redacted>"
+					break
+				}
+			}
+		}
+		redacted = append(redacted, kv)
+	}
+	return redacted
+}
@@ -0,0 +1,54 @@
+package setup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvTakesValuesLiterally(t *testing.T) {
+	env, err := ParseEnv(strings.NewReader(`# shared by the scripts
+EDITOR=vim
+
+export GREETING="hello world"
+QUOTED='$(rm -rf ~)'
+SPACED = a b
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"EDITOR=vim", "GREETING=hello world", "QUOTED=$(rm -rf ~)", "SPACED=a b"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("ParseEnv returned %q, want %q", env, want)
+	}
+	if _, err := ParseEnv(strings.NewReader("EDITOR=vim\nnot a variable\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("an invalid line was reported as %v", err)
+	}
+}
+
+func TestScriptsSeeTheEnvFile(t *testing.T) {
+	setShell(t, "sh")
+	dir, err := ioutil.TempDir("", "tuckr-ran")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "greeting")
+	handle := scriptsDir(t, map[string]string{
+		EnvName:       "TUCKR_TEST_GREETING='hello $USER'\n",
+		"set_echo.sh": `printf %s "$TUCKR_TEST_GREETING" > ` + out + "\n",
+	})
+	handle.Quiet = true
+	if err := handle.RunScripts(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(out); err != nil || string(data) != "hello $USER" {
+		t.Errorf("the script saw %q %v, want the .env's value", data, err)
+	}
+	if _, ok := os.LookupEnv("TUCKR_TEST_GREETING"); ok {
+		t.Error("the .env's variable leaked into tuckr's own environment")
+	}
+}
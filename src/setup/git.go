@@ -0,0 +1,94 @@
+package setup
+
+import (
+	"errors"
+	"strings"
+)
+
+// Returns the commit the store's repo is currently at
+func Head(root string, runner CommandRunner) (string, error) {
+	out, err := runner.Output("git", "-C", root, "rev-parse", "HEAD")
+	if err != nil {
+		return "", errors.New("Error: Could not read the store's current commit: " + err.Error())
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Pulls the latest changes into the store's repo
+func Pull(root string, runner CommandRunner) error {
+	if err := runner.Run("git", "-C", root, "pull"); err != nil {
+		return errors.New("Error: Could not pull the store: " + err.Error())
+	}
+	return nil
+}
+
+// Returns the url of the store repo's origin remote
+func OriginURL(root string, runner CommandRunner) (string, error) {
+	out, err := runner.Output("git", "-C", root, "remote", "get-url", "origin")
+	if err != nil {
+		return "", errors.New("Error: Could not read the url of the store's origin: " + err.Error())
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+/* Returns true if both urls point at the same repo
+The scheme, user, a trailing .git or / and the case of the host are ignored so
+git@host:user/repo and https://host/user/repo.git are the same repo */
+func SameRemote(a string, b string) bool {
+	return normalizeRemote(a) == normalizeRemote(b)
+}
+
+// Reduces a remote url to host/path, see SameRemote
+func normalizeRemote(url string) string {
+	url = strings.TrimSpace(url)
+	scp := true
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+		scp = false
+	}
+	if i := strings.Index(url, "@"); i >= 0 && i < strings.IndexAny(url+"/", ":/") {
+		url = url[i+1:]
+	}
+	if i := strings.Index(url, ":"); scp && i >= 0 && !strings.HasPrefix(url, "/") {
+		url = url[:i] + "/" + url[i+1:]
+	}
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	host, path := url, ""
+	if i := strings.Index(url, "/"); i >= 0 {
+		host, path = url[:i], url[i:]
+	}
+	return strings.ToLower(host) + path
+}
+
+// Fetches the latest changes into the store's repo without merging them
+func Fetch(root string, runner CommandRunner) error {
+	if err := runner.Run("git", "-C", root, "fetch"); err != nil {
+		return errors.New("Error: Could not fetch the store: " + err.Error())
+	}
+	return nil
+}
+
+// Returns the commit of the branch the store's current branch pulls from
+func Upstream(root string, runner CommandRunner) (string, error) {
+	out, err := runner.Output("git", "-C", root, "rev-parse", "@{upstream}")
+	if err != nil {
+		return "", errors.New("Error: Could not read the store's upstream commit: " + err.Error())
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+/* Returns the paths, relative to the store, of the files that differ between two commits
+The paths are read NUL separated so that git doesn't quote names with spaces or unicode */
+func ChangedFiles(root string, from string, to string, runner CommandRunner) ([]string, error) {
+	out, err := runner.Output("git", "-C", root, "diff", "--name-only", "-z", from+".."+to)
+	if err != nil {
+		return nil, errors.New("Error: Could not diff " + from + ".." + to + ": " + err.Error())
+	}
+	var files []string
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
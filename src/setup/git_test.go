@@ -0,0 +1,25 @@
+package setup
+
+import "testing"
+
+func TestSameRemoteIgnoresHowTheURLIsWritten(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"https://github.com/me/dotfiles.git", "https://github.com/me/dotfiles", true},
+		{"git@github.com:me/dotfiles.git", "https://github.com/me/dotfiles", true},
+		{"ssh://git@GitHub.com/me/dotfiles/", "https://github.com/me/dotfiles.git", true},
+		{"https://user@gitlab.com/me/dotfiles", "git@gitlab.com:me/dotfiles", true},
+		{"/srv/git/dotfiles.git", "/srv/git/dotfiles", true},
+		{"https://github.com/me/dotfiles", "https://github.com/someone/dotfiles", false},
+		{"https://github.com/me/dotfiles", "https://gitlab.com/me/dotfiles", false},
+		// the path keeps its case, only hosts don't have one
+		{"https://github.com/Me/dotfiles", "https://github.com/me/dotfiles", false},
+	}
+	for _, test := range tests {
+		if got := SameRemote(test.a, test.b); got != test.want {
+			t.Errorf("SameRemote(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package setup
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestScriptsRunInTheSameOrderWhateverTheFSLists(t *testing.T) {
+	scripts := map[string]string{}
+	for _, name := range []string{"set_c.sh", "set_a.sh", "unset_b.sh", "set_b.sh", "unset_a.sh", ".env"} {
+		scripts[name] = ""
+	}
+	dir := scriptsDir(t, scripts).Dir
+	t.Cleanup(func() { readDir = ioutil.ReadDir })
+	for seed := int64(0); seed < 20; seed++ {
+		shuffle := rand.New(rand.NewSource(seed))
+		readDir = func(dir string) ([]os.FileInfo, error) {
+			files, err := ioutil.ReadDir(dir)
+			shuffle.Shuffle(len(files), func(i, j int) { files[i], files[j] = files[j], files[i] })
+			return files, err
+		}
+		handle, err := NewSetupHandleAt(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := handle.Scripts("set_"); !reflect.DeepEqual(got, []string{"set_a.sh", "set_b.sh", "set_c.sh"}) {
+			t.Errorf("listed in the order of seed %d the set_ scripts are %v", seed, got)
+		}
+		if got := handle.Scripts("unset_"); !reflect.DeepEqual(got, []string{"unset_a.sh", "unset_b.sh"}) {
+			t.Errorf("listed in the order of seed %d the unset_ scripts are %v", seed, got)
+		}
+	}
+}
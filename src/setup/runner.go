@@ -0,0 +1,30 @@
+package setup
+
+import (
+	"os"
+	"os/exec"
+)
+
+/* Runs the external commands tuckr depends on such as git
+It exists so the commands can be swapped out without touching the system */
+type CommandRunner interface {
+	Run(name string, args ...string) error
+	Output(name string, args ...string) ([]byte, error)
+}
+
+// Runs commands for real with their output going to the terminal
+type ExecRunner struct{}
+
+func (ExecRunner) Run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (ExecRunner) Output(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
@@ -1,46 +1,223 @@
 package setup
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/logrusorgru/aurora"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 /* Contains the functions that do all the setting up as well as
-an array with the os.FileInfo for all files in the current dir */
+an array with the os.FileInfo for all files in Dir sorted by name, so scripts
+always run in the same order whatever order the filesystem lists them in
+Env holds the variables from Dir's .env which are only passed to the scripts,
+Path replaces their $PATH when it's set and Quiet keeps it from announcing each
+script it runs and from printing what they write to stdout unless they fail */
 type SetupHandle struct {
+	Dir        string
 	WorkingDir []os.FileInfo
+	Env        []string
+	Path       string
+	Quiet      bool
 }
 
 /* Checks the files in the directory and loads them into the struct
 The files array is only loaded into the struct if a tuckr.json is present */
 func NewSetupHandle() (SetupHandle, error) {
+	return NewSetupHandleAt(".")
+}
+
+// Lists the scripts' directories, swapped out by tests to list them in another order than by name
+var readDir = ioutil.ReadDir
+
+// Same as NewSetupHandle but loads the files from path instead of the current directory
+func NewSetupHandleAt(path string) (SetupHandle, error) {
 	var handler SetupHandle
-	dir, err := os.Open(".")
+	files, err := readDir(path)
 	if err != nil {
 		return handler, err
 	}
-	files, err := dir.Readdir(-1)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	env, err := loadEnv(filepath.Join(path, EnvName))
 	if err != nil {
 		return handler, err
 	}
-	handler = SetupHandle{files}
+	handler = SetupHandle{Dir: path, WorkingDir: files, Env: env}
 	return handler, nil
 }
 
 // Runs all scripts that start with a set_ prefix
 func (s SetupHandle) RunScripts() error {
+	return s.RunScriptsWithPrefix("set_")
+}
+
+/* Runs all scripts that start with the given prefix
+A failing script doesn't stop the others from running, the failures are
+reported once all scripts ran. Scripts marked with SkipMarker are not run */
+func (s SetupHandle) RunScriptsWithPrefix(prefix string) error {
 	var curr string
+	failed := 0
 	for _, file := range s.WorkingDir {
 		curr = file.Name()
-		if strings.HasPrefix(curr, "set_") {
-			fmt.Println(aurora.Green("Running script:"), curr)
-			cmd := exec.Command(os.ExpandEnv("$SHELL"), curr)
-			cmd.Stdout = os.Stdout
-			cmd.Run()
+		if strings.HasPrefix(curr, prefix) {
+			if hasSkipMarker(filepath.Join(s.Dir, curr)) {
+				if !s.Quiet {
+					fmt.Println(aurora.Yellow("Skipping script:"), curr, "is marked with", SkipMarker)
+				}
+				continue
+			}
+			if err := s.runScript(curr); err != nil {
+				fmt.Println(aurora.Red(err))
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return errors.New("Error: " + strconv.Itoa(failed) + " script(s) in " + s.Dir + " failed")
+	}
+	return nil
+}
+
+// Returns the scripts that RunScriptsWithPrefix would run in the order it runs them
+func (s SetupHandle) Scripts(prefix string) []string {
+	var scripts []string
+	for _, file := range s.WorkingDir {
+		name := file.Name()
+		if strings.HasPrefix(name, prefix) && !hasSkipMarker(filepath.Join(s.Dir, name)) {
+			scripts = append(scripts, name)
 		}
 	}
+	return scripts
+}
+
+// Comment that keeps a script from being run along with the others
+const SkipMarker = "# tuckr: skip"
+
+/* Returns true if the script's first line is the skip marker
+The line after a shebang counts as the first line too */
+func hasSkipMarker(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 2 && scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == SkipMarker {
+			return true
+		}
+		if !strings.HasPrefix(line, "#!") {
+			return false
+		}
+	}
+	return false
+}
+
+// Returns true if the handle's directory has a script with the name
+func (s SetupHandle) HasScript(name string) bool {
+	for _, file := range s.WorkingDir {
+		if file.Name() == name && !file.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs a single script by name, returns an error if it's not in the handle's directory
+func (s SetupHandle) RunScript(name string) error {
+	if !s.HasScript(name) {
+		return errors.New("Error: Script " + name + " not found in " + s.Dir)
+	}
+	return s.runScript(name)
+}
+
+/* Runs the script with the user's shell the same way for every runner
+The error tells apart a missing shell, a missing script and a script that failed */
+func (s SetupHandle) runScript(name string) error {
+	if !s.Quiet {
+		fmt.Println(aurora.Green("Running script:"), name)
+	}
+	sh := Shell()
+	if _, err := exec.LookPath(sh); err != nil {
+		return errors.New("Error: Shell " + sh + " was not found, check that $SHELL points to an installed shell")
+	}
+	path := filepath.Join(s.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return errors.New("Error: Script " + path + " was not found, it may have been moved or deleted since tuckr started")
+	}
+	cmd := exec.Command(sh, path)
+	cmd.Env = s.ScriptEnv()
+	var output bytes.Buffer
+	cmd.Stdout = os.Stdout
+	if s.Quiet {
+		cmd.Stdout = &output
+	}
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		os.Stdout.Write(output.Bytes())
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return errors.New("Error: Script " + path + " exited with status " + strconv.Itoa(exitErr.ExitCode()) + ", run it with " + sh + " directly to debug it")
+	}
+	if err != nil {
+		return errors.New("Error: Could not start " + path + " with " + sh + ": " + err.Error())
+	}
 	return nil
 }
+
+// Returns the user's $SHELL falling back to sh when it's not set, it's what scripts are run with
+func Shell() string {
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return sh
+	}
+	return "sh"
+}
+
+/* Returns true if sh checks the syntax of what it's given with -n without running it
+It's asked to check code that would exit with an error if it ran and code that
+doesn't parse, only a shell that accepts the first and rejects the second supports -n */
+func checksSyntax(sh string) bool {
+	if exec.Command(sh, "-n", "-c", "exit 3").Run() != nil {
+		return false
+	}
+	return exec.Command(sh, "-n", "-c", "if").Run() != nil
+}
+
+/* Checks the syntax of all set_ and unset_ scripts without running them, the ones
+marked with SkipMarker are left out since they never run
+Returns the scripts that would fail to parse mapped to the shell's error output */
+func (s SetupHandle) CheckScripts() (map[string]string, error) {
+	broken := map[string]string{}
+	sh := Shell()
+	if _, err := exec.LookPath(sh); err != nil {
+		return broken, errors.New("Error: Shell " + sh + " was not found")
+	}
+	if !checksSyntax(sh) {
+		return broken, errors.New("Error: " + sh + " does not support syntax checking with -n")
+	}
+	for _, file := range s.WorkingDir {
+		curr := file.Name()
+		if file.IsDir() || !strings.HasPrefix(curr, "set_") && !strings.HasPrefix(curr, "unset_") {
+			continue
+		}
+		path := filepath.Join(s.Dir, curr)
+		if hasSkipMarker(path) {
+			continue
+		}
+		out, err := exec.Command(sh, "-n", path).CombinedOutput()
+		if err != nil {
+			broken[curr] = strings.TrimSpace(string(out))
+		}
+	}
+	return broken, nil
+}
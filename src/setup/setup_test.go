@@ -0,0 +1,136 @@
+package setup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Writes the scripts into a new temporary directory and returns a handle for it
+func scriptsDir(t *testing.T, scripts map[string]string) SetupHandle {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "tuckr-setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, contents := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handle, err := NewSetupHandleAt(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handle
+}
+
+// Sets $SHELL for the rest of the test
+func setShell(t *testing.T, sh string) {
+	t.Helper()
+	old, ok := os.LookupEnv("SHELL")
+	os.Setenv("SHELL", sh)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv("SHELL", old)
+		} else {
+			os.Unsetenv("SHELL")
+		}
+	})
+}
+
+func TestCheckScriptsReportsBrokenSetAndUnsetScripts(t *testing.T) {
+	setShell(t, "sh")
+	handle := scriptsDir(t, map[string]string{
+		"set_ok.sh":      "echo ok\n",
+		"set_bad.sh":     "if true; then\n",
+		"unset_bad.sh":   "for x in; do\n",
+		"set_skipped.sh": "#!/bin/sh\n" + SkipMarker + "\nif true; then\n",
+		"other_bad.sh":   "if true; then\n",
+	})
+	broken, err := handle.CheckScripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range broken {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"set_bad.sh", "unset_bad.sh"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("broken scripts %v, want %v", names, want)
+	}
+}
+
+func TestCheckScriptsProbesTheShell(t *testing.T) {
+	handle := scriptsDir(t, map[string]string{"set_ok.sh": "echo ok\n"})
+	// true accepts any arguments without ever rejecting a syntax error
+	setShell(t, "true")
+	if _, err := handle.CheckScripts(); err == nil {
+		t.Error("a shell that ignores -n was taken as checking syntax")
+	}
+	setShell(t, "tuckr-no-such-shell")
+	if _, err := handle.CheckScripts(); err == nil {
+		t.Error("a missing shell was taken as checking syntax")
+	}
+}
+
+func TestRunScriptTellsFailuresApart(t *testing.T) {
+	handle := scriptsDir(t, map[string]string{"set_fail.sh": "exit 3\n", "set_gone.sh": ""})
+	handle.Quiet = true
+	tests := []struct {
+		shell  string
+		script string
+		want   string
+	}{
+		{"tuckr-no-such-shell", "set_fail.sh", "Shell tuckr-no-such-shell was not found, check that $SHELL"},
+		{"sh", "set_gone.sh", "it may have been moved or deleted"},
+		{"sh", "set_fail.sh", "exited with status 3, run it with sh directly"},
+		{"sh", "set_missing.sh", "Script set_missing.sh not found"},
+	}
+	if err := os.Remove(filepath.Join(handle.Dir, "set_gone.sh")); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		setShell(t, test.shell)
+		err := handle.RunScript(test.script)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("running %s with %s failed with %v, want %q", test.script, test.shell, err, test.want)
+		}
+	}
+}
+
+func TestRunScriptsSkipsMarkedScripts(t *testing.T) {
+	setShell(t, "sh")
+	out, err := ioutil.TempDir("", "tuckr-ran")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(out)
+	ran := func(name string) string {
+		return "touch " + filepath.Join(out, name) + "\n"
+	}
+	handle := scriptsDir(t, map[string]string{
+		"set_a.sh":       ran("a"),
+		"set_marked.sh":  SkipMarker + "\n" + ran("marked"),
+		"set_shebang.sh": "#!/bin/sh\n" + SkipMarker + "\n" + ran("shebang"),
+		"set_later.sh":   "echo\n" + SkipMarker + "\n" + ran("later"),
+	})
+	handle.Quiet = true
+	if err := handle.RunScripts(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"a": true, "marked": false, "shebang": false, "later": true} {
+		if _, err := os.Stat(filepath.Join(out, name)); (err == nil) != want {
+			t.Errorf("set_%s.sh ran %v, want %v", name, err == nil, want)
+		}
+	}
+	if want := []string{"set_a.sh", "set_later.sh"}; !reflect.DeepEqual(handle.Scripts("set_"), want) {
+		t.Errorf("Scripts lists %v, want %v", handle.Scripts("set_"), want)
+	}
+}
@@ -0,0 +1,53 @@
+package setup
+
+import (
+	"errors"
+	"strings"
+)
+
+/* Splits a command line into its words the way a shell would without expanding anything
+Words are separated by whitespace, single quotes keep everything in them literally,
+double quotes keep spaces and a backslash escapes the character after it, so paths
+with spaces can be passed as 'my file' or my\ file */
+func SplitCommand(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return words, errors.New("Error: Unterminated quote or escape in command: " + line)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,30 @@
+package setup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := map[string][]string{
+		"git clone":                 {"git", "clone"},
+		"  chmod\t600  {} ":         {"chmod", "600", "{}"},
+		`cp 'my file' "other file"`: {"cp", "my file", "other file"},
+		`touch my\ file`:            {"touch", "my file"},
+		`echo "it's" 'say "hi"'`:    {"echo", "it's", `say "hi"`},
+		`echo 'C:\path' "a\"b"`:     {"echo", `C:\path`, `a"b`},
+		"notify ünïcödé 'ファイル 名'":   {"notify", "ünïcödé", "ファイル 名"},
+		`empty '' ""`:               {"empty", "", ""},
+		"":                          nil,
+	}
+	for line, want := range tests {
+		if got, err := SplitCommand(line); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("SplitCommand(%q) = %q %v, want %q", line, got, err, want)
+		}
+	}
+	for _, line := range []string{`echo 'open`, `echo "open`, `echo trailing\`} {
+		if _, err := SplitCommand(line); err == nil {
+			t.Errorf("SplitCommand(%q) accepted an unterminated quote or escape", line)
+		}
+	}
+}
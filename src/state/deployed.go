@@ -0,0 +1,35 @@
+package state
+
+import (
+	"sort"
+	"time"
+)
+
+const deployedName = "deployed.json"
+
+// Maps every group that is currently set to when it was last set
+type Deployed map[string]time.Time
+
+// Loads the groups recorded as set, none are returned if nothing was recorded yet
+func LoadDeployed() (Deployed, error) {
+	deployed := Deployed{}
+	err := load(deployedName, &deployed)
+	return deployed, err
+}
+
+// Returns the groups from groups that aren't recorded as set
+func (d Deployed) New(groups []string) []string {
+	var fresh []string
+	for _, group := range groups {
+		if _, ok := d[group]; !ok {
+			fresh = append(fresh, group)
+		}
+	}
+	sort.Strings(fresh)
+	return fresh
+}
+
+// Saves the groups recorded as set for the next run
+func (d Deployed) Save() error {
+	return save(deployedName, d)
+}
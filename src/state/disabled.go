@@ -0,0 +1,29 @@
+package state
+
+import (
+	"time"
+)
+
+const disabledName = "disabled.json"
+
+/* Maps every group that is disabled to when it was disabled
+Disabled groups are skipped by set while links they already have are left alone */
+type Disabled map[string]time.Time
+
+// Loads the groups recorded as disabled, none are returned if nothing was recorded yet
+func LoadDisabled() (Disabled, error) {
+	disabled := Disabled{}
+	err := load(disabledName, &disabled)
+	return disabled, err
+}
+
+// Returns true if the group is recorded as disabled
+func (d Disabled) Has(group string) bool {
+	_, ok := d[group]
+	return ok
+}
+
+// Saves the groups recorded as disabled for the next run
+func (d Disabled) Save() error {
+	return save(disabledName, d)
+}
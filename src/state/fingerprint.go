@@ -0,0 +1,23 @@
+package state
+
+const fingerprintName = "fingerprint.json"
+
+/* The hashes of a store's files at the time it got fingerprinted
+Hash is the hash of the whole tree, see store.TreeHash */
+type Fingerprint struct {
+	Store string            `json:"store"`
+	Hash  string            `json:"hash"`
+	Files map[string]string `json:"files"`
+}
+
+// Loads the saved fingerprint, its Hash is empty if the store was never fingerprinted
+func LoadFingerprint() (Fingerprint, error) {
+	var fingerprint Fingerprint
+	err := load(fingerprintName, &fingerprint)
+	return fingerprint, err
+}
+
+// Saves the fingerprint to compare the store against later
+func (f Fingerprint) Save() error {
+	return save(fingerprintName, f)
+}
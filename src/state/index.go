@@ -0,0 +1,91 @@
+package state
+
+import (
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const indexName = "index.json"
+
+// What a store file looked like the last time it was deployed
+type FileEntry struct {
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+	Hash    string    `json:"hash"`
+}
+
+// Maps the path of every deployed store file to what it looked like when deployed
+type FileIndex map[string]FileEntry
+
+// Loads the index saved by the last run, an empty index is returned if there's none
+func LoadIndex() (FileIndex, error) {
+	index := FileIndex{}
+	err := load(indexName, &index)
+	return index, err
+}
+
+/* Returns true if the file changed since it was recorded
+The hash is only computed when the mtime or size differ, directories linked as
+a whole are only compared by their mtime */
+func (i FileIndex) Changed(path string) (bool, error) {
+	entry, ok := i[path]
+	if !ok {
+		return true, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return true, err
+	}
+	if info.IsDir() {
+		return !info.ModTime().Equal(entry.ModTime), nil
+	}
+	if info.ModTime().Equal(entry.ModTime) && info.Size() == entry.Size {
+		return false, nil
+	}
+	hash, err := store.HashFile(path)
+	if err != nil {
+		return true, err
+	}
+	return hash != entry.Hash, nil
+}
+
+// Records the current state of the file
+func (i FileIndex) Record(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		i[path] = FileEntry{ModTime: info.ModTime()}
+		return nil
+	}
+	hash, err := store.HashFile(path)
+	if err != nil {
+		return err
+	}
+	i[path] = FileEntry{ModTime: info.ModTime(), Size: info.Size(), Hash: hash}
+	return nil
+}
+
+// Moves the entries of the files inside of the from directory over to the to directory
+func (i FileIndex) Move(from string, to string) {
+	prefix := from + string(filepath.Separator)
+	moved := FileIndex{}
+	for path, entry := range i {
+		if path == from || strings.HasPrefix(path, prefix) {
+			delete(i, path)
+			moved[to+path[len(from):]] = entry
+		}
+	}
+	for path, entry := range moved {
+		i[path] = entry
+	}
+}
+
+// Saves the index so the next run can compare against it
+func (i FileIndex) Save() error {
+	return save(indexName, i)
+}
@@ -0,0 +1,70 @@
+package state
+
+import (
+	"github.com/raphgl/tuckr/store"
+	"os"
+)
+
+const linksName = "links.json"
+
+/* A link set created along with where its file is in the store and what the file held
+Rel is relative to the group's directory and Hash is empty for directories linked whole */
+type LinkEntry struct {
+	Source string `json:"source"`
+	Group  string `json:"group"`
+	Rel    string `json:"rel"`
+	Hash   string `json:"hash"`
+}
+
+/* Maps the target of every link set created to how it was created
+It tells which links are tuckr's without relying on where they point, so links
+into a store that moved are still known to be its own. It's kept in the state
+directory like the rest of the state, a registry inside of the store would move
+along with it and get shared with every machine the store is cloned on */
+type LinkRegistry map[string]LinkEntry
+
+// Loads the links recorded by past runs, none are returned if nothing was recorded yet
+func LoadLinks() (LinkRegistry, error) {
+	links := LinkRegistry{}
+	err := load(linksName, &links)
+	return links, err
+}
+
+// Records that target was linked to source, a file at rel in the group
+func (r LinkRegistry) Record(target string, source string, group string, rel string) error {
+	entry := LinkEntry{Source: source, Group: group, Rel: rel}
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if entry.Hash, err = store.HashFile(source); err != nil {
+			return err
+		}
+	}
+	r[target] = entry
+	return nil
+}
+
+/* Returns true if target is still the link recorded for the file at rel in the group,
+even if the store moved since. The file now at source has to hold what the recorded
+one did so a different file that took its place isn't mistaken for it */
+func (r LinkRegistry) Owns(target string, group string, rel string, source string) bool {
+	entry, ok := r[target]
+	if !ok || entry.Group != group || entry.Rel != rel {
+		return false
+	}
+	if dest, err := os.Readlink(target); err != nil || dest != entry.Source {
+		return false
+	}
+	if entry.Hash == "" {
+		return true
+	}
+	hash, err := store.HashFile(source)
+	return err == nil && hash == entry.Hash
+}
+
+// Saves the recorded links for the next run
+func (r LinkRegistry) Save() error {
+	return save(linksName, r)
+}
@@ -0,0 +1,73 @@
+package state
+
+import (
+	"encoding/json"
+	"github.com/raphgl/tuckr/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+/* Returns the directory where tuckr keeps what it knows about past runs
+$TUCKR_STATE_DIR takes precedence, otherwise it's tuckr inside of $XDG_STATE_HOME, see config.BaseDir */
+func Dir() (string, error) {
+	if dir := os.Getenv("TUCKR_STATE_DIR"); dir != "" {
+		return config.ExpandPath(dir)
+	}
+	dir, err := config.BaseDir("XDG_STATE_HOME")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tuckr"), nil
+}
+
+// Decodes the json state file name into v, leaving v untouched if the file doesn't exist
+func load(name string, v interface{}) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Encodes v into the json state file name, creating the state directory if needed
+func save(name string, v interface{}) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+}
+
+// Returns the directory holding the generations of backups set makes of taken targets
+func BackupsDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups"), nil
+}
+
+/* Returns the directory holding the plaintext of decrypted secrets while their groups are set
+Each group gets its own directory in it that only the owner can read */
+func SecretsDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "secrets"), nil
+}
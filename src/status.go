@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"os"
+)
+
+// Ends tuckr with the exit code, swapped out to check the exit code status gives
+var exit = os.Exit
+
+// How much of a group is deployed and what's wrong with the rest of it
+type groupStatus struct {
+	group    string
+	links    []manage.Link
+	linked   int
+	problems []manage.Problem
+}
+
+// Checks the links of a group across all of its targets
+func checkGroup(root string, group string, home string) (groupStatus, error) {
+	return checkGroupStreaming(root, group, home, nil)
+}
+
+/* Same as checkGroup but passes each link to checked as soon as it's known whether
+it's linked, so huge groups can be reported on while they're still being checked
+Broken links and conflicts that the registry knows set created before the store
+moved are reported as moved links instead */
+func checkGroupStreaming(root string, group string, home string, checked func(l manage.Link, linked bool)) (groupStatus, error) {
+	status := groupStatus{group: group}
+	deployments, err := groupDeployments(root, group, home)
+	if err != nil {
+		return status, err
+	}
+	cache, err := config.CacheDir()
+	if err != nil {
+		return status, err
+	}
+	registry, err := loadRegistry()
+	if err != nil {
+		return status, err
+	}
+	for _, d := range deployments {
+		links, err := groupLinks(root, group, d)
+		if err != nil {
+			return status, err
+		}
+		for _, l := range links {
+			linked := l.IsLinked()
+			if linked {
+				status.linked++
+			}
+			if checked != nil {
+				checked(l, linked)
+			}
+		}
+		moved, err := movedLinks(registry, root, cache, group, d, links)
+		if err != nil {
+			return status, err
+		}
+		problems := manage.FindProblems(d.src, links)
+		for i, p := range problems {
+			for _, l := range moved {
+				if p.Kind != manage.Orphan && p.Link.Target == l.Target {
+					problems[i].Kind = manage.Moved
+				}
+			}
+		}
+		status.links = append(status.links, links...)
+		status.problems = append(status.problems, problems...)
+	}
+	return status, nil
+}
+
+/* Handles the status command by printing how much of each group is linked
+and any problems with its links, with --fix each problem is resolved interactively
+With --summary-only a single line summing up every group is printed and the exit
+code is non-zero unless everything is linked. Each group is printed once it's checked,
+with --stream so is every file and the summary line comes at the end. --only-new-links
+leaves out the linked files and the problems, listing only what set would add */
+func runStatus(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "prompt for how to resolve each problem")
+	userName := flags.String("user", "", "check the links in this user's home instead")
+	summaryOnly := flags.Bool("summary-only", false, "only print a one line summary and exit non-zero if anything isn't linked")
+	explain := flags.Bool("explain", false, "print why the groups and files that aren't linked are left alone")
+	print0 := flags.Bool("print0", false, "only print the targets that aren't linked separated with NUL")
+	stream := flags.Bool("stream", false, "print every file as soon as it's checked and a summary of them all at the end")
+	onlyNew := flags.Bool("only-new-links", false, "only list the files that aren't linked yet")
+	flags.Parse(args)
+	args = flags.Args()
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := targetHome(*userName)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{"*"}
+	}
+	filter, err := platformFilter(root)
+	if err != nil {
+		return err
+	}
+	groups, err := selectGroups(root, args, filter)
+	if err != nil {
+		return err
+	}
+
+	disabled, err := state.LoadDisabled()
+	if err != nil {
+		return err
+	}
+	var checked func(manage.Link, bool)
+	if *stream && !*print0 && !*summaryOnly {
+		checked = printChecked
+		if *onlyNew {
+			checked = func(l manage.Link, linked bool) {
+				if !linked {
+					printChecked(l, linked)
+				}
+			}
+		}
+	}
+	// Every group's problems are fixed with answers from the same reader
+	answers := bufio.NewReader(os.Stdin)
+	var statuses []groupStatus
+	for _, group := range groups {
+		if checked != nil {
+			fmt.Println(aurora.Cyan("Checking:"), group)
+		}
+		status, err := checkGroupStreaming(root, group, home, checked)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, status)
+		if *print0 || *summaryOnly {
+			continue
+		}
+		if *onlyNew {
+			if checked == nil {
+				printNewLinks(root, status)
+			}
+			continue
+		}
+		label := aurora.Yellow("Partially linked:")
+		if status.linked == len(status.links) {
+			label = aurora.Green("Linked:")
+		} else if status.linked == 0 {
+			label = aurora.Red("Not linked:")
+		}
+		fmt.Println(label, describe(root, status.group), fmt.Sprintf("(%d/%d)", status.linked, len(status.links)))
+		if *fix {
+			if err := manage.FixInteractively(status.problems, answers); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, p := range status.problems {
+			fmt.Println("  ", aurora.Yellow(p.String()+":"), p.Link.Target)
+		}
+		if *explain {
+			if err := explainStatus(root, status.group, home, disabled); err != nil {
+				return err
+			}
+		}
+	}
+	if *print0 {
+		printPaths(unlinkedTargets(statuses), true)
+		return nil
+	}
+	if *summaryOnly || *stream {
+		line, inSync := summarize(statuses)
+		fmt.Println(line)
+		if *summaryOnly && !inSync {
+			exit(1)
+		}
+	}
+	return nil
+}
+
+// Prints whether a file of a group is linked as it gets checked, see checkGroupStreaming
+func printChecked(l manage.Link, linked bool) {
+	if linked {
+		fmt.Println(aurora.Green("  linked:"), l.Target)
+	} else {
+		fmt.Println(aurora.Red("  not linked:"), l.Target)
+	}
+}
+
+/* Prints the group with the targets of its files that aren't linked yet
+Groups that are fully linked aren't printed at all */
+func printNewLinks(root string, status groupStatus) {
+	var targets []string
+	for _, l := range status.links {
+		if !l.IsLinked() {
+			targets = append(targets, l.Target)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	fmt.Println(aurora.Cyan("New links:"), describe(root, status.group), fmt.Sprintf("(%d/%d)", len(targets), len(status.links)))
+	for _, target := range targets {
+		fmt.Println("  ", target)
+	}
+}
+
+// Returns the targets of the statuses that aren't linked, orphaned links included
+func unlinkedTargets(statuses []groupStatus) []string {
+	var targets []string
+	for _, status := range statuses {
+		for _, l := range status.links {
+			if !l.IsLinked() {
+				targets = append(targets, l.Target)
+			}
+		}
+		for _, p := range status.problems {
+			if p.Kind == manage.Orphan {
+				targets = append(targets, p.Link.Target)
+			}
+		}
+	}
+	return targets
+}
+
+/* Sums up the statuses into a single key=value line meant to be parsed by scripts
+Also returns whether every file is linked without problems */
+func summarize(statuses []groupStatus) (string, bool) {
+	files, linked, problems := 0, 0, 0
+	for _, status := range statuses {
+		files += len(status.links)
+		linked += status.linked
+		problems += len(status.problems)
+	}
+	inSync := linked == files && problems == 0
+	result := "in-sync"
+	if !inSync {
+		result = "drifted"
+	}
+	line := fmt.Sprintf("status=%s groups=%d files=%d linked=%d problems=%d", result, len(statuses), files, linked, problems)
+	return line, inSync
+}
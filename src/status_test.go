@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"github.com/raphgl/tuckr/manage"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStatusShowsReadmeDescription(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "set nu")
+	e.write(t, "Configs/vim/README", "Vim settings and plugins\nMore details")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	out := captureOutput(t, func() {
+		if err := runStatus(nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, "vim - Vim settings and plugins (0/1)") {
+		t.Errorf("status doesn't describe vim with its README:\n%s", out)
+	}
+	if !strings.Contains(out, " zsh (0/1)") || strings.Contains(out, "zsh -") {
+		t.Errorf("status describes zsh which has no README:\n%s", out)
+	}
+	if strings.Contains(out, "More details") {
+		t.Errorf("status shows more than the README's first line:\n%s", out)
+	}
+
+	out = captureOutput(t, func() {
+		if err := runGroups(nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := "vim - Vim settings and plugins\nzsh\n"; out != want {
+		t.Errorf("groups printed %q, want %q", out, want)
+	}
+}
+
+func TestStatusFixSharesTheAnswersAcrossGroups(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "set nu")
+	zshrc := e.write(t, "Configs/zsh/.zshrc", "")
+	writeTestFile(t, e.inHome(".vimrc"), "mine")
+	writeTestFile(t, e.inHome(".zshrc"), "mine")
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("b\nr\n")
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+	captureOutput(t, func() {
+		if err := runStatus([]string{"--fix"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertLinked(t, e.inHome(".vimrc"), vimrc)
+	assertLinked(t, e.inHome(".zshrc"), zshrc)
+	if _, err := os.Stat(e.inHome(".vimrc.bak")); err != nil {
+		t.Errorf("vim's conflict wasn't backed up: %v", err)
+	}
+	assertMissing(t, e.inHome(".zshrc.bak"))
+}
+
+// Runs status with the flags and returns what it printed and the code it exited with
+func statusExit(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	code := 0
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+	out := captureOutput(t, func() {
+		if err := runStatus(args); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return out, code
+}
+
+func TestStatusSummaryOnlyExitCodes(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	if err := setGroups(e.store, e.home, []string{"vim", "zsh"}, setOptions{quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	out, code := statusExit(t, "--summary-only")
+	if want := "status=in-sync groups=2 files=2 linked=2 problems=0\n"; out != want || code != 0 {
+		t.Errorf("in sync status printed %q and exited with %d, want %q and 0", out, code, want)
+	}
+
+	os.Remove(e.inHome(".zshrc"))
+	out, code = statusExit(t, "--summary-only")
+	if want := "status=drifted groups=2 files=2 linked=1 problems=0\n"; out != want || code != 1 {
+		t.Errorf("drifted status printed %q and exited with %d, want %q and 1", out, code, want)
+	}
+}
+
+func TestPrint0KeepsTrickyPathsIntact(t *testing.T) {
+	e := newTestEnv(t)
+	vimrc := e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/vim/my notes.txt", "")
+	e.write(t, "Configs/vim/two\nlines", "")
+	if err := os.Symlink(vimrc, e.inHome(".vimrc")); err != nil {
+		t.Fatal(err)
+	}
+	out := captureOutput(t, func() {
+		if err := runStatus([]string{"--print0", "vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	want := e.inHome("my notes.txt") + "\x00" + e.inHome("two\nlines") + "\x00"
+	if out != want {
+		t.Errorf("status --print0 printed %q, want %q", out, want)
+	}
+
+	out = captureOutput(t, func() {
+		if err := runResolve([]string{"--print0", "vim", "two\nlines"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := runGroups([]string{"--print0"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := e.inHome("two\nlines") + "\x00vim\x00"; out != want {
+		t.Errorf("resolve and groups --print0 printed %q, want %q", out, want)
+	}
+}
+
+func TestStatusStreamPrintsEveryFileBeforeTheSummary(t *testing.T) {
+	e := newTestEnv(t)
+	for _, rel := range []string{".vimrc", ".vim/colors/dark.vim", ".vim/ftplugin/go.vim"} {
+		e.write(t, "Configs/vim/"+rel, "")
+	}
+	e.write(t, "Configs/zsh/.zshrc", "")
+	e.write(t, "Configs/zsh/.zprofile", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err := os.Remove(e.inHome(".vim/ftplugin/go.vim")); err != nil {
+		t.Fatal(err)
+	}
+
+	var streamed []string
+	status, err := checkGroupStreaming(e.store, "vim", e.home, func(l manage.Link, linked bool) {
+		streamed = append(streamed, fmt.Sprint(l.Target, " ", linked))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != len(status.links) || status.linked != 2 {
+		t.Errorf("%d files were streamed, %d checked with %d linked", len(streamed), len(status.links), status.linked)
+	}
+	for i, l := range status.links {
+		if want := fmt.Sprint(l.Target, " ", l.IsLinked()); i < len(streamed) && streamed[i] != want {
+			t.Errorf("file %d was streamed as %q, want %q", i, streamed[i], want)
+		}
+	}
+
+	out := uncolored(captureOutput(t, func() {
+		if err := runStatus([]string{"--stream"}); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if last := lines[len(lines)-1]; last != "status=drifted groups=2 files=5 linked=2 problems=0" {
+		t.Errorf("status --stream ended with %q, want the summary", last)
+	}
+	linked, notLinked := strings.Count(out, "  linked: "), strings.Count(out, "  not linked: ")
+	if linked != 2 || notLinked != 3 {
+		t.Errorf("status --stream printed %d linked and %d unlinked files:\n%s", linked, notLinked, out)
+	}
+	// vim's files come out before zsh is even checked
+	if vim, zsh := strings.Index(out, "  not linked: "+e.inHome(".vim/ftplugin/go.vim")), strings.Index(out, "Checking: zsh"); vim < 0 || zsh < vim {
+		t.Errorf("vim's files weren't streamed before zsh was checked:\n%s", out)
+	}
+}
+
+func TestStatusOnlyNewLinksListsWhatIsntLinked(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim", "zsh"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	e.write(t, "Configs/vim/.exrc", "")
+	e.write(t, "Configs/git/.gitconfig", "")
+
+	for _, args := range [][]string{{"--only-new-links"}, {"--only-new-links", "--stream"}} {
+		out := uncolored(captureOutput(t, func() {
+			if err := runStatus(args); err != nil {
+				t.Fatal(err)
+			}
+		}))
+		for _, rel := range []string{".exrc", ".gitconfig"} {
+			if !strings.Contains(out, e.inHome(rel)+"\n") {
+				t.Errorf("status %v didn't list ~/%s:\n%s", args, rel, out)
+			}
+		}
+		for _, rel := range []string{".vimrc", ".zshrc"} {
+			if strings.Contains(out, e.inHome(rel)) {
+				t.Errorf("status %v listed the linked ~/%s:\n%s", args, rel, out)
+			}
+		}
+		if len(args) == 1 && (!strings.Contains(out, "New links: vim (1/2)") || strings.Contains(out, "zsh")) {
+			t.Errorf("status %v didn't list only the groups with new links:\n%s", args, out)
+		}
+	}
+}
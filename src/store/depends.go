@@ -0,0 +1,54 @@
+package store
+
+import (
+	"errors"
+	"strings"
+)
+
+/* Orders the groups so that every group comes after the groups in its dependsOn
+Groups that don't depend on each other keep their order and dependencies that
+aren't among the groups are left out, a cycle of dependencies is an error */
+func SortByDependencies(root string, groups []string) ([]string, error) {
+	selected := map[string]bool{}
+	for _, group := range groups {
+		selected[group] = true
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	marks := map[string]int{}
+	var sorted []string
+	var visit func(group string, path []string) error
+	visit = func(group string, path []string) error {
+		switch marks[group] {
+		case done:
+			return nil
+		case visiting:
+			return errors.New("Error: Groups depend on each other in a cycle: " + strings.Join(append(path, group), " -> "))
+		}
+		marks[group] = visiting
+		path = append(append([]string{}, path...), group)
+		conf, err := LoadGroupConfig(root, group)
+		if err != nil {
+			return err
+		}
+		for _, dep := range conf.DependsOn {
+			if !selected[dep] {
+				continue
+			}
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		marks[group] = done
+		sorted = append(sorted, group)
+		return nil
+	}
+	for _, group := range groups {
+		if err := visit(group, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSortByDependenciesOrdersDependenciesFirst(t *testing.T) {
+	root := tempStore(t, map[string]string{
+		"Configs/vim/.tuckr.json":     `{"dependsOn": ["fonts", "plugins"]}`,
+		"Configs/plugins/.keep":       "",
+		"Configs/plugins/.tuckr.json": `{"dependsOn": ["git"]}`,
+		"Configs/git/.gitconfig":      "",
+		"Configs/fonts/font.ttf":      "",
+		"Configs/zsh/.tuckr.json":     `{"dependsOn": ["unselected"]}`,
+	})
+	sorted, err := SortByDependencies(root, []string{"zsh", "vim", "fonts", "git", "plugins"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"zsh", "fonts", "git", "plugins", "vim"}; !reflect.DeepEqual(sorted, want) {
+		t.Errorf("sorted into %v, want %v", sorted, want)
+	}
+	// Dependencies that aren't asked for aren't added
+	if sorted, err := SortByDependencies(root, []string{"vim", "git"}); err != nil || !reflect.DeepEqual(sorted, []string{"vim", "git"}) {
+		t.Errorf("sorted into %v %v, want [vim git] as they are", sorted, err)
+	}
+}
+
+func TestSortByDependenciesReportsCycles(t *testing.T) {
+	root := tempStore(t, map[string]string{
+		"Configs/a/.tuckr.json": `{"dependsOn": ["b"]}`,
+		"Configs/b/.tuckr.json": `{"dependsOn": ["c"]}`,
+		"Configs/c/.tuckr.json": `{"dependsOn": ["a"]}`,
+		"Configs/d/.tuckr.json": `{"dependsOn": ["d"]}`,
+	})
+	tests := map[string][]string{
+		"a -> b -> c -> a": {"a", "b", "c"},
+		"d -> d":           {"d"},
+	}
+	for want, groups := range tests {
+		_, err := SortByDependencies(root, groups)
+		if err == nil || !strings.HasSuffix(err.Error(), "cycle: "+want) {
+			t.Errorf("sorting %v failed with %v, want the cycle %s", groups, err, want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+/* Evaluates an enableWhen expression against the environment
+An expression is made of conditions joined by && and ||, where && binds tighter
+Each condition is one of
+
+	$VAR           VAR is set and not empty
+	!$VAR          VAR is unset or empty
+	$VAR == value  VAR equals value
+	$VAR != value  VAR doesn't equal value
+
+An empty expression is always true */
+func EvalEnableWhen(expr string) (bool, error) {
+	if strings.TrimSpace(expr) == "" {
+		return true, nil
+	}
+	for _, alternative := range strings.Split(expr, "||") {
+		all := true
+		for _, cond := range strings.Split(alternative, "&&") {
+			ok, err := evalCondition(strings.TrimSpace(cond))
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				all = false
+			}
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Evaluates a single condition of an enableWhen expression
+func evalCondition(cond string) (bool, error) {
+	for _, op := range []string{"==", "!="} {
+		if i := strings.Index(cond, op); i >= 0 {
+			value, err := envValue(strings.TrimSpace(cond[:i]))
+			if err != nil {
+				return false, err
+			}
+			want := strings.Trim(strings.TrimSpace(cond[i+len(op):]), `"'`)
+			return (value == want) == (op == "=="), nil
+		}
+	}
+	if strings.HasPrefix(cond, "!") {
+		value, err := envValue(strings.TrimSpace(cond[1:]))
+		return value == "", err
+	}
+	value, err := envValue(cond)
+	return value != "", err
+}
+
+// Returns the value of a $VAR, or ${VAR}, reference
+func envValue(ref string) (string, error) {
+	if !strings.HasPrefix(ref, "$") || len(ref) < 2 {
+		return "", errors.New("Error: Invalid enableWhen condition " + ref + ", expected a $VARIABLE")
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(ref[1:], "{"), "}")
+	return os.Getenv(name), nil
+}
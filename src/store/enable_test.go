@@ -0,0 +1,33 @@
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEvalEnableWhen(t *testing.T) {
+	os.Setenv("TUCKR_TEST_WORK", "acme")
+	os.Unsetenv("TUCKR_TEST_UNSET")
+	defer os.Unsetenv("TUCKR_TEST_WORK")
+	tests := map[string]bool{
+		"":                                      true,
+		"$TUCKR_TEST_WORK":                      true,
+		"${TUCKR_TEST_WORK}":                    true,
+		"$TUCKR_TEST_UNSET":                     false,
+		"!$TUCKR_TEST_UNSET":                    true,
+		"$TUCKR_TEST_WORK == acme":              true,
+		`$TUCKR_TEST_WORK == "other"`:           false,
+		"$TUCKR_TEST_WORK != other":             true,
+		"$TUCKR_TEST_WORK && $TUCKR_TEST_UNSET": false,
+		"$TUCKR_TEST_UNSET || $TUCKR_TEST_WORK": true,
+		"$TUCKR_TEST_UNSET && $TUCKR_TEST_WORK || !$TUCKR_TEST_UNSET": true,
+	}
+	for expr, want := range tests {
+		if got, err := EvalEnableWhen(expr); err != nil || got != want {
+			t.Errorf("EvalEnableWhen(%q) = %v %v, want %v", expr, got, err, want)
+		}
+	}
+	if _, err := EvalEnableWhen("WORK"); err == nil {
+		t.Error("a condition without a $VARIABLE was accepted")
+	}
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/* Returns the hash of every file in the store keyed by its slash separated path
+relative to the store. Symlinks are hashed by where they point to, the store's
+.git and its history are left out since they change without the files changing */
+func HashTree(root string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if IsLocalState(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			dest, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256([]byte("symlink " + dest))
+			files[rel] = hex.EncodeToString(sum[:])
+			return nil
+		}
+		hash, err := HashFile(path)
+		if err != nil {
+			return err
+		}
+		files[rel] = hash
+		return nil
+	})
+	return files, err
+}
+
+/* Returns true for the slash separated paths, relative to the store, that only hold
+state of this machine's copy of the store. That's its .git and its history along with
+the rotated history files */
+func IsLocalState(rel string) bool {
+	return rel == ".git" || strings.HasPrefix(rel, ".git/") || strings.HasPrefix(rel, HistoryName)
+}
+
+/* Combines the hashes of a tree into a single hash
+The paths are sorted first so the same files always give the same hash */
+func TreeHash(files map[string]string) string {
+	var paths []string
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, path := range paths {
+		h.Write([]byte(path + "\x00" + files[path] + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
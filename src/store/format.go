@@ -0,0 +1,64 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// The directory conventions a store can follow
+const (
+	// Groups are the directories in Configs, Bin and Services
+	FormatTuckr = "tuckr"
+	// Groups are the directories at the top of the store and get deployed into home
+	FormatFlat = "flat"
+	// Groups are the directories at the top of the store and get deployed into
+	// the directory holding the store, like GNU Stow's packages
+	FormatStow = "stow"
+)
+
+/* The convention the store follows, it decides where groups are and where they get
+deployed to. It's set once on startup from --store-format or the config */
+var Format = FormatTuckr
+
+// Checks name is one of the formats, an empty name is the tuckr format
+func ParseFormat(name string) (string, error) {
+	switch name {
+	case "":
+		return FormatTuckr, nil
+	case FormatTuckr, FormatFlat, FormatStow:
+		return name, nil
+	}
+	return "", errors.New("Error: Unknown store format " + name + ", expected tuckr, flat or stow")
+}
+
+// Returns the directories of the store holding the groups, the store itself for flat layouts
+func groupFolders() []string {
+	if Format == FormatTuckr {
+		return groupDirs
+	}
+	return []string{""}
+}
+
+// Returns every directory of the store that can have a directory for a group, Hooks and Secrets included
+func Folders() []string {
+	return append(append([]string{}, groupFolders()...), HooksDir, SecretsDir)
+}
+
+/* Returns true if the directory name inside of folder can be a group
+Flat layouts keep Hooks, Secrets and hidden directories like .git next to their
+groups so those aren't groups */
+func isGroupName(folder string, name string) bool {
+	if folder != "" {
+		return true
+	}
+	return !strings.HasPrefix(name, ".") && name != HooksDir && name != SecretsDir
+}
+
+// Returns the directory the groups of a flat layout get deployed into
+func flatRoot(root string, home string) string {
+	if Format == FormatStow {
+		return filepath.Dir(filepath.Clean(root))
+	}
+	return home
+}
@@ -0,0 +1,294 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/raphgl/tuckr/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name of the file that holds a group's settings
+const GroupConfigName = ".tuckr.json"
+
+/* Settings a group can declare in its .tuckr.json
+Targets are the directories the group gets deployed into, defaulting to $HOME, see TargetsIn
+Platforms are the operating systems, as in runtime.GOOS, the group is meant for,
+defaulting to all of them
+LinkAsDirectory are subdirectories of the group that get linked whole instead of
+having each of their files linked
+EnableWhen is an expression over environment variables that has to hold for the
+group to be deployed, see EvalEnableWhen
+Actions maps files of the group to the commands run after they get linked, an
+argument of {} in a command is replaced by the path of the link
+DotPrefix links the files and directories at the top of the group with a dot in
+front of their names so vimrc gets linked as .vimrc, DotUnderscore instead turns
+a dot_ at the start of any name into a dot like chezmoi does
+DependsOn are the groups that have to be set before this one when they're set together
+Executable are files of the group that get made executable when they're linked
+Ignore holds patterns like the ones in .tuckrignore, which is only read when it's empty
+Links maps files of the group to the paths, relative to the target, they get linked
+as instead of their own names, links.map is only read when it's empty
+Hooks is a directory of the group holding its scripts instead of Hooks/<group>,
+it never gets linked
+Tags are free form labels like cli or gui that groups can be selected by */
+type GroupConfig struct {
+	Targets         []string            `json:"targets"`
+	Platforms       []string            `json:"platforms"`
+	LinkAsDirectory []string            `json:"linkAsDirectory"`
+	EnableWhen      string              `json:"enableWhen"`
+	Actions         map[string][]string `json:"actions"`
+	DotPrefix       bool                `json:"dotPrefix"`
+	DotUnderscore   bool                `json:"dotUnderscore"`
+	DependsOn       []string            `json:"dependsOn"`
+	Executable      []string            `json:"executable"`
+	Ignore          []string            `json:"ignore"`
+	Links           map[string]string   `json:"links"`
+	Hooks           string              `json:"hooks"`
+	Tags            []string            `json:"tags"`
+}
+
+/* Returns the settings of a group without one with every field present but empty
+Written out it's a .tuckr.json listing every setting a group can have */
+func GroupConfigSkeleton() GroupConfig {
+	return GroupConfig{
+		Targets:         []string{},
+		Platforms:       []string{},
+		LinkAsDirectory: []string{},
+		Actions:         map[string][]string{},
+		DependsOn:       []string{},
+		Executable:      []string{},
+		Ignore:          []string{},
+		Links:           map[string]string{},
+		Tags:            []string{},
+	}
+}
+
+/* Returns the path, relative to the target, that a file of the group gets linked to
+rel is relative to the group and gets mapped through Links, otherwise it has the
+group's name transforms applied */
+func (c GroupConfig) LinkName(rel string) string {
+	if dest, ok := c.Links[filepath.ToSlash(rel)]; ok {
+		return filepath.FromSlash(dest)
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i, part := range parts {
+		if c.DotUnderscore && strings.HasPrefix(part, "dot_") && len(part) > len("dot_") {
+			parts[i] = "." + part[len("dot_"):]
+		}
+	}
+	if c.DotPrefix && !strings.HasPrefix(parts[0], ".") {
+		parts[0] = "." + parts[0]
+	}
+	return filepath.FromSlash(strings.Join(parts, "/"))
+}
+
+// Returns true if the group is meant to be deployed on the goos operating system
+func (c GroupConfig) SupportsPlatform(goos string) bool {
+	if len(c.Platforms) == 0 {
+		return true
+	}
+	for _, p := range c.Platforms {
+		if p == goos {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the group is labelled with any of the tags
+func (c GroupConfig) HasTag(tags []string) bool {
+	for _, tag := range tags {
+		for _, t := range c.Tags {
+			if t == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/* Swapped out by tests to count how often the group's files are looked at and read
+and to list directories in another order than by name */
+var (
+	stat     = os.Stat
+	readFile = ioutil.ReadFile
+	readDir  = ioutil.ReadDir
+)
+
+// What a file looked like when it was read, the zero value stands for a missing file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Returns the stamp of the file at path, a missing file gets the zero value
+func stampOf(path string) (fileStamp, error) {
+	info, err := stat(path)
+	if os.IsNotExist(err) {
+		return fileStamp{}, nil
+	}
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+/* The settings of a group along with the stamps of its directory, .tuckr.json and
+links.map when they were read */
+type cachedGroupConfig struct {
+	stamps   [3]fileStamp
+	settings GroupConfig
+}
+
+/* Group settings keyed by the group's directory
+A single command loads the same group's settings many times, and serve for as long
+as it runs, so they're only read again once the directory, which changes when a
+.tuckr.json or links.map is added or removed, or either file has a different mtime
+or size. Files changed less than a second before being read aren't cached since a
+change right after in the same tick of the filesystem's clock would go unnoticed */
+var groupConfigCache = struct {
+	sync.Mutex
+	entries map[string]cachedGroupConfig
+}{entries: map[string]cachedGroupConfig{}}
+
+/* Reads the group's .tuckr.json
+A group without one gets the default settings, either way the links of a
+links.map fill in Links when it's empty. The settings are cached, see groupConfigCache */
+func LoadGroupConfig(root string, group string) (GroupConfig, error) {
+	dir := GroupPath(root, group)
+	paths := [3]string{dir, filepath.Join(dir, GroupConfigName), filepath.Join(dir, LinksMapName)}
+	var stamps [3]fileStamp
+	for i, path := range paths {
+		stamp, err := stampOf(path)
+		if err != nil {
+			return GroupConfig{}, err
+		}
+		stamps[i] = stamp
+	}
+	groupConfigCache.Lock()
+	cached, ok := groupConfigCache.entries[dir]
+	groupConfigCache.Unlock()
+	if ok && cached.stamps == stamps {
+		return cached.settings, nil
+	}
+
+	settings, err := loadGroupManifest(paths[1], group)
+	if err != nil {
+		return settings, err
+	}
+	if len(settings.Links) == 0 {
+		links, err := loadLinksMap(paths[2])
+		if err != nil {
+			return settings, err
+		}
+		if len(links) > 0 {
+			settings.Links = links
+		}
+	}
+	recent := time.Now().Add(-time.Second)
+	for _, stamp := range stamps {
+		if stamp.modTime.After(recent) {
+			return settings, nil
+		}
+	}
+	groupConfigCache.Lock()
+	groupConfigCache.entries[dir] = cachedGroupConfig{stamps: stamps, settings: settings}
+	groupConfigCache.Unlock()
+	return settings, nil
+}
+
+// Reads the group's .tuckr.json at path, a missing one gives the default settings
+func loadGroupManifest(path string, group string) (GroupConfig, error) {
+	var settings GroupConfig
+	data, err := readFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, errors.New("Error: " + group + "'s " + GroupConfigName + " is malformed: " + err.Error())
+	}
+	return settings, nil
+}
+
+/* Returns the targets expanded with a leading ~ being home, see config.ExpandPathIn
+The targets are kept as they're written until then since home depends on whose home
+the group gets deployed into */
+func (c GroupConfig) TargetsIn(home string) ([]string, error) {
+	targets := make([]string, len(c.Targets))
+	for i, target := range c.Targets {
+		var err error
+		if targets[i], err = config.ExpandPathIn(target, home); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+/* Returns true for the files at the top of a group that describe the group
+itself rather than being part of the dotfiles */
+func IsGroupMeta(rel string) bool {
+	return rel == ReadmeName || rel == GroupConfigName || rel == IgnoreName || rel == LinksMapName
+}
+
+// Returns true if the path, relative to the group, is in the group's Hooks directory
+func (c GroupConfig) InHooks(rel string) bool {
+	if c.Hooks == "" {
+		return false
+	}
+	hooks := filepath.Clean(c.Hooks)
+	return rel == hooks || strings.HasPrefix(rel, hooks+string(filepath.Separator))
+}
+
+/* Returns the directory holding the group's scripts
+It's the Hooks directory of its .tuckr.json when it has one, otherwise Hooks/<group> */
+func HooksPath(root string, group string) (string, error) {
+	conf, err := LoadGroupConfig(root, group)
+	if err != nil {
+		return "", err
+	}
+	if conf.Hooks != "" {
+		return filepath.Join(GroupPath(root, group), conf.Hooks), nil
+	}
+	return filepath.Join(root, HooksDir, group), nil
+}
+
+// Name of the file mapping files of a group to the names they get linked as
+const LinksMapName = "links.map"
+
+/* Reads a links.map, each line has a file of the group and the path, relative
+to the target, it gets linked as separated by whitespace
+Blank lines and lines starting with # are skipped, a missing file maps nothing */
+func loadLinksMap(path string) (map[string]string, error) {
+	data, err := readFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	links := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, errors.New("Error: Line " + strconv.Itoa(n) + " of " + path + " should have a file and the name it gets linked as")
+		}
+		links[filepath.ToSlash(filepath.Clean(fields[0]))] = filepath.ToSlash(filepath.Clean(fields[1]))
+	}
+	return links, scanner.Err()
+}
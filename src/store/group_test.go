@@ -0,0 +1,115 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLinkNameAppliesTheNameTransforms(t *testing.T) {
+	tests := []struct {
+		conf GroupConfig
+		rel  string
+		want string
+	}{
+		{GroupConfig{}, "vimrc", "vimrc"},
+		{GroupConfig{}, "dot_vimrc", "dot_vimrc"},
+		{GroupConfig{DotPrefix: true}, "vimrc", ".vimrc"},
+		{GroupConfig{DotPrefix: true}, ".vimrc", ".vimrc"},
+		{GroupConfig{DotPrefix: true}, "config/nvim/init.vim", ".config/nvim/init.vim"},
+		{GroupConfig{DotUnderscore: true}, "dot_config/nvim/dot_init", ".config/nvim/.init"},
+		{GroupConfig{DotUnderscore: true}, "dot_", "dot_"},
+		{GroupConfig{DotUnderscore: true}, "vimrc", "vimrc"},
+	}
+	for _, test := range tests {
+		if got := test.conf.LinkName(filepath.FromSlash(test.rel)); got != filepath.FromSlash(test.want) {
+			t.Errorf("%+v links %s as %s, want %s", test.conf, test.rel, got, test.want)
+		}
+	}
+}
+
+// Moves the mtime of everything in root back by age so the group cache trusts it
+func age(t *testing.T, root string, age time.Duration) {
+	t.Helper()
+	then := time.Now().Add(-age)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, then, then)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGroupConfigIsOnlyReadAgainOnceTheGroupChanges(t *testing.T) {
+	root := tempStore(t, map[string]string{
+		"Configs/vim/.tuckr.json": `{"platforms": ["linux"]}`,
+		"Configs/vim/.vimrc":      "",
+		"Configs/vim/links.map":   "vimrc.local .vimrc.local\n",
+	})
+	age(t, root, 3*time.Hour)
+	reads := 0
+	readFile = func(path string) ([]byte, error) {
+		reads++
+		return ioutil.ReadFile(path)
+	}
+	t.Cleanup(func() { readFile = ioutil.ReadFile })
+	load := func() GroupConfig {
+		t.Helper()
+		conf, err := LoadGroupConfig(root, "vim")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conf
+	}
+
+	for i := 0; i < 3; i++ {
+		load()
+		groups, err := ListGroups(root, GroupFilter{Platform: "linux"})
+		if err != nil || !reflect.DeepEqual(groups, []string{"vim"}) {
+			t.Fatalf("ListGroups returned %v %v, want vim", groups, err)
+		}
+	}
+	// Once for the .tuckr.json and once for the links.map
+	if reads != 2 {
+		t.Errorf("an unchanged group was read %d times, want 2", reads)
+	}
+	if conf := load(); conf.Links["vimrc.local"] != ".vimrc.local" {
+		t.Errorf("the cached settings lost the links.map's links: %v", conf.Links)
+	}
+
+	path := filepath.Join(root, "Configs", "vim", GroupConfigName)
+	if err := ioutil.WriteFile(path, []byte(`{"platforms": ["darwin"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	age(t, root, 2*time.Hour)
+	if conf := load(); !reflect.DeepEqual(conf.Platforms, []string{"darwin"}) || reads != 4 {
+		t.Errorf("after editing .tuckr.json the group supports %v after %d reads, want darwin after 4", conf.Platforms, reads)
+	}
+	load()
+
+	if err := ioutil.WriteFile(filepath.Join(root, "Configs", "vim", ".exrc"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	age(t, root, time.Hour)
+	load()
+	if reads != 6 {
+		t.Errorf("adding a file to the group led to %d reads, want 6", reads)
+	}
+	load()
+
+	// Just changed files might change again within the same mtime so they're read every time
+	if err := ioutil.WriteFile(path, []byte(`{"platforms": ["linux"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	load()
+	load()
+	if reads != 10 {
+		t.Errorf("a just changed group led to %d reads, want 10", reads)
+	}
+}
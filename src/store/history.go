@@ -0,0 +1,64 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// Name of the file in the store that every set and unset gets recorded in
+const HistoryName = "tuckr.history.jsonl"
+
+// Size the history can grow to before it's rotated into tuckr.history.jsonl.1
+const MaxHistorySize = 1 << 20
+
+// A record of one set or unset
+type HistoryEntry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Host      string    `json:"host"`
+	Operation string    `json:"operation"`
+	Groups    []string  `json:"groups"`
+	Links     int       `json:"links"`
+	Failed    int       `json:"failed"`
+}
+
+/* Appends the entry as a line of json to the history in root, usually the store's
+Time, User and Host are filled in when left empty and the history is rotated once
+it grows past MaxHistorySize, keeping only the previous file around */
+func AppendHistory(root string, entry HistoryEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	if entry.User == "" {
+		if u, err := user.Current(); err == nil {
+			entry.User = u.Username
+		}
+	}
+	if entry.Host == "" {
+		entry.Host, _ = os.Hostname()
+	}
+	if entry.Groups == nil {
+		entry.Groups = []string{}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(root, HistoryName)
+	if info, err := os.Stat(path); err == nil && info.Size()+int64(len(data)) > MaxHistorySize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAppendHistoryWritesAJSONLine(t *testing.T) {
+	root, err := ioutil.TempDir("", "tuckr-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, op := range []string{"set", "unset"} {
+		if err := AppendHistory(root, HistoryEntry{Operation: op, Groups: []string{"vim"}, Links: 2}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := ioutil.ReadFile(filepath.Join(root, HistoryName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("history has %d lines, want 2:\n%s", len(lines), data)
+	}
+	for i, op := range []string{"set", "unset"} {
+		var entry HistoryEntry
+		if err := json.Unmarshal(lines[i], &entry); err != nil {
+			t.Fatalf("line %d isn't json: %v", i+1, err)
+		}
+		if entry.Operation != op || !reflect.DeepEqual(entry.Groups, []string{"vim"}) || entry.Links != 2 {
+			t.Errorf("line %d recorded %+v", i+1, entry)
+		}
+		if entry.Time.IsZero() || entry.Host == "" {
+			t.Errorf("line %d is missing its time or host: %+v", i+1, entry)
+		}
+	}
+}
+
+func TestAppendHistoryRotates(t *testing.T) {
+	root, err := ioutil.TempDir("", "tuckr-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	path := filepath.Join(root, HistoryName)
+	full := bytes.Repeat([]byte("{}\n"), MaxHistorySize/3)
+	if err := ioutil.WriteFile(path, full, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendHistory(root, HistoryEntry{Operation: "set"}); err != nil {
+		t.Fatal(err)
+	}
+	if rotated, err := ioutil.ReadFile(path + ".1"); err != nil || !bytes.Equal(rotated, full) {
+		t.Errorf("the full history wasn't rotated: %v", err)
+	}
+	if data, err := ioutil.ReadFile(path); err != nil || bytes.Count(data, []byte("\n")) != 1 {
+		t.Errorf("the history wasn't started over: %q %v", data, err)
+	}
+}
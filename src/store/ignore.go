@@ -0,0 +1,104 @@
+package store
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the file listing the files of a group that shouldn't be linked
+const IgnoreName = ".tuckrignore"
+
+type ignorePattern struct {
+	glob   string
+	negate bool
+}
+
+/* Decides which files of a group are ignored
+Patterns are checked in order so later patterns override earlier ones */
+type Ignore struct {
+	patterns []ignorePattern
+}
+
+/* Parses an ignore file
+Blank lines and lines starting with # are skipped, a leading ! re-includes
+files an earlier pattern ignored and a pattern without a / matches any file or
+directory with that name */
+func ParseIgnore(r io.Reader) (Ignore, error) {
+	var ignore Ignore
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := ignorePattern{}
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		p.glob = strings.Trim(line, "/")
+		if p.glob != "" {
+			ignore.patterns = append(ignore.patterns, p)
+		}
+	}
+	return ignore, scanner.Err()
+}
+
+/* Reads the ignore patterns of the group's .tuckr.json or, when it has none, its .tuckrignore
+A group without either ignores nothing */
+func LoadIgnore(root string, group string) (Ignore, error) {
+	conf, err := LoadGroupConfig(root, group)
+	if err != nil {
+		return Ignore{}, err
+	}
+	if len(conf.Ignore) > 0 {
+		return ParseIgnore(strings.NewReader(strings.Join(conf.Ignore, "\n")))
+	}
+	f, err := os.Open(filepath.Join(GroupPath(root, group), IgnoreName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Ignore{}, nil
+		}
+		return Ignore{}, err
+	}
+	defer f.Close()
+	return ParseIgnore(f)
+}
+
+// Returns true if the path, relative to the group, is ignored
+func (ig Ignore) Match(rel string) bool {
+	_, ignored := ig.Matching(rel)
+	return ignored
+}
+
+// Returns the pattern that ignores the path, relative to the group, and whether it's ignored at all
+func (ig Ignore) Matching(rel string) (string, bool) {
+	rel = filepath.ToSlash(rel)
+	pattern := ""
+	ignored := false
+	for _, p := range ig.patterns {
+		if p.matches(rel) {
+			pattern = p.glob
+			ignored = !p.negate
+		}
+	}
+	return pattern, ignored
+}
+
+// Checks the pattern against the path and every directory leading up to it
+func (p ignorePattern) matches(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		name := strings.Join(parts[:i+1], "/")
+		if !strings.Contains(p.glob, "/") {
+			name = parts[i]
+		}
+		if ok, _ := filepath.Match(p.glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIgnoreHonorsCommentsGlobsAndNegation(t *testing.T) {
+	ignore, err := ParseIgnore(strings.NewReader(`# editor leftovers
+*.swp
+
+  # indented comments and blank lines are skipped too
+cache/
+*.log
+!keep.log
+.config/nvim/*.lua
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		".vimrc.swp":               true,
+		"notes/todo.swp":           true,
+		"cache/data":               true,
+		"nested/cache/data":        true,
+		"debug.log":                true,
+		"keep.log":                 false,
+		"logs/keep.log":            false,
+		".config/nvim/init.lua":    true,
+		".config/nvim/init.vim":    false,
+		"# editor leftovers":       false,
+		".vimrc":                   false,
+		"other/.config/nvim/x.lua": false,
+	}
+	for rel, want := range tests {
+		if got := ignore.Match(rel); got != want {
+			t.Errorf("Match(%q) = %v, want %v", rel, got, want)
+		}
+	}
+}
+
+func TestIgnoreLaterPatternsWin(t *testing.T) {
+	ignore, err := ParseIgnore(strings.NewReader("!keep.log\n*.log\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ignore.Match("keep.log") {
+		t.Error("a negation before the pattern it would undo re-included the file")
+	}
+	ignore, _ = ParseIgnore(strings.NewReader("*.log\n!keep.log\n"))
+	if pattern, ignored := ignore.Matching("keep.log"); ignored || pattern != "keep.log" {
+		t.Errorf("Matching(keep.log) = %q %v, want the negation to re-include it", pattern, ignored)
+	}
+}
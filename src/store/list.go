@@ -0,0 +1,66 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Decides which groups ListGroups leaves out, the zero value keeps all of them
+type GroupFilter struct {
+	// Leaves out groups whose .tuckr.json doesn't support this platform, empty keeps every platform
+	Platform string
+	// Leaves out groups whose names match, usually the store's own .tuckrignore
+	Ignore Ignore
+	// Leaves out groups it returns true for, nil keeps every group
+	Disabled func(group string) bool
+	// Leaves out groups whose .tuckr.json carries none of these tags, empty keeps every group
+	Tags []string
+}
+
+/* Returns the names of the groups of the store that make it through the filter
+sorted alphabetically. Commands go through it to share a single idea of what the
+deployable groups are, filtering by platform or tags only reads the settings of
+groups that changed since they were last read, see LoadGroupConfig */
+func ListGroups(storeRoot string, filter GroupFilter) ([]string, error) {
+	all, err := Groups(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+	var groups []string
+	for _, group := range all {
+		if filter.Ignore.Match(group) {
+			continue
+		}
+		if filter.Disabled != nil && filter.Disabled(group) {
+			continue
+		}
+		if filter.Platform != "" || len(filter.Tags) > 0 {
+			conf, err := LoadGroupConfig(storeRoot, group)
+			if err != nil {
+				return nil, err
+			}
+			if filter.Platform != "" && !conf.SupportsPlatform(filter.Platform) {
+				continue
+			}
+			if len(filter.Tags) > 0 && !conf.HasTag(filter.Tags) {
+				continue
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+/* Reads the .tuckrignore at the root of the store which lists whole groups to leave out
+A store without one ignores no groups */
+func LoadStoreIgnore(root string) (Ignore, error) {
+	f, err := os.Open(filepath.Join(root, IgnoreName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Ignore{}, nil
+		}
+		return Ignore{}, err
+	}
+	defer f.Close()
+	return ParseIgnore(f)
+}
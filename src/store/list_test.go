@@ -0,0 +1,103 @@
+package store
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Creates a store holding the files, keyed by their slash separated paths, that's removed once the test ends
+func tempStore(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root, err := ioutil.TempDir("", "tuckr-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+	for rel, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestListGroupsAppliesEveryFilter(t *testing.T) {
+	root := tempStore(t, map[string]string{
+		"Configs/vim/.vimrc":        "",
+		"Configs/vim/.tuckr.json":   `{"platforms": ["linux", "darwin"], "tags": ["editor"]}`,
+		"Configs/zsh/.zshrc":        "",
+		"Configs/zsh/.tuckr.json":   `{"tags": ["shell"]}`,
+		"Configs/paint/paint.ini":   "",
+		"Configs/paint/.tuckr.json": `{"platforms": ["windows"]}`,
+		"Configs/scratch/notes":     "",
+		"Bin/tools/backup":          "",
+		IgnoreName:                  "scratch\n",
+	})
+	ignore, err := LoadStoreIgnore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled := func(group string) bool { return group == "zsh" }
+	tests := []struct {
+		name   string
+		filter GroupFilter
+		want   []string
+	}{
+		{"nothing", GroupFilter{}, []string{"paint", "scratch", "tools", "vim", "zsh"}},
+		{"ignore", GroupFilter{Ignore: ignore}, []string{"paint", "tools", "vim", "zsh"}},
+		{"linux", GroupFilter{Platform: "linux"}, []string{"scratch", "tools", "vim", "zsh"}},
+		{"windows", GroupFilter{Platform: "windows", Ignore: ignore}, []string{"paint", "tools", "zsh"}},
+		{"disabled", GroupFilter{Platform: "linux", Ignore: ignore, Disabled: disabled}, []string{"tools", "vim"}},
+		{"tags", GroupFilter{Platform: "windows", Tags: []string{"editor", "shell"}}, []string{"zsh"}},
+	}
+	for _, test := range tests {
+		got, err := ListGroups(root, test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("filtering by %s listed %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// A group whose .tuckr.json is broken can't be filtered by platform
+	if err := ioutil.WriteFile(filepath.Join(root, "Configs", "zsh", GroupConfigName), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListGroups(root, GroupFilter{Platform: "linux"}); err == nil || !strings.Contains(err.Error(), "zsh") {
+		t.Errorf("listing with a broken .tuckr.json failed with %v, want the group named", err)
+	}
+}
+
+func TestGroupsDontDependOnTheFSOrder(t *testing.T) {
+	files := map[string]string{}
+	for _, group := range []string{"zsh", "vim", "git", "alacritty", "tmux"} {
+		files["Configs/"+group+"/."+group+"rc"] = ""
+	}
+	files["Bin/scripts/backup"] = ""
+	files["Bin/vim/vimdiff-all"] = ""
+	files["Services/syncthing/syncthing.service"] = ""
+	root := tempStore(t, files)
+	want := []string{"alacritty", "git", "scripts", "syncthing", "tmux", "vim", "zsh"}
+	t.Cleanup(func() { readDir = ioutil.ReadDir })
+	for seed := int64(0); seed < 20; seed++ {
+		shuffle := rand.New(rand.NewSource(seed))
+		readDir = func(dir string) ([]os.FileInfo, error) {
+			files, err := ioutil.ReadDir(dir)
+			shuffle.Shuffle(len(files), func(i, j int) { files[i], files[j] = files[j], files[i] })
+			return files, err
+		}
+		if got, err := Groups(root); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("listed in the order of seed %d the groups are %v %v, want %v", seed, got, err, want)
+		}
+	}
+}
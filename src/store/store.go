@@ -0,0 +1,226 @@
+package store
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/raphgl/tuckr/config"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/* Directories that make up a dotfiles store
+Configs holds one directory per group, Hooks holds the scripts for each group
+and Secrets holds the encrypted files for each group
+Bin and Services hold the executables and user services of each group */
+const (
+	ConfigsDir  = "Configs"
+	HooksDir    = "Hooks"
+	SecretsDir  = "Secrets"
+	BinDir      = "Bin"
+	ServicesDir = "Services"
+)
+
+// A top level folder of the store whose groups all get deployed into Root
+type FolderType struct {
+	Dir        string
+	Root       string
+	Executable bool
+}
+
+/* Returns the folder types of the store at root that hold groups and where each gets deployed
+Configs go into home, Bin into ~/.local/bin with its files made executable and
+Services into ~/.config/systemd/user. The roots can be overridden through
+overrides which is keyed by the folder's lowercase name
+Flat layouts only have the store itself whose groups go where configs would */
+func FolderTypes(root string, home string, overrides map[string]string) []FolderType {
+	folders := []FolderType{
+		{Dir: ConfigsDir, Root: home},
+		{Dir: BinDir, Root: filepath.Join(home, ".local", "bin"), Executable: true},
+		{Dir: ServicesDir, Root: filepath.Join(home, ".config", "systemd", "user")},
+	}
+	if Format != FormatTuckr {
+		folders = []FolderType{{Dir: "", Root: flatRoot(root, home)}}
+	}
+	for i, folder := range folders {
+		name := folder.Dir
+		if name == "" {
+			name = ConfigsDir
+		}
+		if root, ok := overrides[strings.ToLower(name)]; ok && root != "" {
+			folders[i].Root = root
+		}
+	}
+	return folders
+}
+
+// Directories of the store that hold groups
+var groupDirs = []string{ConfigsDir, BinDir, ServicesDir}
+
+/* Returns the path to the dotfiles store
+$TUCKR_STORE takes precedence, then the config's dotfiles_dest or git's dotfiles.dest,
+otherwise the current directory is used */
+func Root() (string, error) {
+	root, err := ConfiguredRoot()
+	if err == errNoStore {
+		return os.Getwd()
+	}
+	return root, err
+}
+
+// Returned by ConfiguredRoot when neither sets the store
+var errNoStore = errors.New("Error: No store is set, set $TUCKR_STORE or dotfiles_dest in the config")
+
+/* Same as Root but the store has to be set with $TUCKR_STORE or dotfiles_dest,
+the current directory is never taken for it */
+func ConfiguredRoot() (string, error) {
+	if dir := os.Getenv("TUCKR_STORE"); dir != "" {
+		return config.ExpandPath(dir)
+	}
+	if conf, err := config.LoadConfig(); err == nil && conf.General.DotfilesDest != "" {
+		return conf.General.DotfilesDest, nil
+	}
+	return "", errNoStore
+}
+
+/* Returns the path to a group inside of the first of Configs, Bin and Services it's in,
+or inside of the store itself for flat layouts. That's where its .tuckr.json, links.map
+and README are read from. A group that's in none of them yet gets a path in Configs */
+func GroupPath(root string, group string) string {
+	folders := groupFolders()
+	for _, folder := range folders {
+		dir := filepath.Join(root, folder, group)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() && isGroupName(folder, group) {
+			return dir
+		}
+	}
+	return filepath.Join(root, folders[0], group)
+}
+
+/* Name of the file that makes the directory holding it not a group
+Useful for directories that other groups share, like templates or libraries */
+const IgnoreGroupName = ".tuckr-ignore-group"
+
+// Returns true if dir holds an IgnoreGroupName marker
+func IgnoresGroup(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, IgnoreGroupName))
+	return err == nil
+}
+
+/* Returns the names of all the groups in the store sorted alphabetically
+A group is any directory in Configs, Bin or Services, or at the top of the store
+for flat layouts, without an IgnoreGroupName marker */
+func Groups(root string) ([]string, error) {
+	var groups []string
+	seen := map[string]bool{}
+	found := false
+	for _, groupDir := range groupFolders() {
+		dir, err := readDir(filepath.Join(root, groupDir))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return groups, err
+		}
+		found = true
+		for _, f := range dir {
+			if f.IsDir() && !seen[f.Name()] && isGroupName(groupDir, f.Name()) && !IgnoresGroup(filepath.Join(root, groupDir, f.Name())) {
+				seen[f.Name()] = true
+				groups = append(groups, f.Name())
+			}
+		}
+	}
+	if !found && Format != FormatTuckr {
+		return groups, errors.New("Error: No store found at " + root)
+	}
+	if !found {
+		return groups, errors.New("Error: No " + ConfigsDir + " directory found in " + root)
+	}
+	sort.Strings(groups)
+	return groups, nil
+}
+
+// Returns true if the group has a directory in Configs, Bin or Services that isn't ignored
+func HasGroup(root string, group string) bool {
+	for _, groupDir := range groupFolders() {
+		if !isGroupName(groupDir, group) {
+			continue
+		}
+		dir := filepath.Join(root, groupDir, group)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() && !IgnoresGroup(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Name of the file whose first line describes a group
+const ReadmeName = "README"
+
+/* Returns the first line of a group's README
+An empty string is returned when the group has no README */
+func Description(root string, group string) string {
+	f, err := os.Open(filepath.Join(GroupPath(root, group), ReadmeName))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if scanner.Scan() {
+		return strings.TrimSpace(scanner.Text())
+	}
+	return ""
+}
+
+// Returns the hex encoded sha256 of the file's contents
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+/* Returns the group that a path, relative to the store, belongs to
+Paths under Configs, Bin, Services, Hooks or Secrets belong to the group named by
+the directory right below them, or by the directory at the top for flat layouts.
+An empty string is returned for anything else */
+func GroupOf(path string) string {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	if len(parts) >= 2 && Format != FormatTuckr && isGroupName("", parts[0]) {
+		return parts[0]
+	}
+	if len(parts) < 3 {
+		return ""
+	}
+	for _, folder := range Folders() {
+		if parts[0] == folder {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// Returns the groups that the paths, relative to the store, belong to, see GroupOf
+func GroupsOf(paths []string) []string {
+	seen := map[string]bool{}
+	var groups []string
+	for _, path := range paths {
+		group := GroupOf(path)
+		if group != "" && !seen[group] {
+			seen[group] = true
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
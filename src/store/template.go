@@ -0,0 +1,127 @@
+package store
+
+import (
+	"github.com/raphgl/tuckr/config"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+/* Extension of the files of a group that are templates
+tuckr doesn't render templates when it deploys them, set links a .tmpl like any
+other file for whatever renders it. check --templates is the only thing that
+renders them, to catch broken ones before they're deployed */
+const TemplateExt = ".tmpl"
+
+// What templates get rendered against
+type TemplateData struct {
+	OS       string
+	Arch     string
+	Hostname string
+	Home     string
+	Env      map[string]string
+}
+
+// Returns the data of the environment tuckr runs in for templates to be rendered against
+func CurrentTemplateData() TemplateData {
+	data := TemplateData{OS: runtime.GOOS, Arch: runtime.GOARCH, Env: map[string]string{}}
+	data.Hostname, _ = os.Hostname()
+	data.Home, _ = config.HomeDir()
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			data.Env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return data
+}
+
+// A template that doesn't parse or render, Line is 0 when it isn't known
+type TemplateError struct {
+	Path string
+	Line int
+	Msg  string
+}
+
+// Formats the error as path:line: msg like compilers do
+func (e *TemplateError) Error() string {
+	if e.Line == 0 {
+		return e.Path + ": " + e.Msg
+	}
+	return e.Path + ":" + strconv.Itoa(e.Line) + ": " + e.Msg
+}
+
+/* Turns an error of text/template for the template at path into a TemplateError
+Its errors look like template: path:line: msg when parsing and template:
+path:line:col: executing "path" at <.Key>: msg when rendering */
+func templateError(path string, err error) *TemplateError {
+	msg := strings.TrimPrefix(err.Error(), "template: "+path+":")
+	if msg == err.Error() {
+		return &TemplateError{Path: path, Msg: msg}
+	}
+	i := strings.IndexFunc(msg, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return &TemplateError{Path: path, Msg: msg}
+	}
+	line, _ := strconv.Atoi(msg[:i])
+	msg = msg[i:]
+	// the column is left out, text/template only knows it when rendering
+	if j := strings.Index(msg, ": "); j >= 0 {
+		msg = msg[j+2:]
+	}
+	msg = strings.TrimPrefix(msg, "executing "+strconv.Quote(path)+" at ")
+	return &TemplateError{Path: path, Line: line, Msg: msg}
+}
+
+/* Renders the template at path against data into w
+Errors are TemplateErrors holding the line they're on, keys that aren't in the
+data are errors instead of rendering as <no value> */
+func RenderTemplate(path string, data TemplateData, w io.Writer) error {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return templateError(path, err)
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		return templateError(path, err)
+	}
+	return nil
+}
+
+// Returns the templates in every group of the store sorted by path
+func Templates(root string) ([]string, error) {
+	groups, err := Groups(root)
+	if err != nil {
+		return nil, err
+	}
+	var templates []string
+	for _, folder := range groupFolders() {
+		for _, group := range groups {
+			err := filepath.Walk(filepath.Join(root, folder, group), func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					if os.IsNotExist(err) {
+						return nil
+					}
+					return err
+				}
+				if info.Mode().IsRegular() && strings.HasSuffix(path, TemplateExt) {
+					templates = append(templates, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(templates)
+	return templates, nil
+}
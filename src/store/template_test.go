@@ -0,0 +1,44 @@
+package store
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplateReportsWhereItBroke(t *testing.T) {
+	root := tempStore(t, map[string]string{
+		"Configs/git/.gitconfig.tmpl": "[user]\n\tname = {{ .Env.NAME }}\n[core]\n\teditor = {{ if eq .OS \"linux\" }}vim\n",
+		"Configs/git/.gitignore.tmpl": "# {{ .Hostname }}\n*.swp\n{{ .Missing }}\n",
+		"Configs/zsh/.zshrc.tmpl":     "export HOME={{ .Home }}\n",
+	})
+	data := TemplateData{OS: "linux", Hostname: "laptop", Home: "/home/me", Env: map[string]string{"NAME": "me"}}
+	tests := map[string]struct {
+		line int
+		msg  string
+	}{
+		"Configs/git/.gitconfig.tmpl": {5, "unexpected EOF"},
+		"Configs/git/.gitignore.tmpl": {3, "<.Missing>: can't evaluate field Missing in type store.TemplateData"},
+	}
+	for rel, want := range tests {
+		path := filepath.Join(root, rel)
+		err := RenderTemplate(path, data, &bytes.Buffer{})
+		tmplErr, ok := err.(*TemplateError)
+		if !ok {
+			t.Errorf("rendering %s failed with %v, want a TemplateError", rel, err)
+			continue
+		}
+		if tmplErr.Path != path || tmplErr.Line != want.line || tmplErr.Msg != want.msg {
+			t.Errorf("rendering %s failed with %+v, want line %d %q", rel, tmplErr, want.line, want.msg)
+		}
+		if prefix := path + ":" + strconv.Itoa(want.line) + ": "; !strings.HasPrefix(err.Error(), prefix) {
+			t.Errorf("the error %q doesn't start with %q", err, prefix)
+		}
+	}
+	var out bytes.Buffer
+	if err := RenderTemplate(filepath.Join(root, "Configs/zsh/.zshrc.tmpl"), data, &out); err != nil || out.String() != "export HOME=/home/me\n" {
+		t.Errorf("rendering a working template gave %q %v", out.String(), err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A config in home that no group manages and the group it could be moved into
+type suggestion struct {
+	path  string
+	group string
+	dest  string
+}
+
+/* Handles the suggest command which looks for configs in home that no group manages
+and prints the commands that move each one into a group of the store
+Where it looks is the config's suggest_paths */
+func runSuggest(args []string) error {
+	flags := flag.NewFlagSet("suggest", flag.ExitOnError)
+	userName := flags.String("user", "", "look in this user's home instead")
+	flags.Parse(args)
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	home, err := targetHome(*userName)
+	if err != nil {
+		return err
+	}
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	suggestions, err := unmanagedConfigs(root, home, conf.General.SuggestPaths)
+	if err != nil {
+		return err
+	}
+	for _, s := range suggestions {
+		fmt.Println(aurora.Cyan("Unmanaged:"), s.path)
+		fmt.Println("  mkdir -p " + shellQuote(filepath.Dir(s.dest)) + " && mv " + shellQuote(s.path) + " " + shellQuote(s.dest) + " && tuckr set " + s.group)
+	}
+	if len(suggestions) == 0 {
+		fmt.Println(aurora.Green("No unmanaged configs found in"), home)
+	}
+	return nil
+}
+
+/* Returns the files and directories matching the globs, relative to home, that no group manages
+A path is managed when it's a symlink into the store or the cache, or a target of some
+group is the path itself or inside of it */
+func unmanagedConfigs(root string, home string, globs []string) ([]suggestion, error) {
+	targets, err := allTargets(root, home)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := config.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var suggestions []suggestion
+	for _, glob := range globs {
+		matches, err := filepath.Glob(filepath.Join(home, glob))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		for _, path := range matches {
+			if seen[path] || managed(path, root, cache, targets) {
+				continue
+			}
+			seen[path] = true
+			rel, err := filepath.Rel(home, path)
+			if err != nil {
+				return nil, err
+			}
+			group := suggestedGroup(rel)
+			suggestions = append(suggestions, suggestion{path: path, group: group, dest: filepath.Join(store.GroupPath(root, group), rel)})
+		}
+	}
+	return suggestions, nil
+}
+
+// Returns the targets of every group of the store
+func allTargets(root string, home string) ([]string, error) {
+	// A file that's in any group is taken care of, whichever machine the group is set on
+	groups, err := store.ListGroups(root, store.GroupFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, group := range groups {
+		deployments, err := groupDeployments(root, group, home)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range deployments {
+			links, err := groupLinks(root, group, d)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range links {
+				targets = append(targets, l.Target)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// Returns true if the path is managed by a group, see unmanagedConfigs
+func managed(path string, root string, cache string, targets []string) bool {
+	if dest, err := os.Readlink(path); err == nil && (manage.IsWithin(dest, root) || manage.IsWithin(dest, cache)) {
+		return true
+	}
+	for _, target := range targets {
+		if manage.IsWithin(target, path) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Returns the name of the group a config, relative to home, could go into
+It's the config's name without a leading dot, an extension or a trailing rc so
+.config/nvim goes into nvim, .tmux.conf into tmux and .bashrc into bash */
+func suggestedGroup(rel string) string {
+	name := strings.TrimPrefix(filepath.Base(rel), ".")
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	if trimmed := strings.TrimSuffix(name, "rc"); trimmed != "" {
+		name = trimmed
+	}
+	if !validGroupName.MatchString(name) {
+		return "configs"
+	}
+	return name
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/config"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSuggestOnlyListsConfigsNoGroupManages(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	e.write(t, "Configs/git/.config/git/config", "")
+	e.write(t, "Configs/zsh/.zshrc", "")
+	writeTestFile(t, e.inHome(".bashrc"), "")
+	writeTestFile(t, e.inHome(".config/nvim/init.lua"), "")
+	// Written in home but still a target of zsh, which just isn't set
+	writeTestFile(t, e.inHome(".zshrc"), "")
+	captureOutput(t, func() {
+		if err := runSet([]string{"vim", "git"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	suggestions, err := unmanagedConfigs(e.store, e.home, config.Default().General.SuggestPaths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []suggestion{
+		{path: e.inHome(".bashrc"), group: "bash", dest: filepath.Join(e.store, "Configs", "bash", ".bashrc")},
+		{path: e.inHome(".config/nvim"), group: "nvim", dest: filepath.Join(e.store, "Configs", "nvim", ".config", "nvim")},
+	}
+	if !reflect.DeepEqual(suggestions, want) {
+		t.Errorf("the default paths suggested %+v, want %+v", suggestions, want)
+	}
+
+	e.config(t, "[GENERAL]\nsuggest_paths = .config/*, .tmux.conf\n")
+	writeTestFile(t, e.inHome(".tmux.conf"), "")
+	out := uncolored(captureOutput(t, func() {
+		if err := runSuggest(nil); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	for _, path := range []string{".config/nvim", ".tmux.conf"} {
+		if !strings.Contains(out, "Unmanaged: "+e.inHome(path)+"\n") {
+			t.Errorf("suggest didn't list %s:\n%s", path, out)
+		}
+	}
+	for _, path := range []string{".bashrc", ".vimrc", ".config/git"} {
+		if strings.Contains(out, "Unmanaged: "+e.inHome(path)+"\n") {
+			t.Errorf("suggest listed %s outside of suggest_paths or managed:\n%s", path, out)
+		}
+	}
+	if !strings.Contains(out, "&& tuckr set tmux") {
+		t.Errorf("suggest didn't propose the tmux group:\n%s", out)
+	}
+}
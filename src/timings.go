@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"time"
+)
+
+// How long each phase of a run took, collected for --timings
+type timings struct {
+	now       func() time.Time
+	phases    []string
+	durations map[string]time.Duration
+}
+
+// Returns timings that read the time from now, which is time.Now outside of tests
+func newTimings(now func() time.Time) *timings {
+	return &timings{now: now, durations: map[string]time.Duration{}}
+}
+
+/* Starts timing phase and returns the function that stops it
+Time spent in the same phase more than once adds up. Nil timings time nothing
+so callers don't have to check whether --timings was passed */
+func (t *timings) start(phase string) func() {
+	if t == nil {
+		return func() {}
+	}
+	begin := t.now()
+	return func() {
+		if _, ok := t.durations[phase]; !ok {
+			t.phases = append(t.phases, phase)
+		}
+		t.durations[phase] += t.now().Sub(begin)
+	}
+}
+
+// Prints how long each phase took in the order they first ran
+func (t *timings) print() {
+	if t == nil {
+		return
+	}
+	for _, phase := range t.phases {
+		fmt.Println(aurora.Cyan("Took:"), t.durations[phase].Round(time.Millisecond), phase)
+	}
+}
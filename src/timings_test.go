@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A clock that only moves when it's told to
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestTimingsAddUpEachPhase(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	took := newTimings(clock.Now)
+	stop := took.start("linking")
+	clock.advance(1500 * time.Millisecond)
+	stop()
+	stop = took.start("scripts")
+	clock.advance(2 * time.Second)
+	stop()
+	stop = took.start("linking")
+	clock.advance(500 * time.Millisecond)
+	stop()
+	out := captureOutput(t, took.print)
+	if want := []string{"2s linking", "2s scripts"}; !strings.Contains(out, want[0]) || !strings.Contains(out, want[1]) || strings.Index(out, want[0]) > strings.Index(out, want[1]) {
+		t.Errorf("timings printed %q, want %v in that order", out, want)
+	}
+
+	var none *timings
+	none.start("linking")()
+	if out := captureOutput(t, none.print); out != "" {
+		t.Errorf("nil timings printed %q", out)
+	}
+}
+
+func TestResetTimesCloningLinkingAndScripts(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "Configs/vim/.vimrc", "")
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	runner := &stubRunner{run: func(name string, args ...string) error {
+		clock.advance(3 * time.Second)
+		e.write(t, "Configs/vim/.vimrc", "")
+		e.write(t, "Hooks/vim/set_up.sh", "")
+		return nil
+	}}
+	took := newTimings(clock.Now)
+	captureOutput(t, func() {
+		general := config.General{DotfilesRepo: "https://example.com/dotfiles.git"}
+		if err := resetStore(e.store, e.home, general, true, runner, took); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if got := took.durations["cloning"]; got != 3*time.Second {
+		t.Errorf("cloning took %v, want the 3s the clock moved", got)
+	}
+	if want := []string{"cloning", "linking", "scripts"}; strings.Join(took.phases, " ") != strings.Join(want, " ") {
+		t.Errorf("timed %v, want %v", took.phases, want)
+	}
+}
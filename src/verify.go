@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/manage"
+	"github.com/raphgl/tuckr/state"
+	"github.com/raphgl/tuckr/store"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+/* Handles the verify command
+Every store file recorded in the index by a previous set is checked to still be
+in the store, with --repair missing files are restored from their copy in the
+cache when it still has the recorded contents */
+func runVerify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "restore missing store files from the cache")
+	flags.Parse(args)
+	root, err := store.Root()
+	if err != nil {
+		return err
+	}
+	index, err := state.LoadIndex()
+	if err != nil {
+		return err
+	}
+	cache, err := config.CacheDir()
+	if err != nil {
+		return err
+	}
+	missing, err := verifyStore(root, cache, index, *repair)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return errors.New("Error: " + strconv.Itoa(len(missing)) + " store file(s) are missing")
+	}
+	fmt.Println(aurora.Green("Verified:"), "all deployed store files are present")
+	return nil
+}
+
+/* Checks that every store file in the index that belongs to root still exists
+Returns the paths that are missing and couldn't be repaired */
+func verifyStore(root string, cache string, index state.FileIndex, repair bool) ([]string, error) {
+	var paths []string
+	for path := range index {
+		if manage.IsWithin(path, root) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var missing []string
+	for _, path := range paths {
+		if _, err := os.Lstat(path); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if repair {
+			restored, err := restoreFromCache(root, cache, path, index[path])
+			if err != nil {
+				return nil, err
+			}
+			if restored {
+				fmt.Println(aurora.Green("Restored:"), path)
+				continue
+			}
+		}
+		fmt.Println(aurora.Red("Missing:"), path)
+		missing = append(missing, path)
+	}
+	return missing, nil
+}
+
+// Copies the cached copy of a store file back into the store if it matches the index
+func restoreFromCache(root string, cache string, path string, entry state.FileEntry) (bool, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false, err
+	}
+	backup := filepath.Join(cache, rel)
+	info, err := os.Stat(backup)
+	if err != nil {
+		return false, nil
+	}
+	if !info.IsDir() {
+		hash, err := store.HashFile(backup)
+		if err != nil || hash != entry.Hash {
+			return false, nil
+		}
+	}
+	return true, manage.CopyTree(backup, path)
+}
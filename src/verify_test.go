@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/raphgl/tuckr/config"
+	"github.com/raphgl/tuckr/state"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVerifyRepairsFromTheCacheOrReportsTheMissingPath(t *testing.T) {
+	e := newTestEnv(t)
+	e.config(t, "[GENERAL]\nread_only_store = true\n")
+	vimrc := e.write(t, "Configs/dots/.vimrc", "set nu")
+	zshrc := e.write(t, "Configs/dots/.zshrc", "setopt autocd")
+	e.write(t, "Configs/dots/.bashrc", "")
+	captureOutput(t, func() {
+		if err := setGroups(e.store, e.home, []string{"dots"}, setOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	cache, err := config.CacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(vimrc)
+	os.Remove(zshrc)
+	// A cached copy that no longer holds what got deployed can't stand in for the store file
+	writeTestFile(t, filepath.Join(cache, "Configs", "dots", ".zshrc"), "changed")
+
+	index, err := state.LoadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var missing []string
+	out := captureOutput(t, func() {
+		if missing, err = verifyStore(e.store, cache, index, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := []string{vimrc, zshrc}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("verify reported %v missing, want %v", missing, want)
+	}
+	if _, err := os.Stat(vimrc); err == nil {
+		t.Error("verify without --repair restored a file")
+	}
+
+	out = captureOutput(t, func() {
+		if missing, err = verifyStore(e.store, cache, index, true); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := []string{zshrc}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("verify --repair left %v missing, want %v", missing, want)
+	}
+	if !strings.Contains(out, "Missing:") || !strings.Contains(out, zshrc) || strings.Contains(out, "Missing: "+vimrc) {
+		t.Errorf("the missing path wasn't reported:\n%s", out)
+	}
+	if data, err := ioutil.ReadFile(vimrc); err != nil || string(data) != "set nu" {
+		t.Errorf("%s was restored with %q %v", vimrc, data, err)
+	}
+}